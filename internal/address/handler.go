@@ -0,0 +1,193 @@
+package address
+
+import (
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/response"
+	"e-commerce/pkg/errno"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// CreateAddress 新增收货地址
+func (h *Handler) CreateAddress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var body CreateAddressBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	a, err := h.svc.CreateAddress(ctx, CreateAddressParam{
+		UserID: accountInfo.AccountId,
+		AddressInfo: model.AddressInfo{
+			Receiver: body.Receiver,
+			Phone:    body.Phone,
+			Province: body.Province,
+			City:     body.City,
+			District: body.District,
+			Detail:   body.Detail,
+		},
+		IsDefault: body.IsDefault,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, FormatAddressItem(a))
+}
+
+// ListAddresses 查看自己的收货地址
+func (h *Handler) ListAddresses(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	addresses, err := h.svc.ListAddresses(ctx, accountInfo.AccountId)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	items := make([]AddressItem, 0, len(addresses))
+	for _, a := range addresses {
+		items = append(items, *FormatAddressItem(a))
+	}
+	response.Write(c, nil, ListAddressesResponse{Addresses: items})
+}
+
+func (h *Handler) GetAddress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	addressID, ok := bindAddressID(c)
+	if !ok {
+		return
+	}
+
+	a, err := h.svc.GetAddress(ctx, addressID, accountInfo.AccountId)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, FormatAddressItem(a))
+}
+
+// UpdateAddress 修改收货地址，只修改传入的字段
+func (h *Handler) UpdateAddress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	addressID, ok := bindAddressID(c)
+	if !ok {
+		return
+	}
+	var body UpdateAddressBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	a, err := h.svc.UpdateAddress(ctx, UpdateAddressParam{
+		UserID:    accountInfo.AccountId,
+		AddressID: addressID,
+		Receiver:  body.Receiver,
+		Phone:     body.Phone,
+		Province:  body.Province,
+		City:      body.City,
+		District:  body.District,
+		Detail:    body.Detail,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, FormatAddressItem(a))
+}
+
+// SetDefault 设为默认地址
+func (h *Handler) SetDefault(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	addressID, ok := bindAddressID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.SetDefault(ctx, addressID, accountInfo.AccountId); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// DeleteAddress 删除收货地址
+func (h *Handler) DeleteAddress(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	addressID, ok := bindAddressID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.DeleteAddress(ctx, addressID, accountInfo.AccountId); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+func bindAddressID(c *gin.Context) (uuid.UUID, bool) {
+	var uri UriWithAddressID
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return uuid.Nil, false
+	}
+	addressID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return uuid.Nil, false
+	}
+	return addressID, true
+}
@@ -0,0 +1,25 @@
+package address
+
+import (
+	"e-commerce/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type CreateAddressParam struct {
+	UserID uuid.UUID
+	model.AddressInfo
+	IsDefault bool
+}
+
+// UpdateAddressParam 为空的字段不修改
+type UpdateAddressParam struct {
+	UserID    uuid.UUID
+	AddressID uuid.UUID
+	Receiver  *string
+	Phone     *string
+	Province  *string
+	City      *string
+	District  *string
+	Detail    *string
+}
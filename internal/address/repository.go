@@ -0,0 +1,100 @@
+package address
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/database"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	*database.BaseRepo
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{BaseRepo: database.NewBaseRepo(db)}
+}
+
+// LockUserAddresses 锁定用户的全部地址，使同一用户的新增、删除和默认地址切换串行执行（事务内使用）
+func (repo *Repository) LockUserAddresses(ctx context.Context, userID uuid.UUID) ([]*model.Address, error) {
+	var addresses []*model.Address
+	err := repo.GetDB(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		Order("updated_at DESC").
+		Find(&addresses).Error
+	return addresses, err
+}
+
+func (repo *Repository) CreateAddress(ctx context.Context, a *model.Address) error {
+	return repo.GetDB(ctx).Create(a).Error
+}
+
+// GetUserAddress 查询用户的地址，不属于该用户时返回 gorm.ErrRecordNotFound
+func (repo *Repository) GetUserAddress(ctx context.Context, id, userID uuid.UUID) (*model.Address, error) {
+	var a model.Address
+	err := repo.GetDB(ctx).Where("id = ? AND user_id = ?", id, userID).First(&a).Error
+	return &a, err
+}
+
+// GetDefaultAddress 查询用户的默认地址，未设置时返回 gorm.ErrRecordNotFound
+func (repo *Repository) GetDefaultAddress(ctx context.Context, userID uuid.UUID) (*model.Address, error) {
+	var a model.Address
+	err := repo.GetDB(ctx).Where("user_id = ? AND is_default", userID).First(&a).Error
+	return &a, err
+}
+
+// ListAddresses 默认地址在前，其余按最近创建排序
+func (repo *Repository) ListAddresses(ctx context.Context, userID uuid.UUID) ([]*model.Address, error) {
+	var addresses []*model.Address
+	err := repo.GetDB(ctx).
+		Where("user_id = ?", userID).
+		Order("is_default DESC, created_at DESC").
+		Find(&addresses).Error
+	return addresses, err
+}
+
+// UpdateAddress 更新地址字段，地址不存在或不属于该用户时返回 gorm.ErrRecordNotFound
+func (repo *Repository) UpdateAddress(ctx context.Context, id, userID uuid.UUID, data map[string]interface{}) error {
+	result := repo.GetDB(ctx).Model(&model.Address{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Updates(data)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SetDefault 将指定地址设为默认并取消原默认地址，先取消再设置以满足部分唯一索引（事务内使用）
+func (repo *Repository) SetDefault(ctx context.Context, id, userID uuid.UUID) error {
+	db := repo.GetDB(ctx)
+	if err := db.Model(&model.Address{}).
+		Where("user_id = ? AND is_default AND id <> ?", userID, id).
+		Update("is_default", false).Error; err != nil {
+		return err
+	}
+	return repo.UpdateAddress(ctx, id, userID, map[string]interface{}{"is_default": true})
+}
+
+// DeleteAddress 删除地址，地址不存在或不属于该用户时返回 gorm.ErrRecordNotFound
+func (repo *Repository) DeleteAddress(ctx context.Context, id, userID uuid.UUID) error {
+	result := repo.GetDB(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&model.Address{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteUserAddresses 删除用户的全部地址，用于注销账号
+func (repo *Repository) DeleteUserAddresses(ctx context.Context, userID uuid.UUID) error {
+	return repo.GetDB(ctx).Where("user_id = ?", userID).Delete(&model.Address{}).Error
+}
@@ -0,0 +1,24 @@
+package address
+
+type CreateAddressBody struct {
+	Receiver  string `json:"receiver" binding:"required,max=64"`
+	Phone     string `json:"phone" binding:"required,max=32"`
+	Province  string `json:"province" binding:"required,max=64"`
+	City      string `json:"city" binding:"required,max=64"`
+	District  string `json:"district" binding:"omitempty,max=64"`
+	Detail    string `json:"detail" binding:"required,max=255"`
+	IsDefault bool   `json:"is_default"`
+}
+
+type UpdateAddressBody struct {
+	Receiver *string `json:"receiver" binding:"omitempty,min=1,max=64"`
+	Phone    *string `json:"phone" binding:"omitempty,min=1,max=32"`
+	Province *string `json:"province" binding:"omitempty,min=1,max=64"`
+	City     *string `json:"city" binding:"omitempty,min=1,max=64"`
+	District *string `json:"district" binding:"omitempty,max=64"`
+	Detail   *string `json:"detail" binding:"omitempty,min=1,max=255"`
+}
+
+type UriWithAddressID struct {
+	ID string `uri:"id" binding:"required"`
+}
@@ -0,0 +1,49 @@
+package address
+
+import (
+	"e-commerce/internal/model"
+	"time"
+)
+
+// ShippingInfo 收货信息，订单中的收货地址快照使用同一结构
+type ShippingInfo struct {
+	Receiver string `json:"receiver"`
+	Phone    string `json:"phone"`
+	Province string `json:"province"`
+	City     string `json:"city"`
+	District string `json:"district"`
+	Detail   string `json:"detail"`
+}
+
+type AddressItem struct {
+	ID string `json:"id"`
+	ShippingInfo
+	IsDefault bool   `json:"is_default"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type ListAddressesResponse struct {
+	Addresses []AddressItem `json:"addresses"`
+}
+
+func FormatShippingInfo(info *model.AddressInfo) *ShippingInfo {
+	return &ShippingInfo{
+		Receiver: info.Receiver,
+		Phone:    info.Phone,
+		Province: info.Province,
+		City:     info.City,
+		District: info.District,
+		Detail:   info.Detail,
+	}
+}
+
+func FormatAddressItem(a *model.Address) *AddressItem {
+	return &AddressItem{
+		ID:           a.ID.String(),
+		ShippingInfo: *FormatShippingInfo(&a.AddressInfo),
+		IsDefault:    a.IsDefault,
+		CreatedAt:    a.CreatedAt.Format(time.DateTime),
+		UpdatedAt:    a.UpdatedAt.Format(time.DateTime),
+	}
+}
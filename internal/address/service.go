@@ -0,0 +1,157 @@
+package address
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/database"
+	"e-commerce/pkg/errno"
+	"errors"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxAddressesPerUser 每个用户最多保存的收货地址数量
+const maxAddressesPerUser = 20
+
+type Service struct {
+	db   *gorm.DB
+	repo *Repository
+}
+
+func NewService(db *gorm.DB, repo *Repository) *Service {
+	return &Service{db: db, repo: repo}
+}
+
+// CreateAddress 新增收货地址，用户的第一个地址自动设为默认
+func (svc *Service) CreateAddress(ctx context.Context, param CreateAddressParam) (*model.Address, error) {
+	var a *model.Address
+	err := database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		existing, err := svc.repo.LockUserAddresses(ctx, param.UserID)
+		if err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		if len(existing) >= maxAddressesPerUser {
+			return errno.ErrAddressFull
+		}
+
+		a = &model.Address{
+			UserID:      param.UserID,
+			AddressInfo: param.AddressInfo,
+		}
+		if err := svc.repo.CreateAddress(ctx, a); err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		if param.IsDefault || len(existing) == 0 {
+			if err := svc.repo.SetDefault(ctx, a.ID, param.UserID); err != nil {
+				return errno.ErrDatabase.WithRaw(err)
+			}
+			a.IsDefault = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (svc *Service) ListAddresses(ctx context.Context, userID uuid.UUID) ([]*model.Address, error) {
+	addresses, err := svc.repo.ListAddresses(ctx, userID)
+	if err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	return addresses, nil
+}
+
+func (svc *Service) GetAddress(ctx context.Context, id, userID uuid.UUID) (*model.Address, error) {
+	a, err := svc.repo.GetUserAddress(ctx, id, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrAddressNotFound
+		}
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	return a, nil
+}
+
+// UpdateAddress 修改地址内容，已下单的订单使用下单时的快照，不受影响
+func (svc *Service) UpdateAddress(ctx context.Context, param UpdateAddressParam) (*model.Address, error) {
+	updateData := map[string]interface{}{}
+	if param.Receiver != nil {
+		updateData["receiver"] = *param.Receiver
+	}
+	if param.Phone != nil {
+		updateData["phone"] = *param.Phone
+	}
+	if param.Province != nil {
+		updateData["province"] = *param.Province
+	}
+	if param.City != nil {
+		updateData["city"] = *param.City
+	}
+	if param.District != nil {
+		updateData["district"] = *param.District
+	}
+	if param.Detail != nil {
+		updateData["detail"] = *param.Detail
+	}
+
+	if len(updateData) > 0 {
+		if err := svc.repo.UpdateAddress(ctx, param.AddressID, param.UserID, updateData); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errno.ErrAddressNotFound
+			}
+			return nil, errno.ErrDatabase.WithRaw(err)
+		}
+	}
+	return svc.GetAddress(ctx, param.AddressID, param.UserID)
+}
+
+// SetDefault 设为默认地址，下单未指定地址时使用
+func (svc *Service) SetDefault(ctx context.Context, id, userID uuid.UUID) error {
+	return database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		if _, err := svc.repo.LockUserAddresses(ctx, userID); err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		if err := svc.repo.SetDefault(ctx, id, userID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errno.ErrAddressNotFound
+			}
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		return nil
+	})
+}
+
+// DeleteAddress 删除地址；删除的是默认地址时，最近修改的其他地址成为默认地址
+func (svc *Service) DeleteAddress(ctx context.Context, id, userID uuid.UUID) error {
+	return database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		addresses, err := svc.repo.LockUserAddresses(ctx, userID)
+		if err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		var target *model.Address
+		var next *model.Address
+		for _, a := range addresses {
+			if a.ID == id {
+				target = a
+			} else if next == nil {
+				next = a
+			}
+		}
+		if target == nil {
+			return errno.ErrAddressNotFound
+		}
+
+		if err := svc.repo.DeleteAddress(ctx, id, userID); err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		if target.IsDefault && next != nil {
+			if err := svc.repo.SetDefault(ctx, next.ID, userID); err != nil {
+				return errno.ErrDatabase.WithRaw(err)
+			}
+		}
+		return nil
+	})
+}
@@ -2,23 +2,29 @@ package app
 
 import (
 	"context"
+	"e-commerce/internal/address"
 	"e-commerce/internal/auth"
+	"e-commerce/internal/cart"
 	"e-commerce/internal/config"
 	"e-commerce/internal/coupon"
 	"e-commerce/internal/middleware"
 	"e-commerce/internal/model"
 	"e-commerce/internal/order"
+	"e-commerce/internal/outbox"
 	"e-commerce/internal/product"
+	"e-commerce/internal/refund"
 	"e-commerce/internal/user"
 	"e-commerce/internal/wallet"
 	"e-commerce/pkg/clog"
 	"e-commerce/pkg/dbconn"
+	"e-commerce/pkg/mailer"
 	"e-commerce/pkg/mq"
 	"e-commerce/pkg/redis"
 	"encoding/json"
 	"fmt"
 
 	"github.com/gin-gonic/gin"
+	amqp "github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/zap"
@@ -78,6 +84,10 @@ func SetupRouter(
 	productSvc *product.Service,
 	orderSvc *order.Service,
 	couponH *coupon.Handler,
+	cartH *cart.Handler,
+	refundH *refund.Handler,
+	addressH *address.Handler,
+	dlqH *order.DeadLetterHandler,
 	logger *zap.Logger,
 	mp *metric.MeterProvider,
 ) (*gin.Engine, error) {
@@ -105,50 +115,109 @@ func SetupRouter(
 	r.GET("/swagger/doc.yaml", swaggerDoc)
 	r.GET("/swagger", swaggerUI)
 
+	// 令牌验签公钥，供其他服务离线验证 AccessToken
+	r.GET("/.well-known/jwks.json", auth.NewHandler(authSvc).JWKS)
+
 	v1 := r.Group("/api/v1")
 	{
 		h := auth.NewHandler(authSvc)
 		accessTokenAuthMiddleware := middleware.AccessTokenAuth(authSvc)
-		refreshTokenAuthMiddleware := middleware.RefreshTokenAuth(authSvc)
+		requireAdmin := middleware.RequireRole(model.UserRoleAdmin)
+		requireSeller := middleware.RequireRole(model.UserRoleSeller, model.UserRoleAdmin)
+		requireEmailVerified := middleware.RequireEmailVerified(userSvc)
+		requireMFA := middleware.RequireMFA(&conf.Auth)
 
 		// 登录接口限流
 		loginGroup := v1.Group("")
 		loginGroup.Use(middleware.RateLimitMiddleware(5, 10))
 		loginGroup.POST("/auth/login", h.Login)
+		loginGroup.POST("/auth/login/mfa", h.LoginMFA)
+
+		// 刷新令牌由 handler 自行校验并轮换，以便识别旧刷新令牌的重用
+		v1.POST("/auth/fetch-access-token", h.FetchAccessToken)
 
 		authGroup := v1.Group("/auth").Use(accessTokenAuthMiddleware)
-		authGroup.POST("/fetch-refresh-token", h.FetchRefreshToken)
 		authGroup.POST("/logout", h.Logout)
-
-		v1.Group("/auth").Use(refreshTokenAuthMiddleware).
-			POST("/fetch-access-token", h.FetchAccessToken)
+		authGroup.GET("/sessions", h.ListSessions)
+		authGroup.DELETE("/sessions", h.RevokeOtherSessions)
+		authGroup.DELETE("/sessions/:sid", h.RevokeSession)
+		authGroup.POST("/mfa/totp/enroll", h.EnrollTOTP)
+		authGroup.POST("/mfa/totp/confirm", h.ConfirmTOTP)
+		authGroup.POST("/mfa/totp/disable", h.DisableTOTP)
 
 		userH := user.NewHandler(userSvc, authSvc)
 		v1.POST("/user/register", userH.Register)
-		v1.Group("/user").Use(accessTokenAuthMiddleware).GET("/good")
+		v1.GET("/user/verify", userH.VerifyEmail)
+		// 找回密码接口限流，防止批量发信和猜测令牌
+		pwdResetGroup := v1.Group("/user/password")
+		pwdResetGroup.Use(middleware.RateLimitMiddleware(5, 10))
+		pwdResetGroup.POST("/forgot", userH.ForgotPassword)
+		pwdResetGroup.POST("/reset", userH.ResetPassword)
+		userGroup := v1.Group("/user").Use(accessTokenAuthMiddleware)
+		// 校验当前密码的接口限流，错误密码同时计入账号的登录防爆破
+		passwordCheckLimit := middleware.RateLimitMiddleware(5, 10)
+		userGroup.GET("/me", userH.GetProfile)
+		userGroup.PATCH("/me", passwordCheckLimit, userH.UpdateProfile)
+		userGroup.DELETE("/me", passwordCheckLimit, userH.DeleteAccount)
+		userGroup.POST("/me/export", userH.ExportAccount)
+		userGroup.POST("/password/change", passwordCheckLimit, userH.ChangePassword)
+		userGroup.POST("/verify/resend", userH.ResendVerification)
 
 		walletH := wallet.NewHandler(walletSvc)
 		walletGroup := v1.Group("/wallet").Use(accessTokenAuthMiddleware)
-		walletGroup.POST("/deposit", walletH.Deposit)
+		walletGroup.GET("", walletH.GetWallet)
+		walletGroup.GET("/logs", walletH.ListLogs)
+		walletGroup.POST("/deposit", requireEmailVerified, walletH.Deposit)
 
 		productH := product.NewHandler(productSvc)
 		productGroup := v1.Group("/product").Use(accessTokenAuthMiddleware)
-		productGroup.POST("/create", productH.CreateProduct)
 		productGroup.GET("/list", productH.ListProducts)
 		productGroup.GET("/:id", productH.GetProduct)
-		productGroup.PATCH("/:id", productH.UpdateProductProperty)
-		productGroup.POST("/:id/status", productH.UpdateProductStatus)
-		productGroup.DELETE("/:id", productH.DeleteProduct)
+		sellerGroup := v1.Group("/product").Use(accessTokenAuthMiddleware, requireSeller, requireMFA)
+		sellerGroup.POST("/create", productH.CreateProduct)
+		sellerGroup.PATCH("/:id", productH.UpdateProductProperty)
+		sellerGroup.POST("/:id/status", productH.UpdateProductStatus)
+		sellerGroup.DELETE("/:id", productH.DeleteProduct)
 
 		orderH := order.NewHandler(orderSvc)
 		orderGroup := v1.Group("/order").Use(accessTokenAuthMiddleware)
-		orderGroup.POST("/create", orderH.CreateOrder)
+		orderGroup.POST("/create", requireEmailVerified, orderH.CreateOrder)
 		orderGroup.GET("/list", orderH.ListOrders)
+		orderGroup.GET("/:id", orderH.GetOrder)
+		orderGroup.POST("/:id/pay", orderH.PayOrder)
+		orderGroup.POST("/:id/cancel", orderH.CancelOrder)
 
 		couponGroup := v1.Group("/coupon").Use(accessTokenAuthMiddleware)
-		couponGroup.POST("/template", couponH.CreateTemplate)
-		couponGroup.POST("/grant", couponH.GrantCoupon)
 		couponGroup.GET("/list", couponH.ListUserCoupons)
+		couponAdminGroup := v1.Group("/coupon").Use(accessTokenAuthMiddleware, requireAdmin, requireMFA)
+		couponAdminGroup.POST("/template", couponH.CreateTemplate)
+		couponAdminGroup.POST("/grant", couponH.GrantCoupon)
+
+		cartGroup := v1.Group("/cart").Use(accessTokenAuthMiddleware)
+		cartGroup.GET("", cartH.ListCart)
+		cartGroup.POST("/items", cartH.AddItem)
+		cartGroup.PATCH("/items/:product_id", cartH.UpdateItem)
+		cartGroup.DELETE("/items/:product_id", cartH.RemoveItem)
+		cartGroup.POST("/checkout", requireEmailVerified, cartH.Checkout)
+
+		addressGroup := v1.Group("/address").Use(accessTokenAuthMiddleware)
+		addressGroup.POST("/create", addressH.CreateAddress)
+		addressGroup.GET("/list", addressH.ListAddresses)
+		addressGroup.GET("/:id", addressH.GetAddress)
+		addressGroup.PATCH("/:id", addressH.UpdateAddress)
+		addressGroup.DELETE("/:id", addressH.DeleteAddress)
+		addressGroup.POST("/:id/default", addressH.SetDefault)
+
+		refundGroup := v1.Group("/refund").Use(accessTokenAuthMiddleware)
+		refundGroup.POST("/create", refundH.CreateRefund)
+		refundGroup.GET("/list", refundH.ListRefunds)
+		refundGroup.POST("/:id/approve", refundH.ApproveRefund)
+		refundGroup.POST("/:id/reject", refundH.RejectRefund)
+
+		adminGroup := v1.Group("/admin").Use(accessTokenAuthMiddleware, requireAdmin)
+		adminGroup.PATCH("/users/:id/role", userH.UpdateRole)
+		adminGroup.GET("/order/dead-letters", dlqH.ListDeadLetters)
+		adminGroup.POST("/order/dead-letters/replay", dlqH.ReplayDeadLetters)
 	}
 	return r, nil
 }
@@ -181,9 +250,17 @@ func Run(ctx context.Context, config config.AppConfig) error {
 			&model.WalletLog{},
 			&model.Product{},
 			&model.Order{},
+			&model.OrderItem{},
+			&model.OrderStatusHistory{},
+			&model.OutboxMessage{},
 			&model.StockChangeLog{},
 			&model.CouponTemplate{},
 			&model.UserCoupon{},
+			&model.RefundRequest{},
+			&model.AuthAuditLog{},
+			&model.UserTOTP{},
+			&model.UserRecoveryCode{},
+			&model.Address{},
 		); err != nil {
 			return fmt.Errorf("数据库 AutoMigrate 失败: %w", err)
 		}
@@ -200,7 +277,7 @@ func Run(ctx context.Context, config config.AppConfig) error {
 		return fmt.Errorf("Redis 初始化失败: %w", err)
 	}
 
-	mqCh, mqCleanup, err := mq.InitMq(ctx, logger, mq.Config{
+	mqManager, mqCleanup, err := mq.InitMq(ctx, logger, mq.Config{
 		User:     config.RabbitMQ.User,
 		Password: config.RabbitMQ.Password,
 		Host:     config.RabbitMQ.Host,
@@ -215,35 +292,70 @@ func Run(ctx context.Context, config config.AppConfig) error {
 	walletSvc := wallet.NewService(walletRepo)
 
 	authRepo := auth.NewRepository(db, rdb, &config.Auth)
-	authSvc := auth.NewService(authRepo, &config.Auth)
+	authKeys, err := auth.NewKeySet(&config.Auth)
+	if err != nil {
+		return fmt.Errorf("加载令牌签名密钥失败: %w", err)
+	}
+	authSvc := auth.NewService(authRepo, &config.Auth, authKeys)
 
 	userMeter := mp.Meter("user_api")
 	userMetrics, err := user.NewMetrics(userMeter)
 	if err != nil {
 		logger.Error("metrics初始化失败", zap.Error(err))
 	}
-	userRepo := user.NewRepository(db)
-	userSvc := user.NewService(userRepo, walletRepo, userMetrics)
-
+	userMailer, err := mailer.New(mailer.Config{
+		Driver:   config.Mail.Driver,
+		From:     config.Mail.From,
+		FilePath: config.Mail.FilePath,
+	})
+	if err != nil {
+		return fmt.Errorf("邮件发送初始化失败: %w", err)
+	}
 	productRepo := product.NewRepository(db)
 	productSvc := product.NewService(db, productRepo)
 
-	orderRepo := order.NewRepository(db, mqCh, &config.OrderMQ)
-	if err := orderRepo.SetupMQ(&config.OrderMQ); err != nil {
+	orderRepo := order.NewRepository(db, &config.OrderMQ)
+	if err := mqManager.Register("order-topology", orderRepo.SetupMQ); err != nil {
 		return fmt.Errorf("初始化 order MQ 失败: %w", err)
 	}
 	couponRepo := coupon.NewRepository(db)
 	couponSvc := coupon.NewService(db, couponRepo)
 	couponH := coupon.NewHandler(couponSvc)
 
-	orderSvc := order.NewService(db, orderRepo, productRepo, couponRepo)
+	addressRepo := address.NewRepository(db)
+	addressH := address.NewHandler(address.NewService(db, addressRepo))
 
-	orderMqHandler := order.NewMqHandler(orderSvc)
-	if err := orderMqHandler.ListenTimeout(ctx, mqCh, config.OrderMQ.ConsumerQueue); err != nil {
+	orderSvc := order.NewService(db, orderRepo, productRepo, couponRepo, walletRepo, addressRepo, &config.Order)
+
+	userRepo := user.NewRepository(db, rdb)
+	userSvc := user.NewService(userRepo, walletRepo, couponRepo, orderRepo, addressRepo, authSvc, userMetrics, userMailer, &config.User)
+
+	cartRepo := cart.NewRepository(rdb)
+	cartH := cart.NewHandler(cart.NewService(cartRepo, productRepo, orderSvc))
+
+	refundRepo := refund.NewRepository(db)
+	refundH := refund.NewHandler(refund.NewService(db, refundRepo, orderRepo, productRepo, walletRepo))
+
+	// 拓扑、消费者、发布者均注册到 mqManager，断线重连后按注册顺序自动恢复
+	orderMqHandler := order.NewMqHandler(orderSvc, &config.OrderMQ)
+	if err := mqManager.Register("order-timeout-consumer", func(ch *amqp.Channel) error {
+		return orderMqHandler.ListenTimeout(ctx, ch, config.OrderMQ.ConsumerQueue)
+	}); err != nil {
 		return fmt.Errorf("启动订单消费者失败: %w", err)
 	}
 
-	r, err := SetupRouter(&config, authSvc, userSvc, walletSvc, productSvc, orderSvc, couponH, logger, &mp)
+	outboxRelay := outbox.NewRelay(outbox.NewRepository(db))
+	if err := mqManager.Register("outbox-publisher", outboxRelay.Attach); err != nil {
+		return fmt.Errorf("启动 outbox relay 失败: %w", err)
+	}
+	outboxRelay.Start(ctx)
+
+	dlq := order.NewDeadLetterQueue(&config.OrderMQ)
+	if err := mqManager.Register("order-dead-letter", dlq.Attach); err != nil {
+		return fmt.Errorf("初始化订单死信队列失败: %w", err)
+	}
+
+	r, err := SetupRouter(&config, authSvc, userSvc, walletSvc, productSvc, orderSvc, couponH, cartH, refundH, addressH, order.NewDeadLetterHandler(dlq), logger, &mp)
 	if err != nil {
 		return fmt.Errorf("初始化路由失败: %w", err)
 	}
@@ -251,7 +363,7 @@ func Run(ctx context.Context, config config.AppConfig) error {
 	// 注入 DB/Redis/MQ 用于健康检查
 	healthDB = db
 	healthRDB = rdb
-	healthMQCh = mqCh
+	healthMQ = mqManager
 
 	addr := fmt.Sprintf("0.0.0.0:%d", config.App.Port)
 	if config.App.SSL {
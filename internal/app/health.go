@@ -2,11 +2,11 @@ package app
 
 import (
 	"context"
+	"e-commerce/pkg/mq"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
@@ -14,7 +14,7 @@ import (
 var (
 	healthDB   *gorm.DB
 	healthRDB  *redis.Client
-	healthMQCh *amqp.Channel
+	healthMQ   *mq.Manager
 )
 
 // HealthHandler 存活检查（K8s liveness probe）
@@ -50,7 +50,7 @@ func ReadyHandler(c *gin.Context) {
 		checks["redis"] = "not_initialized"
 	}
 
-	if healthMQCh != nil && !healthMQCh.IsClosed() {
+	if healthMQ != nil && healthMQ.IsReady() {
 		checks["rabbitmq"] = "ok"
 	} else {
 		checks["rabbitmq"] = "unhealthy"
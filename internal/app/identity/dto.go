@@ -2,6 +2,7 @@ package identity
 
 import (
 	"context"
+	"e-commerce/internal/model"
 
 	"github.com/google/uuid"
 )
@@ -13,6 +14,10 @@ var userIdentityCtxKey = userIdentityCtx{}
 type AccountInfo struct {
 	AccountId uuid.UUID `json:"account_id"`
 	SessionID string    `json:"session_id"`
+	// Role 签发令牌时的用户角色，刷新令牌时从数据库重新读取
+	Role model.UserRole `json:"role"`
+	// MFA 会话登录时是否通过了两步验证
+	MFA bool `json:"mfa,omitempty"`
 }
 
 func SetAccountInfo(ctx context.Context, data *AccountInfo) context.Context {
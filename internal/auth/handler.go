@@ -4,6 +4,10 @@ import (
 	"e-commerce/internal/app/identity"
 	"e-commerce/internal/pkg/response"
 	"e-commerce/pkg/errno"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -17,6 +21,29 @@ type LoginDTO struct {
 	Password string `json:"password" binding:"required"`
 }
 
+type LoginMFADTO struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TOTPCodeDTO struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type UriWithSessionID struct {
+	SessionID string `uri:"sid" binding:"required"`
+}
+
+type SessionItem struct {
+	SessionID       string `json:"session_id"`
+	CreatedAt       string `json:"created_at"`
+	LastRefreshedAt string `json:"last_refreshed_at"`
+	IP              string `json:"ip"`
+	UserAgent       string `json:"user_agent"`
+	// Current 是否为发起请求的会话
+	Current bool `json:"current"`
+}
+
 func NewHandler(authSvc *Service) *Handler {
 	return &Handler{authSvc: authSvc}
 }
@@ -29,29 +56,79 @@ func (h *Handler) Login(c *gin.Context) {
 		response.WriteInvalidParam(c, err)
 		return
 	}
-	tokenPair, err := h.authSvc.Login(ctx, &LoginInput{
-		Email:    loginDTO.Email,
-		Password: loginDTO.Password,
+	result, err := h.authSvc.Login(ctx, &LoginInput{
+		Email:     loginDTO.Email,
+		Password:  loginDTO.Password,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
 	})
-	response.Write(c, err, tokenPair)
+	writeLoginResponse(c, err, result)
+}
+
+// writeLoginResponse 登录（或两步验证码校验）被锁定时设置 Retry-After 响应头
+func writeLoginResponse(c *gin.Context, err error, data interface{}) {
+	var e *errno.Errno
+	if errors.As(err, &e) {
+		if locked, ok := e.Data.(LoginLockedData); ok {
+			c.Header("Retry-After", strconv.Itoa(locked.RetryAfterSeconds))
+		}
+	}
+	response.Write(c, err, data)
 }
 
+// LoginMFA 登录第二步：提交 mfa_token 与验证码（或恢复码）换取令牌对
+func (h *Handler) LoginMFA(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var dto LoginMFADTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	tokenPair, err := h.authSvc.LoginMFA(ctx, &MFALoginInput{
+		MFAToken:  dto.MFAToken,
+		Code:      dto.Code,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	writeLoginResponse(c, err, tokenPair)
+}
+
+// FetchAccessToken 使用刷新令牌换取新的令牌对（刷新令牌轮换，旧刷新令牌立即失效）
 func (h *Handler) FetchAccessToken(c *gin.Context) {
 	ctx := c.Request.Context()
 
+	tokenPair, err := h.authSvc.RefreshTokens(ctx, c.GetHeader("Authorization"), SessionMeta{
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, tokenPair)
+}
+
+func (h *Handler) Logout(c *gin.Context) {
+	ctx := c.Request.Context()
+
 	accountInfo := identity.GetAccountInfo(ctx)
 	if accountInfo == nil {
 		response.Write(c, errno.ErrGetAccountInfo, nil)
 		return
 	}
 
-	at, err := h.authSvc.fetchAccessToken(ctx, accountInfo)
-	response.Write(c, err, gin.H{
-		"access_token": at,
-	})
+	err := h.authSvc.logout(ctx, accountInfo)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	response.Write(c, errno.OK, nil)
 }
 
-func (h *Handler) FetchRefreshToken(c *gin.Context) {
+// ListSessions 查看已登录设备
+func (h *Handler) ListSessions(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	accountInfo := identity.GetAccountInfo(ctx)
@@ -60,18 +137,19 @@ func (h *Handler) FetchRefreshToken(c *gin.Context) {
 		return
 	}
 
-	rt, err := h.authSvc.fetchRefreshToken(ctx, accountInfo)
+	sessions, err := h.authSvc.ListSessions(ctx, accountInfo)
 	if err != nil {
 		response.Write(c, err, nil)
 		return
 	}
 
 	response.Write(c, nil, gin.H{
-		"refresh_token": rt,
+		"sessions": FormatSessions(sessions, accountInfo.SessionID),
 	})
 }
 
-func (h *Handler) Logout(c *gin.Context) {
+// RevokeSession 注销指定设备
+func (h *Handler) RevokeSession(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	accountInfo := identity.GetAccountInfo(ctx)
@@ -80,11 +158,121 @@ func (h *Handler) Logout(c *gin.Context) {
 		return
 	}
 
-	err := h.authSvc.logout(ctx, accountInfo)
+	var uri UriWithSessionID
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.authSvc.RevokeSession(ctx, accountInfo, uri.SessionID); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// RevokeOtherSessions 注销除当前设备外的所有设备
+func (h *Handler) RevokeOtherSessions(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	revoked, err := h.authSvc.RevokeOtherSessions(ctx, accountInfo)
 	if err != nil {
 		response.Write(c, err, nil)
 		return
 	}
+	response.Write(c, nil, gin.H{
+		"revoked": revoked,
+	})
+}
 
-	response.Write(c, errno.OK, nil)
+// EnrollTOTP 绑定验证器：返回密钥、otpauth URI 与恢复码
+func (h *Handler) EnrollTOTP(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	enrollment, err := h.authSvc.EnrollTOTP(ctx, accountInfo)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, enrollment)
+}
+
+// ConfirmTOTP 提交验证码确认绑定，开启两步验证
+func (h *Handler) ConfirmTOTP(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var dto TOTPCodeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	err := h.authSvc.ConfirmTOTP(ctx, accountInfo, dto.Code)
+	writeLoginResponse(c, err, nil)
+}
+
+// DisableTOTP 提交验证码或恢复码关闭两步验证
+func (h *Handler) DisableTOTP(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var dto TOTPCodeDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	err := h.authSvc.DisableTOTP(ctx, accountInfo, dto.Code)
+	writeLoginResponse(c, err, nil)
+}
+
+// formatSessionTime 旧会话缺少时间字段时返回空字符串
+func formatSessionTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// JWKS 公开令牌验签公钥（RFC 7517），按标准格式直接返回，不使用统一响应包装
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.authSvc.JWKS())
+}
+
+// FormatSessions 转换为接口返回的会话列表，currentSessionID 对应的会话标记为当前会话
+func FormatSessions(sessions []SessionInfo, currentSessionID string) []SessionItem {
+	items := make([]SessionItem, 0, len(sessions))
+	for _, s := range sessions {
+		items = append(items, SessionItem{
+			SessionID:       s.SessionID,
+			CreatedAt:       formatSessionTime(s.CreatedAt),
+			LastRefreshedAt: formatSessionTime(s.RefreshedAt),
+			IP:              s.IP,
+			UserAgent:       s.UserAgent,
+			Current:         s.SessionID == currentSessionID,
+		})
+	}
+	return items
 }
@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"e-commerce/internal/config"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的签名算法
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+)
+
+var errUnknownKid = errors.New("unknown signing key id")
+
+type signingKey struct {
+	kid    string
+	method jwt.SigningMethod
+	// signKey 用于签名：*rsa.PrivateKey / ed25519.PrivateKey / []byte
+	signKey interface{}
+	// verifyKey 用于验签：*rsa.PublicKey / ed25519.PublicKey / []byte
+	verifyKey interface{}
+}
+
+// KeySet 令牌签名密钥集合：active 负责签发，集合内所有密钥都可验签。
+// 轮换时先加入新密钥并切换 active_kid，旧密钥保留到其签发的令牌全部过期后再移除
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// NewKeySet 按配置加载签名密钥；未配置 signing_keys 时使用 token_secret 的 HS256（无 kid），
+// 配置了 signing_keys 且 token_secret 非空时，无 kid 的旧令牌仍按 token_secret 验签，便于从 HS256 平滑迁移
+func NewKeySet(cfg *config.AuthSection) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+
+	if cfg.TokenSecret != "" {
+		secret := []byte(cfg.TokenSecret)
+		ks.keys[""] = &signingKey{
+			method:    jwt.SigningMethodHS256,
+			signKey:   secret,
+			verifyKey: secret,
+		}
+	}
+
+	for _, kc := range cfg.SigningKeys {
+		key, err := loadSigningKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("load signing key %q: %w", kc.Kid, err)
+		}
+		if _, ok := ks.keys[key.kid]; ok {
+			return nil, fmt.Errorf("duplicate signing key id %q", key.kid)
+		}
+		ks.keys[key.kid] = key
+	}
+
+	active, ok := ks.keys[cfg.ActiveKid]
+	if !ok {
+		return nil, fmt.Errorf("active signing key %q not found", cfg.ActiveKid)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func loadSigningKey(kc config.SigningKeyConfig) (*signingKey, error) {
+	if kc.Kid == "" {
+		return nil, errors.New("kid required")
+	}
+	pemData := []byte(kc.PrivateKey)
+	if len(pemData) == 0 && kc.PrivateKeyFile != "" {
+		data, err := os.ReadFile(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pemData = data
+	}
+	if len(pemData) == 0 {
+		return nil, errors.New("private_key or private_key_file required")
+	}
+
+	switch kc.Alg {
+	case AlgRS256:
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		return &signingKey{kid: kc.Kid, method: jwt.SigningMethodRS256, signKey: priv, verifyKey: &priv.PublicKey}, nil
+	case AlgEdDSA:
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(pemData)
+		if err != nil {
+			return nil, err
+		}
+		edPriv, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 private key")
+		}
+		return &signingKey{kid: kc.Kid, method: jwt.SigningMethodEdDSA, signKey: edPriv, verifyKey: edPriv.Public()}, nil
+	case AlgHS256:
+		// HS256 时 private_key 即共享密钥，不会出现在 JWKS 中
+		return &signingKey{kid: kc.Kid, method: jwt.SigningMethodHS256, signKey: pemData, verifyKey: pemData}, nil
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", kc.Alg)
+	}
+}
+
+// Sign 使用当前 active 密钥签发令牌，kid 写入令牌头
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	if ks.active.kid != "" {
+		token.Header["kid"] = ks.active.kid
+	}
+	return token.SignedString(ks.active.signKey)
+}
+
+// Keyfunc 按令牌头中的 kid 选择验签密钥，并要求签名算法与密钥一致，防止算法混淆
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, errUnknownKid
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for kid %q", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK 公钥的 JSON Web Key 表示（RFC 7517 / RFC 8037）
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 导出所有非对称密钥的公钥，HS256 共享密钥不对外公开
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		if jwk, ok := toJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].Kid < set.Keys[j].Kid
+	})
+	return set
+}
+
+func toJWK(key *signingKey) (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch pub := key.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: key.kid,
+			Use: "sig",
+			Alg: AlgRS256,
+			N:   enc.EncodeToString(pub.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: key.kid,
+			Use: "sig",
+			Alg: AlgEdDSA,
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(pub),
+		}, true
+	}
+	return JWK{}, false
+}
@@ -0,0 +1,288 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// recoveryCodeCount 每次绑定生成的恢复码数量
+const recoveryCodeCount = 10
+
+type MFALoginInput struct {
+	MFAToken string
+	// Code 验证器 App 中的 6 位验证码，或一个未使用的恢复码
+	Code      string
+	IP        string
+	UserAgent string
+}
+
+// TOTPEnrollment 绑定验证器时返回的信息，恢复码只在此时以明文返回一次
+type TOTPEnrollment struct {
+	Secret        string   `json:"secret"`
+	OTPAuthURI    string   `json:"otpauth_uri"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// genRecoveryCode 生成形如 abcde-fghij 的恢复码
+func genRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate recovery code fail: %w", err)
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode 忽略大小写、空格和连字符后取 SHA-256 摘要
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (svc *Service) totpEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	totp, err := svc.repo.FindTOTP(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, errno.ErrInternalServer.WithRaw(err)
+	}
+	return totp.EnabledAt != nil, nil
+}
+
+// createMFAPending 签发 mfa_token 并在 Redis 中记录待验证的登录
+func (svc *Service) createMFAPending(ctx context.Context, user *model.User) (string, error) {
+	jti, err := generateRandomString(16)
+	if err != nil {
+		return "", fmt.Errorf("generate mfa token jti fail: %w", err)
+	}
+	token, err := svc.keys.Sign(TokenPayload{
+		AccountInfo: identity.AccountInfo{
+			AccountId: user.ID,
+			Role:      user.Role,
+		},
+		TokenType: MFAPendingToken,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(svc.config.MFA.PendingExpire)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("generate mfa token error: %w", err)
+	}
+	if err := svc.repo.createMFAPending(ctx, jti, user.Email, svc.config.MFA.PendingExpire); err != nil {
+		return "", errno.ErrInternalServer.WithRaw(err)
+	}
+	return token, nil
+}
+
+// verifySecondFactor 校验 TOTP 验证码或恢复码，验证码与恢复码均只能使用一次
+func (svc *Service) verifySecondFactor(ctx context.Context, totp *model.UserTOTP, code string) (bool, error) {
+	if step, ok := validateTOTP(totp.Secret, code, time.Now(), totp.LastUsedStep); ok {
+		used, err := svc.repo.useTOTPStep(ctx, totp.UserID, step)
+		if err != nil {
+			return false, errno.ErrInternalServer.WithRaw(err)
+		}
+		return used, nil
+	}
+	used, err := svc.repo.useRecoveryCode(ctx, totp.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return false, errno.ErrInternalServer.WithRaw(err)
+	}
+	return used, nil
+}
+
+// LoginMFA 提交两步验证码完成登录；验证码错误计入登录防爆破，单个 mfa_token 错误次数过多后需重新登录
+func (svc *Service) LoginMFA(ctx context.Context, input *MFALoginInput) (*TokenPair, error) {
+	claims, err := svc.parseToken(input.MFAToken, MFAPendingToken)
+	if err != nil {
+		return nil, errno.ErrAuthMFATokenInvalid.WithRaw(err)
+	}
+	email, err := svc.repo.getMFAPendingEmail(ctx, claims.ID)
+	if errors.Is(err, RepoErrMFAPendingNotFound) {
+		return nil, errno.ErrAuthMFATokenInvalid
+	} else if err != nil {
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+
+	subjects := svc.loginSubjects(&LoginInput{Email: email, IP: input.IP})
+	if err := svc.checkLoginLock(ctx, subjects); err != nil {
+		return nil, err
+	}
+
+	totp, err := svc.repo.FindTOTP(ctx, claims.AccountId)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && totp.EnabledAt == nil) {
+		// 登录后两步验证已被关闭
+		return nil, errno.ErrAuthMFATokenInvalid
+	} else if err != nil {
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+
+	ok, err := svc.verifySecondFactor(ctx, totp, input.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := svc.repo.recordMFAPendingFailure(ctx, claims.ID, svc.config.MFA.MaxAttempts); err != nil {
+			return nil, errno.ErrInternalServer.WithRaw(err)
+		}
+		return nil, svc.recordMFACodeFailure(ctx, subjects)
+	}
+
+	consumed, err := svc.repo.consumeMFAPending(ctx, claims.ID)
+	if err != nil {
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	if !consumed {
+		return nil, errno.ErrAuthMFATokenInvalid
+	}
+	if err := svc.resetLoginFailures(ctx, subjects); err != nil {
+		return nil, err
+	}
+
+	user, err := svc.repo.FindUserByID(ctx, claims.AccountId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrAuthMFATokenInvalid
+		}
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	return svc.issueSession(ctx, user, true, SessionMeta{
+		IP:        input.IP,
+		UserAgent: input.UserAgent,
+	})
+}
+
+// EnrollTOTP 生成新的 TOTP 密钥和恢复码，调用 ConfirmTOTP 提交验证码后才生效
+func (svc *Service) EnrollTOTP(ctx context.Context, accountInfo *identity.AccountInfo) (*TOTPEnrollment, error) {
+	user, err := svc.repo.FindUserByID(ctx, accountInfo.AccountId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrNotFoundRecord
+		}
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := genRecoveryCode()
+		if err != nil {
+			return nil, errno.ErrInternalServer.WithRaw(err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := svc.repo.saveTOTPEnrollment(ctx, user.ID, secret, hashes); err != nil {
+		if errors.Is(err, RepoErrTOTPAlreadyEnabled) {
+			return nil, errno.ErrAuthMFAAlreadyEnabled
+		}
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	return &TOTPEnrollment{
+		Secret:        secret,
+		OTPAuthURI:    totpURI(svc.config.MFA.Issuer, user.Email, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// ConfirmTOTP 提交验证器中的验证码确认绑定，之后登录需要两步验证；
+// 验证码错误计入账号的登录防爆破，开启后注销当前会话以外仅凭密码登录的会话
+func (svc *Service) ConfirmTOTP(ctx context.Context, accountInfo *identity.AccountInfo, code string) error {
+	subjects, err := svc.accountSubjects(ctx, accountInfo.AccountId)
+	if err != nil {
+		return err
+	}
+	if err := svc.checkLoginLock(ctx, subjects); err != nil {
+		return err
+	}
+
+	totp, err := svc.repo.FindTOTP(ctx, accountInfo.AccountId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errno.ErrAuthMFANotEnrolled
+	} else if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if totp.EnabledAt != nil {
+		return errno.ErrAuthMFAAlreadyEnabled
+	}
+
+	step, ok := validateTOTP(totp.Secret, code, time.Now(), totp.LastUsedStep)
+	if !ok {
+		return svc.recordMFACodeFailure(ctx, subjects)
+	}
+	enabled, err := svc.repo.enableTOTP(ctx, accountInfo.AccountId, step)
+	if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if !enabled {
+		return errno.ErrAuthMFAAlreadyEnabled
+	}
+	if _, err := svc.RevokeOtherSessions(ctx, accountInfo); err != nil {
+		return err
+	}
+	return nil
+}
+
+// DisableTOTP 提交验证码或恢复码关闭两步验证，仅限通过两步验证登录的会话；验证码错误计入账号的登录防爆破
+func (svc *Service) DisableTOTP(ctx context.Context, accountInfo *identity.AccountInfo, code string) error {
+	if !accountInfo.MFA {
+		return errno.ErrAuthMFARequired
+	}
+	subjects, err := svc.accountSubjects(ctx, accountInfo.AccountId)
+	if err != nil {
+		return err
+	}
+	if err := svc.checkLoginLock(ctx, subjects); err != nil {
+		return err
+	}
+
+	totp, err := svc.repo.FindTOTP(ctx, accountInfo.AccountId)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && totp.EnabledAt == nil) {
+		return errno.ErrAuthMFANotEnrolled
+	} else if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+
+	ok, err := svc.verifySecondFactor(ctx, totp, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return svc.recordMFACodeFailure(ctx, subjects)
+	}
+	if err := svc.repo.deleteTOTP(ctx, accountInfo.AccountId); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	return nil
+}
+
+// recordMFACodeFailure 验证码错误计入登录失败，触发锁定时返回锁定错误，否则返回验证码错误
+func (svc *Service) recordMFACodeFailure(ctx context.Context, subjects []loginSubject) error {
+	if err := svc.recordLoginFailure(ctx, subjects); !errors.Is(err, errno.ErrUserNotFound) {
+		return err
+	}
+	return errno.ErrAuthMFACodeInvalid
+}
@@ -6,6 +6,7 @@ import (
 	"e-commerce/internal/model"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -14,37 +15,91 @@ import (
 )
 
 var (
-	RepoErrSessionNotFound  = errors.New("session not found in redis")
-	RepoErrDatabaseInternal = errors.New("database internal error")
+	RepoErrSessionNotFound     = errors.New("session not found in redis")
+	RepoErrDatabaseInternal    = errors.New("database internal error")
+	RepoErrSessionLimitReached = errors.New("session limit reached")
+	RepoErrRefreshTokenReused  = errors.New("refresh token already rotated")
+	RepoErrMFAPendingNotFound  = errors.New("mfa pending login not found")
+	RepoErrTOTPAlreadyEnabled  = errors.New("totp already enabled")
 )
 
 var (
 	sidContainPrefix = "ident:sess"
-	userSidsPrefix   = "ident:u_sess"
+	// userSidsPrefix 用户会话有序集合，score 为登录时间（毫秒）
+	userSidsPrefix = "ident:u_sessions"
+	// legacyUserSidsPrefix 旧版本使用的会话 ID 集合，登录时迁移到有序集合后删除
+	legacyUserSidsPrefix = "ident:u_sess"
+	// evictedSidPrefix 因超出会话上限被踢下的会话标记，保留到原刷新令牌过期
+	evictedSidPrefix = "ident:sess_evicted"
+	// 登录失败计数、锁定标记与连续锁定次数，后缀为 <scope>:<value>，scope 为 email 或 ip
+	loginFailPrefix     = "ident:login_fail"
+	loginLockPrefix     = "ident:login_lock"
+	loginLockoutsPrefix = "ident:login_lockouts"
+	// mfaPendingPrefix 密码校验通过、等待两步验证的登录，哈希保存 email 与已失败次数
+	mfaPendingPrefix = "ident:mfa_pending"
 )
 
-// 创建一个session
+// 登录失败 scope
+const (
+	loginScopeEmail = "email"
+	loginScopeIP    = "ip"
+)
+
+// 创建一个session，超出会话上限时按策略踢下最早登录的会话或拒绝登录（返回 0）
 var createSessionScript = redis.NewScript(`
 	local sid_contain_key = KEYS[1]
 	local user_sid_key = KEYS[2]
+	local legacy_sid_key = KEYS[3]
 	
 	local access_token    = ARGV[1]
     local refresh_token   = ARGV[2]
     local expire_seconds  = ARGV[3]
     local session_id      = ARGV[4]
+    local now             = ARGV[5]
+    local ip              = ARGV[6]
+    local user_agent      = ARGV[7]
+    local now_ms          = ARGV[8]
+    local max_sessions    = tonumber(ARGV[9])
+    local policy          = ARGV[10]
 
-    redis.call("HSET", sid_contain_key, "at", access_token, "rt", refresh_token)
-    redis.call("EXPIRE", sid_contain_key, expire_seconds)
-	
-	redis.call("SADD", user_sid_key, session_id)
-	local sids = redis.call("SMEMBERS", user_sid_key)
-	if #sids > 5 then
-		for _, sid in ipairs(sids) do
-			if redis.call("EXISTS", "ident:sess:" .. sid) == 0 then
-				redis.call("SREM", user_sid_key, sid)
+	-- 旧版本的会话集合迁移到有序集合
+	if redis.call("EXISTS", legacy_sid_key) == 1 then
+		for _, sid in ipairs(redis.call("SMEMBERS", legacy_sid_key)) do
+			local created_at = redis.call("HGET", "ident:sess:" .. sid, "created_at")
+			if created_at then
+				redis.call("ZADD", user_sid_key, tonumber(created_at) * 1000, sid)
+			elseif redis.call("EXISTS", "ident:sess:" .. sid) == 1 then
+				redis.call("ZADD", user_sid_key, 0, sid)
+			end
+		end
+		redis.call("DEL", legacy_sid_key)
+	end
+
+	-- 清理已过期的会话
+	for _, sid in ipairs(redis.call("ZRANGE", user_sid_key, 0, -1)) do
+		if redis.call("EXISTS", "ident:sess:" .. sid) == 0 then
+			redis.call("ZREM", user_sid_key, sid)
+		end
+	end
+
+	if max_sessions > 0 then
+		local count = redis.call("ZCARD", user_sid_key)
+		if count >= max_sessions then
+			if policy == "reject" then return 0 end
+			for _, sid in ipairs(redis.call("ZRANGE", user_sid_key, 0, count - max_sessions)) do
+				redis.call("DEL", "ident:sess:" .. sid)
+				redis.call("SET", "ident:sess_evicted:" .. sid, 1, "EX", expire_seconds)
+				redis.call("ZREM", user_sid_key, sid)
 			end
 		end
 	end
+
+    redis.call("HSET", sid_contain_key, "at", access_token, "rt", refresh_token,
+        "created_at", now, "refreshed_at", now, "ip", ip, "ua", user_agent)
+    redis.call("EXPIRE", sid_contain_key, expire_seconds)
+
+	redis.call("ZADD", user_sid_key, now_ms, session_id)
+	redis.call("EXPIRE", user_sid_key, expire_seconds)
 	return 1
 `)
 
@@ -55,32 +110,57 @@ var deleteSessionScript = redis.NewScript(`
 	local remove_sid = ARGV[1]
 
 	redis.call("DEL", sid_contain_key)
-	redis.call("SREM", user_sid_key, remove_sid)
+	redis.call("ZREM", user_sid_key, remove_sid)
 	return 1
 `)
 
-var setAccessTokenScript = redis.NewScript(`
+// 轮换令牌：仅当提交的刷新令牌与会话中当前的刷新令牌一致时写入新令牌对
+// 返回 1 成功，0 会话不存在，-1 提交的是已轮换的旧刷新令牌
+var rotateTokensScript = redis.NewScript(`
 	local sid_contain_key = KEYS[1]
 
-	local new_access_token = ARGV[1]
-	
-	if redis.call("EXISTS", sid_contain_key) == 0 then return 0 end
-	redis.call("HSET", sid_contain_key, "at", new_access_token)
-	return 1
-`)
-var setRefreshTokenScript = redis.NewScript(`
-	local sid_contain_key = KEYS[1]
+	local old_refresh_token = ARGV[1]
+	local new_access_token = ARGV[2]
+	local new_refresh_token = ARGV[3]
+	local expire_seconds = ARGV[4]
+	local now = ARGV[5]
 
-	local new_refresh_token = ARGV[1]
-	local expire_seconds = ARGV[2]
+	local current = redis.call("HGET", sid_contain_key, "rt")
+	if not current then return 0 end
+	if current ~= old_refresh_token then return -1 end
 
-	if redis.call("EXISTS", sid_contain_key) == 0 then return 0 end
-	
-	redis.call("HSET", sid_contain_key, "rt", new_refresh_token)
+	redis.call("HSET", sid_contain_key, "at", new_access_token, "rt", new_refresh_token, "refreshed_at", now)
 	redis.call("EXPIRE", sid_contain_key, expire_seconds)
 	return 1
 `)
 
+// 记录一次登录失败，达到阈值时加锁并清零计数，返回锁定时长（毫秒），未锁定返回 0
+// 连续锁定次数在 lockout_max 的两倍时间内无新锁定后清零
+var recordLoginFailureScript = redis.NewScript(`
+	local fail_key = KEYS[1]
+	local lock_key = KEYS[2]
+	local lockouts_key = KEYS[3]
+
+	local max_failures = tonumber(ARGV[1])
+	local window_ms = tonumber(ARGV[2])
+	local base_ms = tonumber(ARGV[3])
+	local max_ms = tonumber(ARGV[4])
+
+	local failures = redis.call("INCR", fail_key)
+	if failures == 1 then
+		redis.call("PEXPIRE", fail_key, window_ms)
+	end
+	if failures < max_failures then return 0 end
+
+	local lockouts = redis.call("INCR", lockouts_key)
+	redis.call("PEXPIRE", lockouts_key, max_ms * 2)
+	local lock_ms = base_ms * math.pow(2, lockouts - 1)
+	if lock_ms > max_ms then lock_ms = max_ms end
+	redis.call("SET", lock_key, 1, "PX", lock_ms)
+	redis.call("DEL", fail_key)
+	return lock_ms
+`)
+
 type Repository struct {
 	db     *gorm.DB
 	rdb    *redis.Client
@@ -97,22 +177,64 @@ func genSidContainKey(sessionId string) string {
 func genUserSidsKey(accountId uuid.UUID) string {
 	return fmt.Sprintf("%s:%s", userSidsPrefix, accountId)
 }
+func genLegacyUserSidsKey(accountId uuid.UUID) string {
+	return fmt.Sprintf("%s:%s", legacyUserSidsPrefix, accountId)
+}
+func genEvictedSidKey(sessionId string) string {
+	return fmt.Sprintf("%s:%s", evictedSidPrefix, sessionId)
+}
+
+// SessionMeta 登录时记录的设备信息
+type SessionMeta struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionInfo 会话哈希中保存的元数据
+type SessionInfo struct {
+	SessionID   string
+	CreatedAt   time.Time
+	RefreshedAt time.Time
+	IP          string
+	UserAgent   string
+}
 
-// createSession 为用户创建一个session(使用lua脚本)
-func (repo *Repository) createSession(ctx context.Context, accountId uuid.UUID, sessionId string, accessToken string, refreshToken string, expireSeconds time.Duration) error {
+// createSession 为用户创建一个session(使用lua脚本)，按拒绝策略达到会话上限时返回 RepoErrSessionLimitReached
+func (repo *Repository) createSession(ctx context.Context, accountId uuid.UUID, sessionId string, accessToken string, refreshToken string, expireSeconds time.Duration, meta SessionMeta) error {
+	now := time.Now()
 	cmd := createSessionScript.Run(
 		ctx,
 		repo.rdb,
 		[]string{
 			genSidContainKey(sessionId),
 			genUserSidsKey(accountId),
+			genLegacyUserSidsKey(accountId),
 		},
 		accessToken,
 		refreshToken,
 		int(expireSeconds.Seconds()),
 		sessionId,
+		now.Unix(),
+		meta.IP,
+		meta.UserAgent,
+		now.UnixMilli(),
+		repo.config.MaxSessions,
+		repo.config.SessionLimitPolicy,
 	)
-	return cmd.Err()
+	result, err := cmd.Int()
+	if err != nil {
+		return fmt.Errorf("%w: %v", RepoErrDatabaseInternal, err)
+	}
+	if result == 0 {
+		return RepoErrSessionLimitReached
+	}
+	return nil
+}
+
+// isSessionEvicted 判断会话是否因超出会话上限被踢下
+func (repo *Repository) isSessionEvicted(ctx context.Context, sessionId string) (bool, error) {
+	n, err := repo.rdb.Exists(ctx, genEvictedSidKey(sessionId)).Result()
+	return n > 0, err
 }
 
 func (repo *Repository) getRefreshToken(ctx context.Context, sessionId string) (string, error) {
@@ -133,54 +255,290 @@ func (repo *Repository) FindUserByEmail(ctx context.Context, email string) (*mod
 	return &user, result.Error
 }
 
-func (repo *Repository) SetAccessToken(ctx context.Context, accountId uuid.UUID, sessionId string, accessToken string) error {
-	cmd := setAccessTokenScript.Run(
+func (repo *Repository) FindUserByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	var user model.User
+	result := repo.db.WithContext(ctx).Where("id = ?", id).First(&user)
+	return &user, result.Error
+}
+
+// rotateTokens 用新令牌对替换会话中的令牌，oldRefreshToken 已被轮换时返回 RepoErrRefreshTokenReused
+func (repo *Repository) rotateTokens(ctx context.Context, sessionId string, oldRefreshToken, accessToken, refreshToken string) error {
+	cmd := rotateTokensScript.Run(
 		ctx,
 		repo.rdb,
 		[]string{
 			genSidContainKey(sessionId),
 		},
+		oldRefreshToken,
 		accessToken,
+		refreshToken,
+		int(repo.config.RefreshTokenExpire.Seconds()),
+		time.Now().Unix(),
 	)
 	result, err := cmd.Int()
 	if err != nil {
-		return fmt.Errorf("%w: %v", RepoErrDatabaseInternal, cmd.Err())
+		return fmt.Errorf("%w: %v", RepoErrDatabaseInternal, err)
 	}
-	if result == 0 {
+	switch result {
+	case 0:
 		return RepoErrSessionNotFound
+	case -1:
+		return RepoErrRefreshTokenReused
 	}
 	return nil
 }
 
-func (repo *Repository) SetRefreshToken(ctx context.Context, accountId uuid.UUID, sessionId string, refreshToken string) error {
-	cmd := setRefreshTokenScript.Run(
+// CreateAuditLog 写入认证审计日志
+func (repo *Repository) CreateAuditLog(ctx context.Context, log *model.AuthAuditLog) error {
+	return repo.db.WithContext(ctx).Create(log).Error
+}
+
+func (repo *Repository) DelSession(ctx context.Context, accountId uuid.UUID, sessionId string) error {
+	cmd := deleteSessionScript.Run(
 		ctx,
 		repo.rdb,
 		[]string{
 			genSidContainKey(sessionId),
+			genUserSidsKey(accountId),
 		},
-		refreshToken,
-		repo.config.RefreshTokenExpire,
+		sessionId,
 	)
-	result, err := cmd.Int()
+	return cmd.Err()
+}
+
+// HasSession 判断会话是否属于该用户
+func (repo *Repository) HasSession(ctx context.Context, accountId uuid.UUID, sessionId string) (bool, error) {
+	err := repo.rdb.ZScore(ctx, genUserSidsKey(accountId), sessionId).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ListSessions 按登录时间倒序列出用户的有效会话，顺带清理已过期的会话 ID
+func (repo *Repository) ListSessions(ctx context.Context, accountId uuid.UUID) ([]SessionInfo, error) {
+	userSidsKey := genUserSidsKey(accountId)
+	sids, err := repo.rdb.ZRevRange(ctx, userSidsKey, 0, -1).Result()
 	if err != nil {
-		return fmt.Errorf("%w: %v", RepoErrDatabaseInternal, cmd.Err())
+		return nil, err
 	}
-	if result == 0 {
-		return RepoErrSessionNotFound
+	if len(sids) == 0 {
+		return nil, nil
 	}
-	return nil
+
+	pipe := repo.rdb.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HGetAll(ctx, genSidContainKey(sid))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	sessions := make([]SessionInfo, 0, len(sids))
+	var expired []interface{}
+	for i, cmd := range cmds {
+		fields := cmd.Val()
+		if len(fields) == 0 {
+			expired = append(expired, sids[i])
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			SessionID:   sids[i],
+			CreatedAt:   parseUnix(fields["created_at"]),
+			RefreshedAt: parseUnix(fields["refreshed_at"]),
+			IP:          fields["ip"],
+			UserAgent:   fields["ua"],
+		})
+	}
+	if len(expired) > 0 {
+		if err := repo.rdb.ZRem(ctx, userSidsKey, expired...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
 }
 
-func (repo *Repository) DelSession(ctx context.Context, accountId uuid.UUID, sessionId string) error {
-	cmd := deleteSessionScript.Run(
+// parseUnix 解析会话中的秒级时间戳，旧会话没有该字段时返回零值
+func parseUnix(s string) time.Time {
+	sec, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+func genLoginKey(prefix, scope, value string) string {
+	return fmt.Sprintf("%s:%s:%s", prefix, scope, value)
+}
+
+// loginLockRemaining 返回 scope 下锁定的剩余时间，未锁定返回 0
+func (repo *Repository) loginLockRemaining(ctx context.Context, scope, value string) (time.Duration, error) {
+	ttl, err := repo.rdb.PTTL(ctx, genLoginKey(loginLockPrefix, scope, value)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// 键不存在时 PTTL 返回负数
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// recordLoginFailure 记录一次登录失败，达到 maxFailures 时锁定并返回锁定时长
+func (repo *Repository) recordLoginFailure(ctx context.Context, scope, value string, maxFailures int) (time.Duration, error) {
+	guard := repo.config.LoginGuard
+	lockMs, err := recordLoginFailureScript.Run(
 		ctx,
 		repo.rdb,
 		[]string{
-			genSidContainKey(sessionId),
-			genUserSidsKey(accountId),
+			genLoginKey(loginFailPrefix, scope, value),
+			genLoginKey(loginLockPrefix, scope, value),
+			genLoginKey(loginLockoutsPrefix, scope, value),
 		},
-		sessionId,
-	)
-	return cmd.Err()
+		maxFailures,
+		guard.FailureWindow.Milliseconds(),
+		guard.LockoutBase.Milliseconds(),
+		guard.LockoutMax.Milliseconds(),
+	).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(lockMs) * time.Millisecond, nil
+}
+
+// resetLoginFailures 登录成功后清零失败计数与连续锁定次数
+func (repo *Repository) resetLoginFailures(ctx context.Context, scope, value string) error {
+	return repo.rdb.Del(ctx,
+		genLoginKey(loginFailPrefix, scope, value),
+		genLoginKey(loginLockoutsPrefix, scope, value),
+	).Err()
+}
+
+// 验证码错误次数 +1，达到上限删除；登录已失效时不重新创建（避免产生没有过期时间的键）
+var recordMFAFailureScript = redis.NewScript(`
+	if redis.call('EXISTS', KEYS[1]) == 0 then
+		return 0
+	end
+	local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+	if attempts >= tonumber(ARGV[1]) then
+		redis.call('DEL', KEYS[1])
+	end
+	return attempts
+`)
+
+func genMFAPendingKey(jti string) string {
+	return fmt.Sprintf("%s:%s", mfaPendingPrefix, jti)
+}
+
+// createMFAPending 记录等待两步验证的登录
+func (repo *Repository) createMFAPending(ctx context.Context, jti, email string, expire time.Duration) error {
+	key := genMFAPendingKey(jti)
+	_, err := repo.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, "email", email, "attempts", 0)
+		pipe.PExpire(ctx, key, expire)
+		return nil
+	})
+	return err
+}
+
+// getMFAPendingEmail 不存在（已过期/已使用/错误次数过多）时返回 RepoErrMFAPendingNotFound
+func (repo *Repository) getMFAPendingEmail(ctx context.Context, jti string) (string, error) {
+	email, err := repo.rdb.HGet(ctx, genMFAPendingKey(jti), "email").Result()
+	if errors.Is(err, redis.Nil) {
+		return "", RepoErrMFAPendingNotFound
+	}
+	return email, err
+}
+
+// recordMFAPendingFailure 累计验证码错误次数，达到 maxAttempts 后作废该登录
+func (repo *Repository) recordMFAPendingFailure(ctx context.Context, jti string, maxAttempts int) error {
+	key := genMFAPendingKey(jti)
+	return recordMFAFailureScript.Run(ctx, repo.rdb, []string{key}, maxAttempts).Err()
+}
+
+// consumeMFAPending 删除等待中的登录，返回 false 表示已被并发请求使用
+func (repo *Repository) consumeMFAPending(ctx context.Context, jti string) (bool, error) {
+	n, err := repo.rdb.Del(ctx, genMFAPendingKey(jti)).Result()
+	return n > 0, err
+}
+
+// FindTOTP 查询用户的 TOTP 绑定，未绑定时返回 gorm.ErrRecordNotFound
+func (repo *Repository) FindTOTP(ctx context.Context, userID uuid.UUID) (*model.UserTOTP, error) {
+	var totp model.UserTOTP
+	result := repo.db.WithContext(ctx).Where("user_id = ?", userID).First(&totp)
+	return &totp, result.Error
+}
+
+// saveTOTPEnrollment 保存待确认的密钥并替换恢复码，重复绑定时覆盖上一次未确认的密钥
+func (repo *Repository) saveTOTPEnrollment(ctx context.Context, userID uuid.UUID, secret string, codeHashes []string) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec(`INSERT INTO user_totps (user_id, secret, enabled_at, last_used_step, created_at, updated_at)
+			VALUES (?, ?, NULL, 0, NOW(), NOW())
+			ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = NOW()
+			WHERE user_totps.enabled_at IS NULL`, userID, secret)
+		if result.Error != nil {
+			return fmt.Errorf("execute query error %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return RepoErrTOTPAlreadyEnabled
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("execute query error %w", err)
+		}
+		codes := make([]model.UserRecoveryCode, 0, len(codeHashes))
+		for _, h := range codeHashes {
+			codes = append(codes, model.UserRecoveryCode{UserID: userID, CodeHash: h})
+		}
+		if err := tx.Create(&codes).Error; err != nil {
+			return fmt.Errorf("execute query error %w", err)
+		}
+		return nil
+	})
+}
+
+// enableTOTP 确认绑定，记录本次验证通过的时间步
+func (repo *Repository) enableTOTP(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result := repo.db.WithContext(ctx).Model(&model.UserTOTP{}).
+		Where("user_id = ? AND enabled_at IS NULL", userID).
+		Updates(map[string]interface{}{"enabled_at": time.Now(), "last_used_step": step})
+	if result.Error != nil {
+		return false, fmt.Errorf("execute query error %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// useTOTPStep 条件更新最近使用的时间步，返回 false 表示验证码已被使用过
+func (repo *Repository) useTOTPStep(ctx context.Context, userID uuid.UUID, step int64) (bool, error) {
+	result := repo.db.WithContext(ctx).Model(&model.UserTOTP{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("execute query error %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// useRecoveryCode 标记恢复码已使用，返回 false 表示恢复码不存在或已使用
+func (repo *Repository) useRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) (bool, error) {
+	result := repo.db.WithContext(ctx).Model(&model.UserRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("execute query error %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// deleteTOTP 关闭两步验证，删除密钥与恢复码
+func (repo *Repository) deleteTOTP(ctx context.Context, userID uuid.UUID) error {
+	return repo.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("execute query error %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserTOTP{}).Error; err != nil {
+			return fmt.Errorf("execute query error %w", err)
+		}
+		return nil
+	})
 }
@@ -4,14 +4,19 @@ import (
 	"context"
 	"e-commerce/internal/app/identity"
 	"e-commerce/internal/config"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/clog"
 	"e-commerce/pkg/errno"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -21,11 +26,16 @@ type TokenType string
 var (
 	AccessToken  TokenType = "access_token"
 	RefreshToken TokenType = "refresh_token"
+	// MFAPendingToken 密码校验通过后签发的短期令牌，仅用于提交两步验证码
+	MFAPendingToken TokenType = "mfa_pending"
 )
 
 type LoginInput struct {
 	Email    string
 	Password string
+	// IP、UserAgent 记录到会话中，用于会话列表展示登录设备
+	IP        string
+	UserAgent string
 }
 
 type TokenPayload struct {
@@ -39,13 +49,26 @@ type TokenPair struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// LoginResult 未开启两步验证时直接返回令牌对；开启后只返回 mfa_token，提交验证码后才签发令牌对
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
 type Service struct {
 	repo   *Repository
 	config *config.AuthSection
+	keys   *KeySet
 }
 
-func NewService(repo *Repository, config *config.AuthSection) *Service {
-	return &Service{repo: repo, config: config}
+func NewService(repo *Repository, config *config.AuthSection, keys *KeySet) *Service {
+	return &Service{repo: repo, config: config, keys: keys}
+}
+
+// JWKS 令牌验签公钥集合，供其他服务离线验证 AccessToken
+func (svc *Service) JWKS() JWKS {
+	return svc.keys.JWKS()
 }
 
 func genSessionId() (string, error) {
@@ -74,19 +97,13 @@ func (svc *Service) CreateSession(ctx context.Context, userID uuid.UUID) (string
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenPayload)
-	return token.SignedString([]byte(svc.config.TokenSecret))
+	return svc.keys.Sign(tokenPayload)
 }
 
-// VerifyToken 验证Token是否正常
-func (svc *Service) VerifyToken(ctx context.Context, userToken string, tokenType TokenType) (*identity.AccountInfo, error) {
+// parseToken 校验令牌签名、有效期与类型
+func (svc *Service) parseToken(userToken string, tokenType TokenType) (*TokenPayload, error) {
 	payload := &TokenPayload{}
-	token, err := jwt.ParseWithClaims(userToken, payload, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errno.ErrAuthInvalidToken
-		}
-		return []byte(svc.config.TokenSecret), nil
-	})
+	token, err := jwt.ParseWithClaims(userToken, payload, svc.keys.Keyfunc)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
 			return nil, errno.ErrAuthTokenExpired.WithRaw(err)
@@ -100,6 +117,27 @@ func (svc *Service) VerifyToken(ctx context.Context, userToken string, tokenType
 	if (!ok || !token.Valid) || (claims.TokenType != tokenType) {
 		return nil, errno.ErrAuthInvalidToken
 	}
+	return claims, nil
+}
+
+// sessionGoneError 会话已不存在时，区分被踢下与已过期/已注销
+func (svc *Service) sessionGoneError(ctx context.Context, sessionID string, raw error) error {
+	evicted, err := svc.repo.isSessionEvicted(ctx, sessionID)
+	if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if evicted {
+		return errno.ErrAuthSessionRevoked
+	}
+	return errno.ErrAuthInvalidToken.WithRaw(raw)
+}
+
+// VerifyToken 验证Token是否正常
+func (svc *Service) VerifyToken(ctx context.Context, userToken string, tokenType TokenType) (*identity.AccountInfo, error) {
+	claims, err := svc.parseToken(userToken, tokenType)
+	if err != nil {
+		return nil, err
+	}
 
 	var findToken string
 	if claims.TokenType == AccessToken {
@@ -110,7 +148,7 @@ func (svc *Service) VerifyToken(ctx context.Context, userToken string, tokenType
 
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
-			return nil, errno.ErrAuthInvalidToken.WithRaw(err)
+			return nil, svc.sessionGoneError(ctx, claims.SessionID, err)
 		}
 		return nil, errno.ErrInternalServer.WithRaw(err)
 	}
@@ -120,7 +158,7 @@ func (svc *Service) VerifyToken(ctx context.Context, userToken string, tokenType
 	return &claims.AccountInfo, nil
 }
 
-func (svc *Service) generateToken(userID uuid.UUID, sessionID string, tokenType TokenType) (string, error) {
+func (svc *Service) generateToken(accountInfo identity.AccountInfo, tokenType TokenType) (string, error) {
 	jti, err := generateRandomString(5)
 	if err != nil {
 		return "", fmt.Errorf("generate token jti fail: %w", err)
@@ -128,11 +166,8 @@ func (svc *Service) generateToken(userID uuid.UUID, sessionID string, tokenType
 	var tokenPayload TokenPayload
 	if tokenType == AccessToken {
 		tokenPayload = TokenPayload{
-			AccountInfo: identity.AccountInfo{
-				AccountId: userID,
-				SessionID: sessionID,
-			},
-			TokenType: tokenType,
+			AccountInfo: accountInfo,
+			TokenType:   tokenType,
 			RegisteredClaims: jwt.RegisteredClaims{
 				ExpiresAt: jwt.NewNumericDate(time.Now().Add(svc.config.AccessTokenExpire)),
 				IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -141,11 +176,8 @@ func (svc *Service) generateToken(userID uuid.UUID, sessionID string, tokenType
 		}
 	} else if tokenType == RefreshToken {
 		tokenPayload = TokenPayload{
-			AccountInfo: identity.AccountInfo{
-				AccountId: userID,
-				SessionID: sessionID,
-			},
-			TokenType: tokenType,
+			AccountInfo: accountInfo,
+			TokenType:   tokenType,
 			RegisteredClaims: jwt.RegisteredClaims{
 				ExpiresAt: jwt.NewNumericDate(time.Now().Add(svc.config.RefreshTokenExpire)),
 				IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -154,81 +186,268 @@ func (svc *Service) generateToken(userID uuid.UUID, sessionID string, tokenType
 		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, tokenPayload)
-	tokenStr, err := token.SignedString([]byte(svc.config.TokenSecret))
+	tokenStr, err := svc.keys.Sign(tokenPayload)
 	if err != nil {
 		return "", fmt.Errorf("generate token error: %w", err)
 	}
 	return tokenStr, nil
 }
 
-func (svc *Service) Login(ctx context.Context, input *LoginInput) (*TokenPair, error) {
+// LoginLockedData 登录被锁定时随错误返回，告知客户端何时可以重试
+type LoginLockedData struct {
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+	RetryAt           string `json:"retry_at"`
+}
+
+type loginSubject struct {
+	scope       string
+	value       string
+	maxFailures int
+}
+
+// loginSubjects 登录失败按账号和 IP 分别计数，阈值为 0 的维度不参与
+func (svc *Service) loginSubjects(input *LoginInput) []loginSubject {
+	guard := svc.config.LoginGuard
+	subjects := make([]loginSubject, 0, 2)
+	if guard.MaxFailures > 0 {
+		subjects = append(subjects, loginSubject{loginScopeEmail, strings.ToLower(strings.TrimSpace(input.Email)), guard.MaxFailures})
+	}
+	if guard.MaxFailuresPerIP > 0 && input.IP != "" {
+		subjects = append(subjects, loginSubject{loginScopeIP, input.IP, guard.MaxFailuresPerIP})
+	}
+	return subjects
+}
+
+func loginLockedError(remaining time.Duration) error {
+	seconds := int(math.Ceil(remaining.Seconds()))
+	return errno.ErrAuthLoginLocked.WithData(LoginLockedData{
+		RetryAfterSeconds: seconds,
+		RetryAt:           time.Now().Add(time.Duration(seconds) * time.Second).Format(time.RFC3339),
+	})
+}
+
+// checkLoginLock 账号或 IP 任一处于锁定期则拒绝登录，返回最长的剩余锁定时间
+func (svc *Service) checkLoginLock(ctx context.Context, subjects []loginSubject) error {
+	var remaining time.Duration
+	for _, s := range subjects {
+		ttl, err := svc.repo.loginLockRemaining(ctx, s.scope, s.value)
+		if err != nil {
+			return errno.ErrInternalServer.WithRaw(err)
+		}
+		if ttl > remaining {
+			remaining = ttl
+		}
+	}
+	if remaining > 0 {
+		return loginLockedError(remaining)
+	}
+	return nil
+}
+
+// recordLoginFailure 记录失败并返回对应错误：本次失败触发锁定时直接返回锁定错误
+func (svc *Service) recordLoginFailure(ctx context.Context, subjects []loginSubject) error {
+	var locked time.Duration
+	for _, s := range subjects {
+		lockFor, err := svc.repo.recordLoginFailure(ctx, s.scope, s.value, s.maxFailures)
+		if err != nil {
+			return errno.ErrInternalServer.WithRaw(err)
+		}
+		if lockFor > locked {
+			locked = lockFor
+		}
+	}
+	if locked > 0 {
+		return loginLockedError(locked)
+	}
+	return errno.ErrUserNotFound
+}
+
+func (svc *Service) Login(ctx context.Context, input *LoginInput) (*LoginResult, error) {
+	subjects := svc.loginSubjects(input)
+	if err := svc.checkLoginLock(ctx, subjects); err != nil {
+		return nil, err
+	}
+
 	user, err := svc.repo.FindUserByEmail(ctx, input.Email)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, errno.ErrUserNotFound
+		return nil, svc.recordLoginFailure(ctx, subjects)
+	} else if err != nil {
+		return nil, errno.ErrInternalServer.WithRaw(err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password))
 	if err != nil {
-		return nil, errno.ErrUserNotFound
+		return nil, svc.recordLoginFailure(ctx, subjects)
 	}
 
-	sid, err := genSessionId()
+	// 开启两步验证时失败计数保留到验证码通过后再清零，验证码错误同样计入
+	enabled, err := svc.totpEnabled(ctx, user.ID)
 	if err != nil {
 		return nil, err
 	}
+	if enabled {
+		mfaToken, err := svc.createMFAPending(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	if err := svc.resetLoginFailures(ctx, subjects); err != nil {
+		return nil, err
+	}
+	tokenPair, err := svc.issueSession(ctx, user, false, SessionMeta{
+		IP:        input.IP,
+		UserAgent: input.UserAgent,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{TokenPair: tokenPair}, nil
+}
+
+// CheckPasswordLock 已登录用户校验当前密码前调用，账号处于登录锁定期时返回 ErrAuthLoginLocked
+func (svc *Service) CheckPasswordLock(ctx context.Context, email string) error {
+	return svc.checkLoginLock(ctx, svc.loginSubjects(&LoginInput{Email: email}))
+}
+
+// RecordPasswordFailure 当前密码校验失败时计入账号的登录失败，本次失败触发锁定时返回 ErrAuthLoginLocked
+func (svc *Service) RecordPasswordFailure(ctx context.Context, email string) error {
+	if err := svc.recordLoginFailure(ctx, svc.loginSubjects(&LoginInput{Email: email})); !errors.Is(err, errno.ErrUserNotFound) {
+		return err
+	}
+	return nil
+}
+
+// accountSubjects 已登录用户提交的验证码/密码只按账号维度计入登录防爆破
+func (svc *Service) accountSubjects(ctx context.Context, userID uuid.UUID) ([]loginSubject, error) {
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrNotFoundRecord
+		}
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	return svc.loginSubjects(&LoginInput{Email: user.Email}), nil
+}
 
-	at, err := svc.generateToken(user.ID, sid, AccessToken)
+func (svc *Service) resetLoginFailures(ctx context.Context, subjects []loginSubject) error {
+	for _, s := range subjects {
+		if err := svc.repo.resetLoginFailures(ctx, s.scope, s.value); err != nil {
+			return errno.ErrInternalServer.WithRaw(err)
+		}
+	}
+	return nil
+}
+
+// issueSession 创建会话并签发令牌对，mfa 标记会话是否通过了两步验证
+func (svc *Service) issueSession(ctx context.Context, user *model.User, mfa bool, meta SessionMeta) (*TokenPair, error) {
+	sid, err := genSessionId()
 	if err != nil {
 		return nil, err
 	}
 
-	rt, err := svc.generateToken(user.ID, sid, RefreshToken)
+	accountInfo := identity.AccountInfo{
+		AccountId: user.ID,
+		SessionID: sid,
+		Role:      user.Role,
+		MFA:       mfa,
+	}
+	at, err := svc.generateToken(accountInfo, AccessToken)
 	if err != nil {
 		return nil, err
 	}
 
-	err = svc.repo.createSession(ctx, user.ID, sid, at, rt, svc.config.RefreshTokenExpire)
+	rt, err := svc.generateToken(accountInfo, RefreshToken)
 	if err != nil {
 		return nil, err
 	}
 
+	err = svc.repo.createSession(ctx, user.ID, sid, at, rt, svc.config.RefreshTokenExpire, meta)
+	if err != nil {
+		if errors.Is(err, RepoErrSessionLimitReached) {
+			return nil, errno.ErrAuthSessionLimit
+		}
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+
 	return &TokenPair{
 		AccessToken:  at,
 		RefreshToken: rt,
 	}, nil
 }
 
-func (svc *Service) fetchAccessToken(ctx context.Context, accountInfo *identity.AccountInfo) (string, error) {
-	newAccessToken, err := svc.generateToken(accountInfo.AccountId, accountInfo.SessionID, AccessToken)
+// currentAccountInfo 以数据库中的最新角色重建令牌身份，角色变更在下次刷新令牌时生效
+func (svc *Service) currentAccountInfo(ctx context.Context, accountInfo *identity.AccountInfo) (identity.AccountInfo, error) {
+	user, err := svc.repo.FindUserByID(ctx, accountInfo.AccountId)
 	if err != nil {
-		return "", err
-	}
-
-	err = svc.repo.SetAccessToken(ctx, accountInfo.AccountId, accountInfo.SessionID, newAccessToken)
-	if err != nil {
-		if errors.Is(err, RepoErrSessionNotFound) {
-			return "", errno.ErrAuthInvalidToken
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return identity.AccountInfo{}, errno.ErrAuthInvalidToken
 		}
-		return "", errno.ErrInternalServer.WithRaw(err)
+		return identity.AccountInfo{}, errno.ErrInternalServer.WithRaw(err)
 	}
-	return newAccessToken, nil
+	return identity.AccountInfo{
+		AccountId: user.ID,
+		SessionID: accountInfo.SessionID,
+		Role:      user.Role,
+		MFA:       accountInfo.MFA,
+	}, nil
 }
 
-func (svc *Service) fetchRefreshToken(ctx context.Context, accountInfo *identity.AccountInfo) (string, error) {
-	newRefreshToken, err := svc.generateToken(accountInfo.AccountId, accountInfo.SessionID, RefreshToken)
+// RefreshTokens 用刷新令牌换取新的令牌对，旧刷新令牌随即失效；
+// 已轮换的旧刷新令牌被再次使用时视为令牌被盗，注销整个会话并记录审计日志
+func (svc *Service) RefreshTokens(ctx context.Context, refreshToken string, meta SessionMeta) (*TokenPair, error) {
+	claims, err := svc.parseToken(refreshToken, RefreshToken)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	err = svc.repo.SetRefreshToken(ctx, accountInfo.AccountId, accountInfo.SessionID, newRefreshToken)
+	current, err := svc.currentAccountInfo(ctx, &claims.AccountInfo)
 	if err != nil {
-		if errors.Is(err, RepoErrSessionNotFound) {
-			return "", errno.ErrAuthInvalidToken
-		}
-		return "", errno.ErrInternalServer.WithRaw(err)
+		return nil, err
+	}
+	at, err := svc.generateToken(current, AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	rt, err := svc.generateToken(current, RefreshToken)
+	if err != nil {
+		return nil, err
 	}
-	return newRefreshToken, nil
+
+	err = svc.repo.rotateTokens(ctx, claims.SessionID, refreshToken, at, rt)
+	switch {
+	case err == nil:
+		return &TokenPair{AccessToken: at, RefreshToken: rt}, nil
+	case errors.Is(err, RepoErrSessionNotFound):
+		return nil, svc.sessionGoneError(ctx, claims.SessionID, err)
+	case errors.Is(err, RepoErrRefreshTokenReused):
+		return nil, svc.revokeReusedSession(ctx, &claims.AccountInfo, meta)
+	default:
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+}
+
+// revokeReusedSession 注销刷新令牌被重用的会话，并记录审计日志
+func (svc *Service) revokeReusedSession(ctx context.Context, accountInfo *identity.AccountInfo, meta SessionMeta) error {
+	clog.L(ctx).Warn("检测到已轮换的刷新令牌被重用，注销会话",
+		zap.String("account_id", accountInfo.AccountId.String()),
+		zap.String("session_id", accountInfo.SessionID),
+		zap.String("ip", meta.IP),
+	)
+	if err := svc.repo.DelSession(ctx, accountInfo.AccountId, accountInfo.SessionID); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if err := svc.repo.CreateAuditLog(ctx, &model.AuthAuditLog{
+		UserID:    accountInfo.AccountId,
+		SessionID: accountInfo.SessionID,
+		Event:     model.AuthAuditRefreshTokenReuse,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+	}); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	return errno.ErrAuthRefreshTokenReused
 }
 
 func (svc *Service) logout(ctx context.Context, accountInfo *identity.AccountInfo) error {
@@ -238,3 +457,55 @@ func (svc *Service) logout(ctx context.Context, accountInfo *identity.AccountInf
 	}
 	return nil
 }
+
+// ListSessions 列出当前用户所有已登录设备，按登录时间倒序
+func (svc *Service) ListSessions(ctx context.Context, accountInfo *identity.AccountInfo) ([]SessionInfo, error) {
+	sessions, err := svc.repo.ListSessions(ctx, accountInfo.AccountId)
+	if err != nil {
+		return nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession 注销当前用户的指定会话
+func (svc *Service) RevokeSession(ctx context.Context, accountInfo *identity.AccountInfo, sessionID string) error {
+	ok, err := svc.repo.HasSession(ctx, accountInfo.AccountId, sessionID)
+	if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if !ok {
+		return errno.ErrAuthSessionNotFound
+	}
+	if err := svc.repo.DelSession(ctx, accountInfo.AccountId, sessionID); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	return nil
+}
+
+// RevokeOtherSessions 注销除当前会话外的所有会话，返回注销数量
+func (svc *Service) RevokeOtherSessions(ctx context.Context, accountInfo *identity.AccountInfo) (int, error) {
+	return svc.revokeSessionsExcept(ctx, accountInfo.AccountId, accountInfo.SessionID)
+}
+
+// RevokeAllSessions 注销用户的全部会话（如重置密码后），返回注销数量
+func (svc *Service) RevokeAllSessions(ctx context.Context, userID uuid.UUID) (int, error) {
+	return svc.revokeSessionsExcept(ctx, userID, "")
+}
+
+func (svc *Service) revokeSessionsExcept(ctx context.Context, userID uuid.UUID, keepSessionID string) (int, error) {
+	sessions, err := svc.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return 0, errno.ErrInternalServer.WithRaw(err)
+	}
+	revoked := 0
+	for _, s := range sessions {
+		if s.SessionID == keepSessionID {
+			continue
+		}
+		if err := svc.repo.DelSession(ctx, userID, s.SessionID); err != nil {
+			return revoked, errno.ErrInternalServer.WithRaw(err)
+		}
+		revoked++
+	}
+	return revoked, nil
+}
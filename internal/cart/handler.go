@@ -0,0 +1,190 @@
+package cart
+
+import (
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/pkg/response"
+	"e-commerce/pkg/errno"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// AddItem 加入购物车
+func (h *Handler) AddItem(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var body AddItemBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	productID, err := uuid.Parse(body.ProductID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.svc.AddItem(ctx, AddItemParam{
+		UserID:    accountInfo.AccountId,
+		ProductID: productID,
+		Quantity:  body.Quantity,
+	}); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// UpdateItem 修改购物车商品数量或选中状态
+func (h *Handler) UpdateItem(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithProductID
+	var body UpdateItemBody
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	productID, err := uuid.Parse(uri.ProductID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.svc.UpdateItem(ctx, UpdateItemParam{
+		UserID:    accountInfo.AccountId,
+		ProductID: productID,
+		Quantity:  body.Quantity,
+		Selected:  body.Selected,
+	}); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// RemoveItem 从购物车移除商品
+func (h *Handler) RemoveItem(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithProductID
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	productID, err := uuid.Parse(uri.ProductID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.svc.RemoveItem(ctx, accountInfo.AccountId, productID); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// ListCart 查看购物车
+func (h *Handler) ListCart(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	lines, err := h.svc.ListCart(ctx, accountInfo.AccountId)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, formatCart(lines))
+}
+
+// Checkout 结算购物车中选中的商品
+func (h *Handler) Checkout(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var body CheckoutBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	var couponID uuid.UUID
+	if body.CouponID != "" {
+		var err error
+		couponID, err = uuid.Parse(body.CouponID)
+		if err != nil {
+			response.WriteInvalidParam(c, err)
+			return
+		}
+	}
+
+	var addressID uuid.UUID
+	if body.AddressID != "" {
+		var err error
+		addressID, err = uuid.Parse(body.AddressID)
+		if err != nil {
+			response.WriteInvalidParam(c, err)
+			return
+		}
+	}
+
+	o, err := h.svc.Checkout(ctx, CheckoutParam{
+		UserID:         accountInfo.AccountId,
+		UserCouponID:   couponID,
+		AddressID:      addressID,
+		IdempotencyKey: body.IdempotencyKey,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, CheckoutResponse{
+		OrderID:     o.ID.String(),
+		PayDeadline: o.PayDeadline.Format(time.RFC3339),
+	})
+}
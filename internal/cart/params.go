@@ -0,0 +1,23 @@
+package cart
+
+import "github.com/google/uuid"
+
+type AddItemParam struct {
+	UserID    uuid.UUID
+	ProductID uuid.UUID
+	Quantity  int
+}
+
+type UpdateItemParam struct {
+	UserID    uuid.UUID
+	ProductID uuid.UUID
+	Quantity  int
+	Selected  *bool
+}
+
+type CheckoutParam struct {
+	UserID         uuid.UUID
+	UserCouponID   uuid.UUID
+	AddressID      uuid.UUID
+	IdempotencyKey string
+}
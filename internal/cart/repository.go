@@ -0,0 +1,203 @@
+package cart
+
+import (
+	"context"
+	"e-commerce/internal/order"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+var (
+	repoErrCartFull     = errors.New("cart line limit reached")
+	repoErrItemNotFound = errors.New("cart item not found")
+)
+
+const (
+	cartPrefix = "cart"
+	// cartExpire 购物车最后一次变更后的保留时长
+	cartExpire = 30 * 24 * time.Hour
+	// maxCartLines 购物车最多可放入的商品种类数，与单个订单上限一致，保证整车可以结算
+	maxCartLines = order.MaxOrderItems
+	// maxLineQuantity 单个商品在购物车中的数量上限
+	maxLineQuantity = 999
+)
+
+// 加购：已存在则累加数量，新商品默认选中
+var addItemScript = redis.NewScript(`
+	local cart_key = KEYS[1]
+
+	local product_id = ARGV[1]
+	local quantity   = tonumber(ARGV[2])
+	local now        = tonumber(ARGV[3])
+	local max_qty    = tonumber(ARGV[4])
+	local max_lines  = tonumber(ARGV[5])
+	local expire     = ARGV[6]
+
+	local item
+	local raw = redis.call("HGET", cart_key, product_id)
+	if raw then
+		item = cjson.decode(raw)
+		item.quantity = item.quantity + quantity
+	else
+		if redis.call("HLEN", cart_key) >= max_lines then return -1 end
+		item = {quantity = quantity, selected = true, added_at = now}
+	end
+	if item.quantity > max_qty then item.quantity = max_qty end
+
+	redis.call("HSET", cart_key, product_id, cjson.encode(item))
+	redis.call("EXPIRE", cart_key, expire)
+	return item.quantity
+`)
+
+// 修改数量/选中状态：quantity 为 0 表示不修改，selected 为 "" 表示不修改
+var updateItemScript = redis.NewScript(`
+	local cart_key = KEYS[1]
+
+	local product_id = ARGV[1]
+	local quantity   = tonumber(ARGV[2])
+	local selected   = ARGV[3]
+	local expire     = ARGV[4]
+
+	local raw = redis.call("HGET", cart_key, product_id)
+	if not raw then return 0 end
+
+	local item = cjson.decode(raw)
+	if quantity > 0 then item.quantity = quantity end
+	if selected == "1" then item.selected = true end
+	if selected == "0" then item.selected = false end
+
+	redis.call("HSET", cart_key, product_id, cjson.encode(item))
+	redis.call("EXPIRE", cart_key, expire)
+	return 1
+`)
+
+type Repository struct {
+	rdb *redis.Client
+}
+
+func NewRepository(rdb *redis.Client) *Repository {
+	return &Repository{rdb: rdb}
+}
+
+// Item 购物车中的一行
+type Item struct {
+	ProductID uuid.UUID
+	Quantity  int
+	Selected  bool
+	AddedAt   time.Time
+}
+
+type storedItem struct {
+	Quantity int   `json:"quantity"`
+	Selected bool  `json:"selected"`
+	AddedAt  int64 `json:"added_at"`
+}
+
+func genCartKey(userID uuid.UUID) string {
+	return fmt.Sprintf("%s:%s", cartPrefix, userID)
+}
+
+// AddItem 加购，返回累加后的数量
+func (repo *Repository) AddItem(ctx context.Context, userID, productID uuid.UUID, quantity int) (int, error) {
+	result, err := addItemScript.Run(
+		ctx,
+		repo.rdb,
+		[]string{genCartKey(userID)},
+		productID.String(),
+		quantity,
+		time.Now().Unix(),
+		maxLineQuantity,
+		maxCartLines,
+		int(cartExpire.Seconds()),
+	).Int()
+	if err != nil {
+		return 0, fmt.Errorf("add cart item error: %w", err)
+	}
+	if result < 0 {
+		return 0, repoErrCartFull
+	}
+	return result, nil
+}
+
+type UpdateItemData struct {
+	Quantity int
+	Selected *bool
+}
+
+func (repo *Repository) UpdateItem(ctx context.Context, userID, productID uuid.UUID, data UpdateItemData) error {
+	selected := ""
+	if data.Selected != nil {
+		selected = "0"
+		if *data.Selected {
+			selected = "1"
+		}
+	}
+
+	result, err := updateItemScript.Run(
+		ctx,
+		repo.rdb,
+		[]string{genCartKey(userID)},
+		productID.String(),
+		data.Quantity,
+		selected,
+		int(cartExpire.Seconds()),
+	).Int()
+	if err != nil {
+		return fmt.Errorf("update cart item error: %w", err)
+	}
+	if result == 0 {
+		return repoErrItemNotFound
+	}
+	return nil
+}
+
+func (repo *Repository) RemoveItems(ctx context.Context, userID uuid.UUID, productIDs ...uuid.UUID) (int64, error) {
+	if len(productIDs) == 0 {
+		return 0, nil
+	}
+	fields := make([]string, 0, len(productIDs))
+	for _, id := range productIDs {
+		fields = append(fields, id.String())
+	}
+	return repo.rdb.HDel(ctx, genCartKey(userID), fields...).Result()
+}
+
+// ListItems 查询购物车，按加购时间倒序
+func (repo *Repository) ListItems(ctx context.Context, userID uuid.UUID) ([]*Item, error) {
+	raw, err := repo.rdb.HGetAll(ctx, genCartKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list cart items error: %w", err)
+	}
+
+	items := make([]*Item, 0, len(raw))
+	for field, value := range raw {
+		productID, err := uuid.Parse(field)
+		if err != nil {
+			continue
+		}
+		var stored storedItem
+		if err := json.Unmarshal([]byte(value), &stored); err != nil {
+			return nil, fmt.Errorf("decode cart item %s error: %w", field, err)
+		}
+		items = append(items, &Item{
+			ProductID: productID,
+			Quantity:  stored.Quantity,
+			Selected:  stored.Selected,
+			AddedAt:   time.Unix(stored.AddedAt, 0),
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].AddedAt.Equal(items[j].AddedAt) {
+			return items[i].ProductID.String() < items[j].ProductID.String()
+		}
+		return items[i].AddedAt.After(items[j].AddedAt)
+	})
+	return items, nil
+}
@@ -0,0 +1,21 @@
+package cart
+
+type AddItemBody struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1,max=999"`
+}
+
+type UriWithProductID struct {
+	ProductID string `uri:"product_id" binding:"required"`
+}
+
+type UpdateItemBody struct {
+	Quantity int   `json:"quantity" binding:"omitempty,min=1,max=999"`
+	Selected *bool `json:"selected" binding:"omitempty"`
+}
+
+type CheckoutBody struct {
+	CouponID       string `json:"coupon_id" binding:"omitempty"`
+	AddressID      string `json:"address_id" binding:"omitempty"`
+	IdempotencyKey string `json:"idempotency_key" binding:"required,max=64"`
+}
@@ -0,0 +1,50 @@
+package cart
+
+import "time"
+
+type LineItem struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Quantity  int     `json:"quantity"`
+	Stock     int     `json:"stock"`
+	Selected  bool    `json:"selected"`
+	Status    string  `json:"status"`
+	Subtotal  float64 `json:"subtotal"`
+	AddedAt   string  `json:"added_at"`
+}
+
+type CartResponse struct {
+	Items []LineItem `json:"items"`
+	// SelectedAmount 选中且可售商品的合计金额（按实时价格）
+	SelectedAmount float64 `json:"selected_amount"`
+}
+
+type CheckoutResponse struct {
+	OrderID     string `json:"order_id"`
+	PayDeadline string `json:"pay_deadline"`
+}
+
+func formatCart(lines []*Line) *CartResponse {
+	resp := &CartResponse{Items: make([]LineItem, 0, len(lines))}
+	for _, line := range lines {
+		item := LineItem{
+			ProductID: line.ProductID.String(),
+			Quantity:  line.Quantity,
+			Selected:  line.Selected,
+			Status:    string(line.Status),
+			AddedAt:   line.AddedAt.Format(time.DateTime),
+		}
+		if line.Product != nil {
+			item.Name = line.Product.Name
+			item.Price = line.Product.Price
+			item.Stock = line.Product.Stock
+			item.Subtotal = line.Product.Price * float64(line.Quantity)
+		}
+		if line.Selected && line.Status == LineStatusAvailable {
+			resp.SelectedAmount += item.Subtotal
+		}
+		resp.Items = append(resp.Items, item)
+	}
+	return resp
+}
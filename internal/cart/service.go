@@ -0,0 +1,182 @@
+package cart
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/internal/order"
+	"e-commerce/internal/product"
+	"e-commerce/pkg/clog"
+	"e-commerce/pkg/errno"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// LineStatus 购物车商品的实时可售状态
+type LineStatus string
+
+const (
+	LineStatusAvailable         LineStatus = "available"
+	LineStatusInsufficientStock LineStatus = "insufficient_stock"
+	LineStatusUnavailable       LineStatus = "unavailable"
+)
+
+// Line 购物车商品行（价格与库存为读取时的实时数据）
+type Line struct {
+	Item
+	Product *model.Product
+	Status  LineStatus
+}
+
+type Service struct {
+	repo        *Repository
+	productRepo *product.Repository
+	orderSvc    *order.Service
+}
+
+func NewService(repo *Repository, productRepo *product.Repository, orderSvc *order.Service) *Service {
+	return &Service{repo: repo, productRepo: productRepo, orderSvc: orderSvc}
+}
+
+// AddItem 加入购物车（仅允许上架商品）
+func (svc *Service) AddItem(ctx context.Context, param AddItemParam) error {
+	p, err := svc.productRepo.GetProduct(ctx, param.ProductID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrProductNotFound
+		}
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if p.Status != model.ProductStatusActive {
+		return errno.ErrCartProductUnavailable
+	}
+
+	if _, err := svc.repo.AddItem(ctx, param.UserID, param.ProductID, param.Quantity); err != nil {
+		if errors.Is(err, repoErrCartFull) {
+			return errno.ErrCartFull
+		}
+		return errno.ErrRedisDown.WithRaw(err)
+	}
+	return nil
+}
+
+func (svc *Service) UpdateItem(ctx context.Context, param UpdateItemParam) error {
+	err := svc.repo.UpdateItem(ctx, param.UserID, param.ProductID, UpdateItemData{
+		Quantity: param.Quantity,
+		Selected: param.Selected,
+	})
+	if err != nil {
+		if errors.Is(err, repoErrItemNotFound) {
+			return errno.ErrCartItemNotFound
+		}
+		return errno.ErrRedisDown.WithRaw(err)
+	}
+	return nil
+}
+
+func (svc *Service) RemoveItem(ctx context.Context, userID, productID uuid.UUID) error {
+	removed, err := svc.repo.RemoveItems(ctx, userID, productID)
+	if err != nil {
+		return errno.ErrRedisDown.WithRaw(err)
+	}
+	if removed == 0 {
+		return errno.ErrCartItemNotFound
+	}
+	return nil
+}
+
+// ListCart 查询购物车，并按商品表重新校验价格与库存
+func (svc *Service) ListCart(ctx context.Context, userID uuid.UUID) ([]*Line, error) {
+	items, err := svc.repo.ListItems(ctx, userID)
+	if err != nil {
+		return nil, errno.ErrRedisDown.WithRaw(err)
+	}
+	if len(items) == 0 {
+		return []*Line{}, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ProductID)
+	}
+	products, err := svc.productRepo.ListProductsByIDs(ctx, ids)
+	if err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	productMap := make(map[uuid.UUID]*model.Product, len(products))
+	for _, p := range products {
+		productMap[p.ID] = p
+	}
+
+	lines := make([]*Line, 0, len(items))
+	for _, item := range items {
+		p := productMap[item.ProductID]
+		line := &Line{Item: *item, Product: p, Status: LineStatusAvailable}
+		switch {
+		case p == nil || p.Status != model.ProductStatusActive:
+			line.Status = LineStatusUnavailable
+		case p.Stock < item.Quantity:
+			line.Status = LineStatusInsufficientStock
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// Checkout 将选中的商品通过订单服务下单，成功后从购物车移除
+func (svc *Service) Checkout(ctx context.Context, param CheckoutParam) (*model.Order, error) {
+	// 重复提交时购物车已被清空，直接返回首次结算的订单
+	if o, err := svc.orderSvc.GetOrderByIdempotencyKey(ctx, param.UserID, param.IdempotencyKey); err == nil {
+		return o, nil
+	} else if !errors.Is(err, errno.ErrNotFoundRecord) {
+		return nil, err
+	}
+
+	lines, err := svc.ListCart(ctx, param.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]order.CreateOrderItemParam, 0, len(lines))
+	productIDs := make([]uuid.UUID, 0, len(lines))
+	for _, line := range lines {
+		if !line.Selected {
+			continue
+		}
+		if line.Status == LineStatusUnavailable {
+			return nil, errno.ErrCartProductUnavailable.WithRaw(
+				fmt.Errorf("product %s is unavailable", line.ProductID),
+			)
+		}
+		items = append(items, order.CreateOrderItemParam{
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+		})
+		productIDs = append(productIDs, line.ProductID)
+	}
+	if len(items) == 0 {
+		return nil, errno.ErrCartEmpty
+	}
+
+	o, err := svc.orderSvc.CreateOrder(ctx, param.UserID, order.CreateOrderParam{
+		Items:          items,
+		UserCouponID:   param.UserCouponID,
+		AddressID:      param.AddressID,
+		IdempotencyKey: param.IdempotencyKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 订单已创建，移除失败只影响购物车展示，不回滚订单
+	if _, err := svc.repo.RemoveItems(ctx, param.UserID, productIDs...); err != nil {
+		clog.L(ctx).Error("结算后清理购物车失败",
+			zap.String("order_id", o.ID.String()),
+			zap.Error(err),
+		)
+	}
+	return o, nil
+}
@@ -29,6 +29,9 @@ type AppConfig struct {
 	Otel       OtelSection       `mapstructure:"otel"`
 	TestImages TestImagesSection `mapstructure:"test_images"`
 	OrderMQ    OrderMQConfig     `mapstructure:"order_mq"`
+	Order      OrderSection      `mapstructure:"order"`
+	User       UserSection       `mapstructure:"user"`
+	Mail       MailSection       `mapstructure:"mail"`
 }
 
 type AppSection struct {
@@ -91,10 +94,81 @@ type OtelSection struct {
 	ServiceName string `mapstructure:"service_name"`
 }
 
+// 会话数达到上限时的处理策略
+const (
+	// SessionPolicyEvictOldest 踢下最早登录的会话
+	SessionPolicyEvictOldest = "evict_oldest"
+	// SessionPolicyReject 拒绝新的登录
+	SessionPolicyReject = "reject"
+)
+
+// SigningKeyConfig 令牌签名密钥，私钥为 PEM（RS256 为 PKCS#1/PKCS#8，EdDSA 为 PKCS#8）
+type SigningKeyConfig struct {
+	Kid            string `mapstructure:"kid"`
+	Alg            string `mapstructure:"alg"`
+	PrivateKey     string `mapstructure:"private_key"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+}
+
 type AuthSection struct {
 	AccessTokenExpire  time.Duration `mapstructure:"access_token_expire"`
 	RefreshTokenExpire time.Duration `mapstructure:"refresh_token_expire"`
-	TokenSecret        string        `mapstructure:"token_secret"`
+	// TokenSecret HS256 共享密钥；未配置 SigningKeys 时用于签发，配置后仅用于验证无 kid 的旧令牌
+	TokenSecret string `mapstructure:"token_secret"`
+	// SigningKeys 非对称签名密钥，ActiveKid 指定签发用的密钥，其余密钥仅用于验签
+	SigningKeys []SigningKeyConfig `mapstructure:"signing_keys"`
+	ActiveKid   string             `mapstructure:"active_kid"`
+	// MaxSessions 每个用户同时在线的会话上限，0 表示不限制
+	MaxSessions        int               `mapstructure:"max_sessions"`
+	SessionLimitPolicy string            `mapstructure:"session_limit_policy"`
+	LoginGuard         LoginGuardSection `mapstructure:"login_guard"`
+	MFA                MFASection        `mapstructure:"mfa"`
+}
+
+// MFASection TOTP 两步验证：开启后登录先返回短期的 mfa_token，提交验证码后才签发令牌对
+type MFASection struct {
+	// Issuer 验证器 App 中显示的服务名
+	Issuer string `mapstructure:"issuer"`
+	// PendingExpire mfa_token 有效期
+	PendingExpire time.Duration `mapstructure:"pending_expire"`
+	// MaxAttempts 单个 mfa_token 允许的验证码错误次数，超过后需重新登录
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// RequiredRoles 这些角色的用户必须通过两步验证登录才能使用对应的管理接口
+	RequiredRoles []string `mapstructure:"required_roles"`
+}
+
+// LoginGuardSection 登录防爆破：窗口期内失败次数达到阈值后临时锁定，连续锁定时长按指数增长
+type LoginGuardSection struct {
+	// MaxFailures 单个账号（邮箱）的失败阈值，0 表示不限制
+	MaxFailures int `mapstructure:"max_failures"`
+	// MaxFailuresPerIP 单个 IP 的失败阈值，0 表示不限制
+	MaxFailuresPerIP int           `mapstructure:"max_failures_per_ip"`
+	FailureWindow    time.Duration `mapstructure:"failure_window"`
+	// LockoutBase 首次锁定时长，之后每次锁定翻倍，不超过 LockoutMax
+	LockoutBase time.Duration `mapstructure:"lockout_base"`
+	LockoutMax  time.Duration `mapstructure:"lockout_max"`
+}
+
+type UserSection struct {
+	// PasswordResetExpire 密码重置令牌有效期
+	PasswordResetExpire time.Duration `mapstructure:"password_reset_expire"`
+	// PasswordResetURL 重置密码页面地址，令牌以 token 查询参数附加在其后
+	PasswordResetURL string `mapstructure:"password_reset_url"`
+	// RequireEmailVerified 开启后未验证邮箱的用户不能下单和充值
+	RequireEmailVerified bool `mapstructure:"require_email_verified"`
+	// EmailVerifyExpire 邮箱验证令牌有效期
+	EmailVerifyExpire time.Duration `mapstructure:"email_verify_expire"`
+	// EmailVerifyURL 邮箱验证地址，令牌以 token 查询参数附加在其后
+	EmailVerifyURL string `mapstructure:"email_verify_url"`
+	// VerifyResendInterval 两次发送验证邮件的最小间隔
+	VerifyResendInterval time.Duration `mapstructure:"verify_resend_interval"`
+}
+
+// MailSection 邮件发送配置，driver 为 log 时仅写日志，为 file 时追加写入 file_path
+type MailSection struct {
+	Driver   string `mapstructure:"driver"`
+	From     string `mapstructure:"from"`
+	FilePath string `mapstructure:"file_path"`
 }
 
 type TestImagesSection struct {
@@ -103,12 +177,31 @@ type TestImagesSection struct {
 	RabbitMQ string `mapstructure:"rabbitmq"`
 }
 
+type OrderSection struct {
+	// PayTimeout 默认支付时限，商品未单独配置时使用
+	PayTimeout time.Duration `mapstructure:"pay_timeout"`
+}
+
 type OrderMQConfig struct {
 	Exchange      string `mapstructure:"exchange"`
 	ConsumerQueue string `mapstructure:"consumer_queue"`
-	DelayQueue    string `mapstructure:"delay_queue"`
 	RoutingKey    string `mapstructure:"routing_key"`
-	TTLMs         int    `mapstructure:"ttl_ms"`
+	// RetryQueuePrefix 第 n 次重试的延迟队列为 <prefix>.<n>，TTL 为 RetryBaseTTLMs * 2^(n-1)
+	RetryQueuePrefix string `mapstructure:"retry_queue_prefix"`
+	RetryBaseTTLMs   int    `mapstructure:"retry_base_ttl_ms"`
+	MaxRetries       int    `mapstructure:"max_retries"`
+	// DeadLetterQueue 重试耗尽或无法解析的消息最终进入该队列，等待人工重放
+	DeadLetterQueue string `mapstructure:"dead_letter_queue"`
+}
+
+// RetryQueue 第 attempt 次重试使用的延迟队列名
+func (c *OrderMQConfig) RetryQueue(attempt int) string {
+	return fmt.Sprintf("%s.%d", c.RetryQueuePrefix, attempt)
+}
+
+// RetryTTLMs 第 attempt 次重试的延迟（毫秒），按指数增长
+func (c *OrderMQConfig) RetryTTLMs(attempt int) int {
+	return c.RetryBaseTTLMs << (attempt - 1)
 }
 
 func Init() (*AppConfig, error) {
@@ -118,6 +211,25 @@ func Init() (*AppConfig, error) {
 	v := viper.New()
 
 	v.SetDefault("APP_ENV", EnvProd)
+	v.SetDefault("auth.max_sessions", 5)
+	v.SetDefault("auth.session_limit_policy", SessionPolicyEvictOldest)
+	v.SetDefault("auth.login_guard.max_failures", 5)
+	v.SetDefault("auth.login_guard.max_failures_per_ip", 20)
+	v.SetDefault("auth.login_guard.failure_window", 15*time.Minute)
+	v.SetDefault("auth.login_guard.lockout_base", time.Minute)
+	v.SetDefault("auth.login_guard.lockout_max", time.Hour)
+	v.SetDefault("auth.mfa.issuer", "e-commerce")
+	v.SetDefault("auth.mfa.pending_expire", 5*time.Minute)
+	v.SetDefault("auth.mfa.max_attempts", 5)
+	v.SetDefault("user.password_reset_expire", 30*time.Minute)
+	v.SetDefault("user.email_verify_expire", 24*time.Hour)
+	v.SetDefault("user.verify_resend_interval", time.Minute)
+	v.SetDefault("mail.driver", "log")
+	v.SetDefault("order.pay_timeout", 30*time.Minute)
+	v.SetDefault("order_mq.retry_queue_prefix", "order.timeout.retry")
+	v.SetDefault("order_mq.retry_base_ttl_ms", 5000)
+	v.SetDefault("order_mq.max_retries", 3)
+	v.SetDefault("order_mq.dead_letter_queue", "order.timeout.dlq")
 
 	v.SetEnvPrefix("APP")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -166,12 +278,64 @@ func (c *AppConfig) Validate() error {
 	if c.RabbitMQ.Password == "" {
 		return errors.New("rabbitmq.password required (ENV: APP_RABBITMQ_PASSWORD)")
 	}
-	if c.IsProd() && (c.Auth.TokenSecret == "" || c.Auth.TokenSecret == "12345678") {
-		return errors.New("auth.token_secret must be a strong, non-default value in production (ENV: APP_AUTH_TOKEN_SECRET)")
+	if c.Auth.MaxSessions < 0 {
+		return errors.New("auth.max_sessions must be >= 0")
+	}
+	switch c.Auth.SessionLimitPolicy {
+	case SessionPolicyEvictOldest, SessionPolicyReject:
+	default:
+		return fmt.Errorf("无效的 auth.session_limit_policy: %s, 必须是 %s/%s 之一", c.Auth.SessionLimitPolicy, SessionPolicyEvictOldest, SessionPolicyReject)
+	}
+	if g := c.Auth.LoginGuard; g.MaxFailures < 0 || g.MaxFailuresPerIP < 0 ||
+		g.FailureWindow <= 0 || g.LockoutBase <= 0 || g.LockoutMax < g.LockoutBase {
+		return errors.New("auth.login_guard: thresholds must be >= 0, failure_window and lockout_base must be > 0, lockout_max must be >= lockout_base")
+	}
+	if c.Auth.MFA.Issuer == "" || c.Auth.MFA.PendingExpire <= 0 || c.Auth.MFA.MaxAttempts <= 0 {
+		return errors.New("auth.mfa: issuer required, pending_expire and max_attempts must be > 0")
+	}
+	if c.User.PasswordResetExpire <= 0 || c.User.EmailVerifyExpire <= 0 || c.User.VerifyResendInterval <= 0 {
+		return errors.New("user.password_reset_expire, user.email_verify_expire and user.verify_resend_interval must be > 0")
+	}
+	switch c.Mail.Driver {
+	case "log":
+	case "file":
+		if c.Mail.FilePath == "" {
+			return errors.New("mail.file_path required when mail.driver is file")
+		}
+	default:
+		return fmt.Errorf("无效的 mail.driver: %s, 必须是 log/file 之一", c.Mail.Driver)
+	}
+	if c.Order.PayTimeout <= 0 {
+		return errors.New("order.pay_timeout must be > 0")
+	}
+	if c.OrderMQ.MaxRetries < 0 || c.OrderMQ.RetryBaseTTLMs <= 0 {
+		return errors.New("order_mq.max_retries must be >= 0 and order_mq.retry_base_ttl_ms must be > 0")
+	}
+	if len(c.Auth.SigningKeys) > 0 && c.Auth.ActiveKid == "" {
+		return errors.New("auth.active_kid required when auth.signing_keys is set")
+	}
+	if len(c.Auth.SigningKeys) == 0 && c.Auth.ActiveKid != "" {
+		return errors.New("auth.active_kid set but auth.signing_keys is empty")
+	}
+	if c.IsProd() {
+		if len(c.Auth.SigningKeys) == 0 && c.Auth.TokenSecret == "" {
+			return errors.New("auth.token_secret or auth.signing_keys required in production (ENV: APP_AUTH_TOKEN_SECRET)")
+		}
+		// 配置了 signing_keys 时 token_secret 仍会加入验签密钥集合，弱密钥同样可被用来伪造令牌
+		if c.Auth.TokenSecret != "" && isWeakTokenSecret(c.Auth.TokenSecret) {
+			return fmt.Errorf("auth.token_secret must be a non-default value of at least %d bytes in production, or empty once auth.signing_keys is set (ENV: APP_AUTH_TOKEN_SECRET)", minTokenSecretLen)
+		}
 	}
 	return nil
 }
 
+// minTokenSecretLen HS256 共享密钥的最小长度（256 位）
+const minTokenSecretLen = 32
+
+func isWeakTokenSecret(secret string) bool {
+	return secret == "12345678" || len(secret) < minTokenSecretLen
+}
+
 // ===== 环境判断 =====
 
 func (c *AppConfig) IsDev() bool  { return strings.ToUpper(c.App.Env) == EnvDev }
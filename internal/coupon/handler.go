@@ -77,7 +77,7 @@ func (h *Handler) GrantCoupon(c *gin.Context) {
 		return
 	}
 
-	response.Write(c, nil, formatUserCoupon(uc))
+	response.Write(c, nil, FormatUserCoupon(uc))
 }
 
 // ListUserCoupons 用户查看自己的券
@@ -106,7 +106,7 @@ func (h *Handler) ListUserCoupons(c *gin.Context) {
 
 	items := make([]UserCouponItem, 0, len(coupons))
 	for _, uc := range coupons {
-		items = append(items, *formatUserCoupon(uc))
+		items = append(items, *FormatUserCoupon(uc))
 	}
 
 	response.Write(c, nil, ListUserCouponsResponse{
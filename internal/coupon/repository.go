@@ -81,6 +81,15 @@ func (r *Repository) CountUserCouponsByTemplate(ctx context.Context, userID, tem
 	return count, err
 }
 
+// CountUnusedUserCoupons 统计用户未使用且未过期的优惠券数量
+func (r *Repository) CountUnusedUserCoupons(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.GetDB(ctx).Model(&model.UserCoupon{}).
+		Where("user_id = ? AND status = ? AND expire_time > ?", userID, model.UserCouponStatusUnused, time.Now()).
+		Count(&count).Error
+	return count, err
+}
+
 // ListUserCoupons 用户查看自己的优惠券
 func (r *Repository) ListUserCoupons(ctx context.Context, userID uuid.UUID, pageNum, pageSize int) ([]*model.UserCoupon, int64, error) {
 	var coupons []*model.UserCoupon
@@ -103,6 +112,31 @@ func (r *Repository) ListUserCoupons(ctx context.Context, userID uuid.UUID, page
 	return coupons, total, err
 }
 
+// ListAllUserCoupons 返回用户的全部优惠券（模板已删除时仍预载），用于导出个人数据
+func (r *Repository) ListAllUserCoupons(ctx context.Context, userID uuid.UUID) ([]*model.UserCoupon, error) {
+	var coupons []*model.UserCoupon
+	err := r.GetDB(ctx).
+		Preload("Template", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&coupons).Error
+	return coupons, err
+}
+
+// GetUserCoupon 获取用户券（预载模板，模板已删除时仍返回，用于订单详情展示）
+func (r *Repository) GetUserCoupon(ctx context.Context, id uuid.UUID) (*model.UserCoupon, error) {
+	var uc model.UserCoupon
+	err := r.GetDB(ctx).
+		Preload("Template", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
+		Where("id = ?", id).
+		First(&uc).Error
+	return &uc, err
+}
+
 // GetUserCouponForUpdate 获取用户券（带行锁+预载模板，事务内使用）
 func (r *Repository) GetUserCouponForUpdate(ctx context.Context, id, userID uuid.UUID) (*model.UserCoupon, error) {
 	var uc model.UserCoupon
@@ -139,7 +173,7 @@ func (r *Repository) UseCouponWithVersion(ctx context.Context, id, userID uuid.U
 	return nil
 }
 
-// ReturnCoupon 超时退券
+// ReturnCoupon 超时/取消订单退券
 func (r *Repository) ReturnCoupon(ctx context.Context, userCouponID uuid.UUID) error {
 	result := r.GetDB(ctx).Model(&model.UserCoupon{}).
 		Where("id = ? AND status = ?", userCouponID, model.UserCouponStatusUsed).
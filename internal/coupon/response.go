@@ -60,7 +60,7 @@ func formatTemplate(t *model.CouponTemplate) *TemplateItem {
 	}
 }
 
-func formatUserCoupon(uc *model.UserCoupon) *UserCouponItem {
+func FormatUserCoupon(uc *model.UserCoupon) *UserCouponItem {
 	item := &UserCouponItem{
 		ID:         uc.ID.String(),
 		TemplateID: uc.TemplateID.String(),
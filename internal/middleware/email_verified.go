@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/pkg/response"
+	"e-commerce/internal/user"
+	"e-commerce/pkg/errno"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireEmailVerified 开启 user.require_email_verified 时要求当前用户已验证邮箱，需放在 AccessTokenAuth 之后
+func RequireEmailVerified(userSvc *user.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		accountInfo := identity.GetAccountInfo(ctx)
+		if accountInfo == nil {
+			response.Write(c, errno.ErrGetAccountInfo, nil)
+			c.Abort()
+			return
+		}
+
+		if err := userSvc.CheckEmailVerified(ctx, accountInfo.AccountId); err != nil {
+			response.Write(c, err, nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
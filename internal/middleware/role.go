@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/config"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/response"
+	"e-commerce/pkg/errno"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole 要求当前用户具备 roles 中任一角色，需放在 AccessTokenAuth 之后
+func RequireRole(roles ...model.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountInfo := identity.GetAccountInfo(c.Request.Context())
+		if accountInfo == nil {
+			response.Write(c, errno.ErrGetAccountInfo, nil)
+			c.Abort()
+			return
+		}
+
+		for _, role := range roles {
+			if accountInfo.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		response.Write(c, errno.ErrAuthNotPermission, nil)
+		c.Abort()
+	}
+}
+
+// RequireMFA auth.mfa.required_roles 中的角色必须通过两步验证登录，需放在 AccessTokenAuth 之后
+func RequireMFA(cfg *config.AuthSection) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accountInfo := identity.GetAccountInfo(c.Request.Context())
+		if accountInfo == nil {
+			response.Write(c, errno.ErrGetAccountInfo, nil)
+			c.Abort()
+			return
+		}
+
+		if !accountInfo.MFA {
+			for _, role := range cfg.MFA.RequiredRoles {
+				if string(accountInfo.Role) == role {
+					response.Write(c, errno.ErrAuthMFARequired, nil)
+					c.Abort()
+					return
+				}
+			}
+		}
+		c.Next()
+	}
+}
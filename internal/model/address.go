@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// ConstraintAddressUserDefault 每个用户最多一个默认地址（is_default 上的部分唯一索引）
+	ConstraintAddressUserDefault = "uni_address_user_default"
+)
+
+// AddressInfo 收货信息，地址簿与订单快照共用
+type AddressInfo struct {
+	Receiver string `gorm:"column:receiver;type:varchar(64);not null;default:''"`
+	Phone    string `gorm:"column:phone;type:varchar(32);not null;default:''"`
+	Province string `gorm:"column:province;type:varchar(64);not null;default:''"`
+	City     string `gorm:"column:city;type:varchar(64);not null;default:''"`
+	District string `gorm:"column:district;type:varchar(64);not null;default:''"`
+	Detail   string `gorm:"column:detail;type:varchar(255);not null;default:''"`
+}
+
+// Address 用户收货地址
+type Address struct {
+	ID          uuid.UUID `gorm:"column:id;primaryKey;type:uuid"`
+	UserID      uuid.UUID `gorm:"column:user_id;type:uuid;not null;index;uniqueIndex:uni_address_user_default,where:is_default"`
+	AddressInfo `gorm:"embedded"`
+	IsDefault   bool      `gorm:"column:is_default;not null;default:false"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (a *Address) BeforeCreate(tx *gorm.DB) (err error) {
+	if a.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		a.ID = id
+	}
+	return nil
+}
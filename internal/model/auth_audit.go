@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuthAuditEvent 认证审计事件类型
+type AuthAuditEvent string
+
+const (
+	// AuthAuditRefreshTokenReuse 已轮换的刷新令牌被再次使用，视为令牌被盗，整个会话被注销
+	AuthAuditRefreshTokenReuse AuthAuditEvent = "refresh_token_reuse"
+)
+
+// AuthAuditLog 认证相关的安全事件记录
+type AuthAuditLog struct {
+	ID        uuid.UUID      `gorm:"column:id;primaryKey;type:uuid"`
+	UserID    uuid.UUID      `gorm:"column:user_id;type:uuid;not null;index:idx_auth_audit_user_created,priority:1"`
+	SessionID string         `gorm:"column:session_id;type:varchar(64);not null;default:''"`
+	Event     AuthAuditEvent `gorm:"column:event;type:varchar(32);not null"`
+	IP        string         `gorm:"column:ip;type:varchar(64);not null;default:''"`
+	UserAgent string         `gorm:"column:user_agent;type:varchar(512);not null;default:''"`
+	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime;index:idx_auth_audit_user_created,priority:2"`
+}
+
+func (AuthAuditLog) TableName() string {
+	return "auth_audit_logs"
+}
+
+func (l *AuthAuditLog) BeforeCreate(tx *gorm.DB) (err error) {
+	if l.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		l.ID = id
+	}
+	return nil
+}
@@ -18,23 +18,30 @@ const (
 	OrderStatusCompleted  OrderStatus = 1
 	OrderStatusCancelled  OrderStatus = 2
 	OrderStatusTimeout    OrderStatus = 3
+	OrderStatusRefunding  OrderStatus = 4 // 存在待审核的退款申请
+	OrderStatusRefunded   OrderStatus = 5 // 全部商品已退款
 )
 
-// Order 订单表
-// TODO(10)[2026-05-04] 使得订单表字段不用与Product表绑定
+// Order 订单表，商品明细见 OrderItem
 type Order struct {
 	ID             uuid.UUID   `gorm:"column:id;primaryKey;type:uuid"`
 	UserID         uuid.UUID   `gorm:"column:user_id;type:uuid"`
-	ProductId      uuid.UUID   `gorm:"column:product_id;type:uuid"`
-	Quantity       int         `gorm:"column:quantity;not null;check:quantity >= 0"`
-	SnapshotTitle  string      `gorm:"column:snapshot_title;varchar(255);not null"`
-	SnapshotPrice  float64     `gorm:"column:snapshot_price;decimal(16,2);not null"`
+	TotalAmount    float64     `gorm:"column:total_amount;type:decimal(16,2);not null;default:0"`
 	Status         OrderStatus `gorm:"column:status;type:smallint;not null"`
 	UserCouponID   *uuid.UUID  `gorm:"column:user_coupon_id;type:uuid"`
 	DiscountAmount float64     `gorm:"column:discount_amount;decimal(16,2);not null;default:0"`
+	RefundedAmount float64     `gorm:"column:refunded_amount;type:decimal(16,2);not null;default:0"`
 	IdempotencyKey string      `gorm:"column:idempotency_key;uniqueIndex:uni_order_idempotency_key;type:varchar(64);not null;"`
-	CreatedAt      time.Time   `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt      time.Time   `gorm:"column:updated_at;autoUpdateTime"`
+	PayDeadline    time.Time   `gorm:"column:pay_deadline;not null;comment:支付截止时间，超过后自动关单"`
+	// AddressID 下单时选择的收货地址，为空表示订单没有收货信息
+	AddressID *uuid.UUID `gorm:"column:address_id;type:uuid"`
+	// SnapshotAddress 下单时的收货信息快照，之后修改或删除地址不影响订单
+	SnapshotAddress AddressInfo `gorm:"embedded;embeddedPrefix:snapshot_"`
+	CreatedAt       time.Time   `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt       time.Time   `gorm:"column:updated_at;autoUpdateTime"`
+
+	Items   []OrderItem          `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
+	History []OrderStatusHistory `gorm:"foreignKey:OrderID;constraint:OnDelete:CASCADE"`
 }
 
 func (o *Order) BeforeCreate(tx *gorm.DB) (err error) {
@@ -47,3 +54,80 @@ func (o *Order) BeforeCreate(tx *gorm.DB) (err error) {
 	}
 	return nil
 }
+
+// PayableAmount 实付金额（商品总额 - 优惠，最低为 0）
+func (o *Order) PayableAmount() float64 {
+	payable := o.TotalAmount - o.DiscountAmount
+	if payable < 0 {
+		return 0
+	}
+	return payable
+}
+
+// OrderItem 订单商品明细（下单时快照商品标题与价格）
+type OrderItem struct {
+	ID               uuid.UUID `gorm:"column:id;primaryKey;type:uuid"`
+	OrderID          uuid.UUID `gorm:"column:order_id;type:uuid;index;not null"`
+	ProductID        uuid.UUID `gorm:"column:product_id;type:uuid;not null"`
+	Quantity         int       `gorm:"column:quantity;not null;check:quantity > 0"`
+	SnapshotTitle    string    `gorm:"column:snapshot_title;type:varchar(255);not null"`
+	SnapshotPrice    float64   `gorm:"column:snapshot_price;type:decimal(16,2);not null"`
+	RefundedQuantity int       `gorm:"column:refunded_quantity;not null;default:0;comment:已退款数量"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (oi *OrderItem) BeforeCreate(tx *gorm.DB) (err error) {
+	if oi.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		oi.ID = id
+	}
+	return nil
+}
+
+// Subtotal 明细小计
+func (oi *OrderItem) Subtotal() float64 {
+	return oi.SnapshotPrice * float64(oi.Quantity)
+}
+
+// RefundableQuantity 剩余可退数量
+func (oi *OrderItem) RefundableQuantity() int {
+	return oi.Quantity - oi.RefundedQuantity
+}
+
+type OrderActorType string
+
+const (
+	OrderActorBuyer  OrderActorType = "buyer"
+	OrderActorSeller OrderActorType = "seller"
+	OrderActorSystem OrderActorType = "system"
+)
+
+// OrderStatusHistory 订单状态流转记录，创建订单时 FromStatus 为空
+type OrderStatusHistory struct {
+	ID         uuid.UUID      `gorm:"column:id;primaryKey;type:uuid"`
+	OrderID    uuid.UUID      `gorm:"column:order_id;type:uuid;index;not null"`
+	FromStatus *OrderStatus   `gorm:"column:from_status;type:smallint"`
+	ToStatus   OrderStatus    `gorm:"column:to_status;type:smallint;not null"`
+	ActorType  OrderActorType `gorm:"column:actor_type;type:varchar(16);not null"`
+	ActorID    *uuid.UUID     `gorm:"column:actor_id;type:uuid"`
+	Note       string         `gorm:"column:note;type:varchar(255);not null;default:''"`
+	CreatedAt  time.Time      `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (OrderStatusHistory) TableName() string {
+	return "order_status_history"
+}
+
+func (h *OrderStatusHistory) BeforeCreate(tx *gorm.DB) (err error) {
+	if h.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		h.ID = id
+	}
+	return nil
+}
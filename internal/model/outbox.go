@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxStatusPending OutboxStatus = "pending"
+	OutboxStatusSent    OutboxStatus = "sent"
+)
+
+// OutboxMessage 事务性发件箱：与业务数据在同一事务内写入，由 relay 异步投递到 RabbitMQ
+type OutboxMessage struct {
+	ID            uuid.UUID    `gorm:"column:id;primaryKey;type:uuid"`
+	Exchange      string       `gorm:"column:exchange;type:varchar(128);not null;default:''"`
+	RoutingKey    string       `gorm:"column:routing_key;type:varchar(128);not null"`
+	Body          string       `gorm:"column:body;type:text;not null"`
+	Status        OutboxStatus `gorm:"column:status;type:varchar(16);not null;default:'pending';index:idx_outbox_status_next_attempt,priority:1"`
+	Attempts      int          `gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt time.Time    `gorm:"column:next_attempt_at;not null;index:idx_outbox_status_next_attempt,priority:2"`
+	LastError     string       `gorm:"column:last_error;type:text;not null;default:''"`
+	SentAt        *time.Time   `gorm:"column:sent_at"`
+	CreatedAt     time.Time    `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
+
+func (m *OutboxMessage) BeforeCreate(tx *gorm.DB) (err error) {
+	if m.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		m.ID = id
+	}
+	if m.NextAttemptAt.IsZero() {
+		m.NextAttemptAt = time.Now()
+	}
+	return nil
+}
@@ -23,17 +23,18 @@ func (s ProductStatus) IsValid() bool {
 }
 
 type Product struct {
-	ID          uuid.UUID     `gorm:"column:id;type:uuid;primaryKey"`
-	Publisher   uuid.UUID     `gorm:"column:publisher;type:uuid;not null"`
-	Name        string        `gorm:"column:name;type:varchar(255);not null"`
-	Description string        `gorm:"column:description;type:text;not null"`
-	Price       float64       `gorm:"column:price;type:decimal(16,2);not null"`
-	Stock       int           `gorm:"column:stock;not null;default:0;check:stock >= 0"`
-	FrozenStock int           `gorm:"column:frozen_stock;not null;default:0;check:stock >= 0"`
-	Status      ProductStatus `gorm:"column:status;type:varchar(16);not null;default:'active'"`
-	Version     int           `gorm:"column:version;not null;default:0"`
-	CreatedAt   time.Time     `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt   time.Time     `gorm:"column:updated_at;autoUpdateTime"`
+	ID                uuid.UUID     `gorm:"column:id;type:uuid;primaryKey"`
+	Publisher         uuid.UUID     `gorm:"column:publisher;type:uuid;not null"`
+	Name              string        `gorm:"column:name;type:varchar(255);not null"`
+	Description       string        `gorm:"column:description;type:text;not null"`
+	Price             float64       `gorm:"column:price;type:decimal(16,2);not null"`
+	Stock             int           `gorm:"column:stock;not null;default:0;check:stock >= 0;comment:可售库存"`
+	FrozenStock       int           `gorm:"column:frozen_stock;not null;default:0;check:frozen_stock >= 0;comment:已下单未支付的冻结库存"`
+	Status            ProductStatus `gorm:"column:status;type:varchar(16);not null;default:'active'"`
+	PayTimeoutSeconds int           `gorm:"column:pay_timeout_seconds;not null;default:0;comment:支付时限（秒），0 表示使用全局配置"`
+	Version           int           `gorm:"column:version;not null;default:0"`
+	CreatedAt         time.Time     `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt         time.Time     `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 func (p *Product) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefundStatus string
+
+const (
+	RefundStatusPending  RefundStatus = "pending"
+	RefundStatusApproved RefundStatus = "approved"
+	RefundStatusRejected RefundStatus = "rejected"
+)
+
+// RefundRequest 退款申请，按订单明细（单个商品）发起，由商品发布者审核
+type RefundRequest struct {
+	ID          uuid.UUID    `gorm:"column:id;primaryKey;type:uuid"`
+	OrderID     uuid.UUID    `gorm:"column:order_id;type:uuid;index;not null"`
+	OrderItemID uuid.UUID    `gorm:"column:order_item_id;type:uuid;index;not null"`
+	UserID      uuid.UUID    `gorm:"column:user_id;type:uuid;index;not null"`
+	ProductID   uuid.UUID    `gorm:"column:product_id;type:uuid;not null"`
+	Publisher   uuid.UUID    `gorm:"column:publisher;type:uuid;index;not null"`
+	Quantity    int          `gorm:"column:quantity;not null;check:quantity > 0"`
+	Amount      float64      `gorm:"column:amount;type:decimal(16,2);not null;default:0;comment:审核通过时实际退款金额"`
+	Reason      string       `gorm:"column:reason;type:varchar(512);not null"`
+	Status      RefundStatus `gorm:"column:status;type:varchar(16);not null;default:'pending'"`
+	ReviewNote  string       `gorm:"column:review_note;type:varchar(512);not null;default:''"`
+	ReviewedAt  *time.Time   `gorm:"column:reviewed_at"`
+	CreatedAt   time.Time    `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time    `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (r *RefundRequest) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
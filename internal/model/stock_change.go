@@ -10,10 +10,13 @@ import (
 type StockChangeReason int8
 
 const (
-	StockChangeOrder   StockChangeReason = 1 // 下单扣减
+	StockChangeOrder   StockChangeReason = 1 // 下单直接扣减（历史数据，现由冻结流程替代）
 	StockChangeRefund  StockChangeReason = 2 // 退单归还
-	StockChangeTimeout StockChangeReason = 3 // 超时关单归还
+	StockChangeTimeout StockChangeReason = 3 // 超时关单释放冻结
 	StockChangeManual  StockChangeReason = 4 // 手动调整
+	StockChangeReserve StockChangeReason = 5 // 下单冻结
+	StockChangeCommit  StockChangeReason = 6 // 支付扣减冻结
+	StockChangeRelease StockChangeReason = 7 // 取消订单释放冻结
 )
 
 func (r StockChangeReason) String() string {
@@ -26,21 +29,28 @@ func (r StockChangeReason) String() string {
 		return "timeout"
 	case StockChangeManual:
 		return "manual"
+	case StockChangeReserve:
+		return "reserve"
+	case StockChangeCommit:
+		return "commit"
+	case StockChangeRelease:
+		return "release"
 	default:
 		return "unknown"
 	}
 }
 
 type StockChangeLog struct {
-	ID        uuid.UUID         `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	ProductID uuid.UUID         `gorm:"type:uuid;not null;index"`
-	Quantity  int               `gorm:"not null;comment:变动数量，正数增加负数减少"`
-	Before    int               `gorm:"not null;comment:变动前库存"`
-	After     int               `gorm:"not null;comment:变动后库存"`
-	Reason    StockChangeReason `gorm:"type:smallint;not null;index"`
-	CreatedAt time.Time         `gorm:"not null;index"`
+	ID             uuid.UUID         `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ProductID      uuid.UUID         `gorm:"type:uuid;not null;index"`
+	Quantity       int               `gorm:"not null;comment:变动数量，正数增加负数减少"`
+	Before         int               `gorm:"not null;comment:变动前库存"`
+	After          int               `gorm:"not null;comment:变动后库存"`
+	FrozenQuantity int               `gorm:"not null;default:0;comment:冻结库存变动数量"`
+	Reason         StockChangeReason `gorm:"type:smallint;not null;index"`
+	CreatedAt      time.Time         `gorm:"not null;index"`
 }
 
 func (StockChangeLog) TableName() string {
 	return "stock_change_logs"
-}
\ No newline at end of file
+}
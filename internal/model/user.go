@@ -12,14 +12,34 @@ const (
 	ConstraintUserEmail = "uni_user_user_email"
 )
 
+// UserRole 用户角色：admin 管理平台（优惠券、死信等），seller 发布和管理商品，buyer 默认角色
+type UserRole string
+
+const (
+	UserRoleAdmin  UserRole = "admin"
+	UserRoleSeller UserRole = "seller"
+	UserRoleBuyer  UserRole = "buyer"
+)
+
+func (r UserRole) IsValid() bool {
+	switch r {
+	case UserRoleAdmin, UserRoleSeller, UserRoleBuyer:
+		return true
+	}
+	return false
+}
+
 type User struct {
-	ID        uuid.UUID      `gorm:"primaryKey;type:uuid"`
-	UserName  string         `gorm:"column:user_name;uniqueIndex:uni_user_user_name;type:varchar(30);not null"`
-	Email     string         `gorm:"column:email;uniqueIndex:uni_user_user_email;type:varchar(254);not null"`
-	Password  string         `gorm:"column:password;type:varchar(255);not null"`
-	CreatedAt time.Time      `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt time.Time      `gorm:"column:updated_at;autoUpdateTime"`
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID       uuid.UUID `gorm:"primaryKey;type:uuid"`
+	UserName string    `gorm:"column:user_name;uniqueIndex:uni_user_user_name;type:varchar(30);not null"`
+	Email    string    `gorm:"column:email;uniqueIndex:uni_user_user_email;type:varchar(254);not null"`
+	Password string    `gorm:"column:password;type:varchar(255);not null"`
+	Role     UserRole  `gorm:"column:role;type:varchar(16);not null;default:'buyer'"`
+	// EmailVerifiedAt 邮箱验证时间，为空表示尚未验证
+	EmailVerifiedAt *time.Time     `gorm:"column:email_verified_at"`
+	CreatedAt       time.Time      `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt       time.Time      `gorm:"column:updated_at;autoUpdateTime"`
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
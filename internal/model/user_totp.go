@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserTOTP 用户绑定的 TOTP 验证器（RFC 6238），EnabledAt 为空表示已生成密钥但尚未确认绑定
+type UserTOTP struct {
+	UserID uuid.UUID `gorm:"column:user_id;primaryKey;type:uuid"`
+	// Secret Base32 编码的共享密钥
+	Secret    string     `gorm:"column:secret;type:varchar(64);not null"`
+	EnabledAt *time.Time `gorm:"column:enabled_at"`
+	// LastUsedStep 最近一次验证通过的时间步，同一验证码不能重复使用
+	LastUsedStep int64     `gorm:"column:last_used_step;not null;default:0"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totps"
+}
+
+// UserRecoveryCode 两步验证恢复码，只保存 SHA-256 摘要，每个恢复码只能使用一次
+type UserRecoveryCode struct {
+	ID        uuid.UUID  `gorm:"column:id;primaryKey;type:uuid"`
+	UserID    uuid.UUID  `gorm:"column:user_id;type:uuid;not null;index:idx_recovery_code_user_hash,priority:1"`
+	CodeHash  string     `gorm:"column:code_hash;type:varchar(64);not null;index:idx_recovery_code_user_hash,priority:2"`
+	UsedAt    *time.Time `gorm:"column:used_at"`
+	CreatedAt time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (UserRecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
+
+func (c *UserRecoveryCode) BeforeCreate(tx *gorm.DB) (err error) {
+	if c.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			return err
+		}
+		c.ID = id
+	}
+	return nil
+}
@@ -11,6 +11,13 @@ const (
 	ConstraintWalletLogIdempotencyKey = "uni_wallet_log_idempotency_key"
 )
 
+// WalletLog.Type 取值
+const (
+	WalletLogTypeDeposit = "deposit"
+	WalletLogTypePayment = "payment"
+	WalletLogTypeRefund  = "refund"
+)
+
 type UserWallet struct {
 	UserID    uuid.UUID `gorm:"column:user_id;primaryKey;type:uuid"`
 	Balance   float64   `gorm:"column:balance;type:decimal(16,2);not null;default:0"`
@@ -20,12 +27,13 @@ type UserWallet struct {
 
 type WalletLog struct {
 	ID             uuid.UUID `gorm:"column:id;primaryKey;type:uuid"`
-	UserID         uuid.UUID `gorm:"column:user_id;type:uuid;not null"`
+	UserID         uuid.UUID `gorm:"column:user_id;type:uuid;not null;index:idx_wallet_logs_user_created,priority:1"`
 	SessionID      string    `gorm:"column:session_id;not null"`
-	Amount         float64   `gorm:"column:amount;type:decimal(16,2);not null;"`
+	Amount         float64   `gorm:"column:amount;type:decimal(16,2);not null;comment:变动金额，正数入账负数出账"`
+	BalanceAfter   float64   `gorm:"column:balance_after;type:decimal(16,2);not null;default:0;comment:本条流水入账后的钱包余额"`
 	Type           string    `gorm:"column:type;type:varchar(20);not null;"`
 	IdempotencyKey string    `gorm:"column:idempotency_key;uniqueIndex:uni_wallet_log_idempotency_key;type:varchar(64);not null;"`
-	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime"`
+	CreatedAt      time.Time `gorm:"column:created_at;autoCreateTime;index:idx_wallet_logs_user_created,priority:2,sort:desc"`
 }
 
 func (wl *WalletLog) BeforeCreate(tx *gorm.DB) (err error) {
@@ -0,0 +1,167 @@
+package order
+
+import (
+	"context"
+	"e-commerce/internal/config"
+	"e-commerce/pkg/errno"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errReplayNacked = errors.New("broker nacked the replayed message")
+
+// DeadLetter 死信队列中的一条订单超时消息
+type DeadLetter struct {
+	MessageID      string
+	Body           string
+	RetryCount     int
+	Reason         string
+	DeadLetteredAt string
+}
+
+// DeadLetterQueue 订单超时死信队列的查看与重放（同一时刻只允许一个操作，避免互相拿走未确认的消息）
+type DeadLetterQueue struct {
+	cfg *config.OrderMQConfig
+
+	mu sync.Mutex
+	ch *amqp.Channel
+}
+
+func NewDeadLetterQueue(cfg *config.OrderMQConfig) *DeadLetterQueue {
+	return &DeadLetterQueue{cfg: cfg}
+}
+
+// Attach 绑定新 Channel 并开启 publisher confirm，作为 mq.SetupFunc 注册，重连后自动重新绑定
+func (q *DeadLetterQueue) Attach(ch *amqp.Channel) error {
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("开启 publisher confirm 失败: %w", err)
+	}
+	q.mu.Lock()
+	q.ch = ch
+	q.mu.Unlock()
+	return nil
+}
+
+// List 查看队首最多 limit 条死信（取出后全部放回队列），返回死信总数
+func (q *DeadLetterQueue) List(ctx context.Context, limit int) ([]DeadLetter, int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.ch == nil || q.ch.IsClosed() {
+		return nil, 0, errno.ErrMQUnavailable
+	}
+	queue, err := q.ch.QueueDeclarePassive(q.cfg.DeadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return nil, 0, errno.ErrMQUnavailable.WithRaw(err)
+	}
+
+	letters := make([]DeadLetter, 0, limit)
+	var lastTag uint64
+	for len(letters) < limit && len(letters) < queue.Messages {
+		d, ok, err := q.ch.Get(q.cfg.DeadLetterQueue, false)
+		if err != nil {
+			return nil, 0, errno.ErrMQUnavailable.WithRaw(err)
+		}
+		if !ok {
+			break
+		}
+		lastTag = d.DeliveryTag
+		letters = append(letters, toDeadLetter(d))
+	}
+	if lastTag != 0 {
+		if err := q.ch.Nack(lastTag, true, true); err != nil {
+			return nil, 0, errno.ErrMQUnavailable.WithRaw(err)
+		}
+	}
+	return letters, queue.Messages, nil
+}
+
+// Replay 将死信重新发布到订单超时消费队列（重试次数清零）；messageIDs 为空时重放全部，返回重放条数
+func (q *DeadLetterQueue) Replay(ctx context.Context, messageIDs []string) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.ch == nil || q.ch.IsClosed() {
+		return 0, errno.ErrMQUnavailable
+	}
+	queue, err := q.ch.QueueDeclarePassive(q.cfg.DeadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return 0, errno.ErrMQUnavailable.WithRaw(err)
+	}
+
+	wanted := make(map[string]bool, len(messageIDs))
+	for _, id := range messageIDs {
+		wanted[id] = true
+	}
+
+	replayed := 0
+	var skippedTag uint64
+	// 只遍历开始时已有的消息，未命中的消息在结束时统一放回
+	for i := 0; i < queue.Messages; i++ {
+		d, ok, err := q.ch.Get(q.cfg.DeadLetterQueue, false)
+		if err != nil {
+			return replayed, errno.ErrMQUnavailable.WithRaw(err)
+		}
+		if !ok {
+			break
+		}
+		if len(wanted) > 0 && !wanted[d.MessageId] {
+			skippedTag = d.DeliveryTag
+			continue
+		}
+		if err := q.replayOne(ctx, d); err != nil {
+			_ = q.ch.Nack(d.DeliveryTag, true, true)
+			return replayed, errno.ErrMQUnavailable.WithRaw(err)
+		}
+		if err := d.Ack(false); err != nil {
+			return replayed, errno.ErrMQUnavailable.WithRaw(err)
+		}
+		replayed++
+	}
+	if skippedTag != 0 {
+		if err := q.ch.Nack(skippedTag, true, true); err != nil {
+			return replayed, errno.ErrMQUnavailable.WithRaw(err)
+		}
+	}
+	return replayed, nil
+}
+
+func (q *DeadLetterQueue) replayOne(ctx context.Context, d amqp.Delivery) error {
+	ctx, cancel := context.WithTimeout(ctx, republishTimeout)
+	defer cancel()
+
+	confirm, err := q.ch.PublishWithDeferredConfirmWithContext(ctx, q.cfg.Exchange, q.cfg.RoutingKey, false, false, amqp.Publishing{
+		MessageId:    d.MessageId,
+		DeliveryMode: amqp.Persistent,
+		Body:         d.Body,
+	})
+	if err != nil {
+		return err
+	}
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errReplayNacked
+	}
+	return nil
+}
+
+func toDeadLetter(d amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID:  d.MessageId,
+		Body:       string(d.Body),
+		RetryCount: getRetryCount(d.Headers),
+	}
+	if v, ok := d.Headers[deadLetterReasonHeader].(string); ok {
+		letter.Reason = v
+	}
+	if v, ok := d.Headers[deadLetterAtHeader].(string); ok {
+		letter.DeadLetteredAt = v
+	}
+	return letter
+}
@@ -0,0 +1,72 @@
+package order
+
+import (
+	"e-commerce/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDeadLetterLimit = 20
+
+type DeadLetterHandler struct {
+	dlq *DeadLetterQueue
+}
+
+func NewDeadLetterHandler(dlq *DeadLetterQueue) *DeadLetterHandler {
+	return &DeadLetterHandler{dlq: dlq}
+}
+
+// ListDeadLetters 查看订单超时死信队列
+func (h *DeadLetterHandler) ListDeadLetters(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var query ListDeadLettersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	if query.Limit == 0 {
+		query.Limit = defaultDeadLetterLimit
+	}
+
+	letters, total, err := h.dlq.List(ctx, query.Limit)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	items := make([]DeadLetterItem, 0, len(letters))
+	for _, l := range letters {
+		items = append(items, DeadLetterItem{
+			MessageID:      l.MessageID,
+			Body:           l.Body,
+			RetryCount:     l.RetryCount,
+			Reason:         l.Reason,
+			DeadLetteredAt: l.DeadLetteredAt,
+		})
+	}
+
+	response.Write(c, nil, ListDeadLettersResponse{
+		Messages: items,
+		Total:    total,
+	})
+}
+
+// ReplayDeadLetters 将死信重新投递到订单超时消费队列
+func (h *DeadLetterHandler) ReplayDeadLetters(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var body ReplayDeadLettersBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	replayed, err := h.dlq.Replay(ctx, body.MessageIDs)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	response.Write(c, nil, ReplayDeadLettersResponse{Replayed: replayed})
+}
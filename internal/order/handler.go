@@ -4,6 +4,7 @@ import (
 	"e-commerce/internal/app/identity"
 	"e-commerce/internal/pkg/response"
 	"e-commerce/pkg/errno"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -33,14 +34,22 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		return
 	}
 
-	productID, err := uuid.Parse(body.ProductID)
-	if err != nil {
-		response.WriteInvalidParam(c, err)
-		return
+	items := make([]CreateOrderItemParam, 0, len(body.Items))
+	for _, item := range body.Items {
+		productID, err := uuid.Parse(item.ProductID)
+		if err != nil {
+			response.WriteInvalidParam(c, err)
+			return
+		}
+		items = append(items, CreateOrderItemParam{
+			ProductID: productID,
+			Quantity:  item.Quantity,
+		})
 	}
 
 	var couponID uuid.UUID
 	if body.CouponID != "" {
+		var err error
 		couponID, err = uuid.Parse(body.CouponID)
 		if err != nil {
 			response.WriteInvalidParam(c, err)
@@ -48,12 +57,101 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		}
 	}
 
-	if err := h.svc.CreateOrder(ctx, accountInfo.AccountId, CreateOrderParam{
-		ProductID:      productID,
-		Quantity:       body.Quantity,
+	var addressID uuid.UUID
+	if body.AddressID != "" {
+		var err error
+		addressID, err = uuid.Parse(body.AddressID)
+		if err != nil {
+			response.WriteInvalidParam(c, err)
+			return
+		}
+	}
+
+	o, err := h.svc.CreateOrder(ctx, accountInfo.AccountId, CreateOrderParam{
+		Items:          items,
 		UserCouponID:   couponID,
+		AddressID:      addressID,
+		IdempotencyKey: body.IdempotencyKey,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	response.Write(c, nil, CreateOrderResponse{
+		OrderID:     o.ID.String(),
+		PayDeadline: o.PayDeadline.Format(time.RFC3339),
+	})
+}
+
+// PayOrder 使用钱包余额支付订单
+func (h *Handler) PayOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithOrderID
+	var body PayOrderBody
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	orderID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	o, err := h.svc.PayOrder(ctx, PayOrderParam{
+		OrderID:        orderID,
+		UserID:         accountInfo.AccountId,
+		SessionID:      accountInfo.SessionID,
 		IdempotencyKey: body.IdempotencyKey,
-	}); err != nil {
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	response.Write(c, nil, PayOrderResponse{
+		OrderID:    o.ID.String(),
+		PaidAmount: o.PayableAmount(),
+		Status:     int(o.Status),
+	})
+}
+
+// CancelOrder 用户取消未支付的订单
+func (h *Handler) CancelOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithOrderID
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	orderID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.svc.CancelOrder(ctx, accountInfo.AccountId, orderID); err != nil {
 		response.Write(c, err, nil)
 		return
 	}
@@ -61,6 +159,37 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 	response.Write(c, nil, nil)
 }
 
+// GetOrder 用户查看订单详情
+func (h *Handler) GetOrder(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithOrderID
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	orderID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	detail, err := h.svc.GetOrderDetail(ctx, accountInfo.AccountId, orderID)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	response.Write(c, nil, FormatOrderDetail(detail))
+}
+
 // ListOrders 用户查看自己的订单列表
 func (h *Handler) ListOrders(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -2,28 +2,47 @@ package order
 
 import (
 	"context"
+	"e-commerce/internal/config"
 	"e-commerce/pkg/clog"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
 )
 
-const maxRetryHeader = "x-retry-count"
-const maxRetries = 3
+const (
+	// retryCountHeader 已重试次数，随重新发布的消息一起写入
+	retryCountHeader = "x-retry-count"
+	// deadLetterReasonHeader / deadLetterAtHeader 进入死信队列的原因与时间
+	deadLetterReasonHeader = "x-dead-letter-reason"
+	deadLetterAtHeader     = "x-dead-lettered-at"
+
+	republishTimeout = 5 * time.Second
+)
+
+var errRepublishNacked = errors.New("broker nacked the republished message")
 
 type MqHandler struct {
 	svc *Service
+	cfg *config.OrderMQConfig
 }
 
-func NewMqHandler(svc *Service) *MqHandler {
+func NewMqHandler(svc *Service, cfg *config.OrderMQConfig) *MqHandler {
 	return &MqHandler{
 		svc: svc,
+		cfg: cfg,
 	}
 }
 
+// ListenTimeout 在 ch 上注册订单超时消费者；Channel 关闭后协程退出，由 mq.Manager 重连后再次调用。
+// ch 开启 publisher confirm，重试/死信消息经 broker 确认后才确认原消息
 func (h *MqHandler) ListenTimeout(ctx context.Context, ch *amqp.Channel, queueName string) error {
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("开启 publisher confirm 失败: %w", err)
+	}
 	msgs, err := ch.Consume(
 		queueName,
 		"",
@@ -50,10 +69,10 @@ func (h *MqHandler) ListenTimeout(ctx context.Context, ch *amqp.Channel, queueNa
 				return
 			case d, ok := <-msgs:
 				if !ok {
-					clog.L(ctx).Info("订单超时消息通道已关闭")
+					clog.L(ctx).Info("订单超时消息通道已关闭，等待重连后重新注册")
 					return
 				}
-				h.handleSingleMessage(ctx, d)
+				h.handleSingleMessage(ctx, ch, d)
 			}
 		}
 	}()
@@ -61,55 +80,108 @@ func (h *MqHandler) ListenTimeout(ctx context.Context, ch *amqp.Channel, queueNa
 	return nil
 }
 
-func (h *MqHandler) handleSingleMessage(ctx context.Context, d amqp.Delivery) {
+func (h *MqHandler) handleSingleMessage(ctx context.Context, ch *amqp.Channel, d amqp.Delivery) {
 	logger := clog.L(ctx)
 	orderID, err := uuid.Parse(string(d.Body))
 	if err != nil {
-		logger.Error("无法从消息中解析订单ID",
+		logger.Error("无法从消息中解析订单ID，转入死信队列",
 			zap.String("body", string(d.Body)),
 			zap.String("message_id", d.MessageId),
 		)
-		// 格式错误的消息无法重试，直接丢弃（或可转发到死信队列）
-		_ = d.Reject(false)
+		// 格式错误的消息重试无意义，直接进入死信队列
+		h.deadLetter(ctx, ch, d, fmt.Sprintf("invalid order id: %v", err))
 		return
 	}
 
 	logger.Info("Received a message: " + orderID.String())
 	err = h.svc.HandleOrderTimeout(ctx, orderID)
+	if err == nil {
+		_ = d.Ack(false)
+		return
+	}
 
-	if err != nil {
-		retryCount := getRetryCount(d.Headers)
-		if retryCount >= maxRetries {
-			logger.Error("订单超时处理达到最大重试次数，丢弃消息",
-				zap.String("order_id", orderID.String()),
-				zap.Int("retry_count", retryCount),
-				zap.Error(err),
-			)
-			_ = d.Reject(false) // 不重新入队
-			return
-		}
-		logger.Warn("订单超时处理失败，将重新入队",
+	retryCount := getRetryCount(d.Headers)
+	if retryCount >= h.cfg.MaxRetries {
+		logger.Error("订单超时处理达到最大重试次数，转入死信队列",
 			zap.String("order_id", orderID.String()),
 			zap.Int("retry_count", retryCount),
 			zap.Error(err),
 		)
-		// 更新重试计数并重新入队
-		if d.Headers == nil {
-			d.Headers = amqp.Table{}
-		}
-		d.Headers[maxRetryHeader] = retryCount + 1
-		_ = d.Nack(false, true)
+		h.deadLetter(ctx, ch, d, err.Error())
 		return
 	}
 
+	next := retryCount + 1
+	logger.Warn("订单超时处理失败，延迟后重试",
+		zap.String("order_id", orderID.String()),
+		zap.Int("retry_count", next),
+		zap.Int("delay_ms", h.cfg.RetryTTLMs(next)),
+		zap.Error(err),
+	)
+	headers := copyHeaders(d.Headers)
+	headers[retryCountHeader] = int32(next)
+	h.republish(ctx, ch, d, h.cfg.RetryQueue(next), headers)
+}
+
+// deadLetter 将消息连同失败原因发布到死信队列
+func (h *MqHandler) deadLetter(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, reason string) {
+	headers := copyHeaders(d.Headers)
+	headers[deadLetterReasonHeader] = reason
+	headers[deadLetterAtHeader] = time.Now().Format(time.RFC3339)
+	h.republish(ctx, ch, d, h.cfg.DeadLetterQueue, headers)
+}
+
+// republish 经默认交换机将消息发布到指定队列，broker 确认后才确认原消息；发布失败、被 nack 或等待确认超时时原消息重新入队
+func (h *MqHandler) republish(ctx context.Context, ch *amqp.Channel, d amqp.Delivery, queue string, headers amqp.Table) {
+	pubCtx, cancel := context.WithTimeout(ctx, republishTimeout)
+	defer cancel()
+
+	err := publishConfirmed(pubCtx, ch, queue, amqp.Publishing{
+		Headers:      headers,
+		MessageId:    d.MessageId,
+		DeliveryMode: amqp.Persistent,
+		Body:         d.Body,
+	})
+	if err != nil {
+		clog.L(ctx).Error("重新发布订单超时消息失败，原消息重新入队",
+			zap.String("queue", queue),
+			zap.String("message_id", d.MessageId),
+			zap.Error(err),
+		)
+		_ = d.Nack(false, true)
+		return
+	}
 	_ = d.Ack(false)
 }
 
+func publishConfirmed(ctx context.Context, ch *amqp.Channel, queue string, msg amqp.Publishing) error {
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", queue, false, false, msg)
+	if err != nil {
+		return err
+	}
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errRepublishNacked
+	}
+	return nil
+}
+
+func copyHeaders(headers amqp.Table) amqp.Table {
+	out := make(amqp.Table, len(headers)+2)
+	for k, v := range headers {
+		out[k] = v
+	}
+	return out
+}
+
 func getRetryCount(headers amqp.Table) int {
 	if headers == nil {
 		return 0
 	}
-	v, ok := headers[maxRetryHeader]
+	v, ok := headers[retryCountHeader]
 	if !ok {
 		return 0
 	}
@@ -4,10 +4,16 @@ import (
 	"github.com/google/uuid"
 )
 
+type CreateOrderItemParam struct {
+	ProductID uuid.UUID
+	Quantity  int
+}
+
 type CreateOrderParam struct {
-	ProductID      uuid.UUID
-	Quantity       int
-	UserCouponID   uuid.UUID
+	Items        []CreateOrderItemParam
+	UserCouponID uuid.UUID
+	// AddressID 收货地址，为空时使用默认地址
+	AddressID      uuid.UUID
 	IdempotencyKey string
 }
 
@@ -15,4 +21,11 @@ type ListOrdersParam struct {
 	UserID   uuid.UUID
 	PageNum  int
 	PageSize int
-}
\ No newline at end of file
+}
+
+type PayOrderParam struct {
+	OrderID        uuid.UUID
+	UserID         uuid.UUID
+	SessionID      string
+	IdempotencyKey string
+}
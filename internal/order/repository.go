@@ -7,16 +7,19 @@ import (
 	"e-commerce/internal/pkg/database"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	amqp "github.com/rabbitmq/amqp091-go"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
 	repoErrOrderIdempotencyConflict = errors.New("order idempotency key already exists")
+	repoErrOrderStatusChanged       = errors.New("order status has been changed")
 )
 
 var constraintMap = map[string]error{
@@ -25,20 +28,19 @@ var constraintMap = map[string]error{
 
 type Repository struct {
 	*database.BaseRepo
-	mqCh  *amqp.Channel
 	mqCfg *config.OrderMQConfig
 }
 
-func NewRepository(db *gorm.DB, mqCh *amqp.Channel, cfg *config.OrderMQConfig) *Repository {
+func NewRepository(db *gorm.DB, cfg *config.OrderMQConfig) *Repository {
 	return &Repository{
 		BaseRepo: database.NewBaseRepo(db),
-		mqCh:     mqCh,
 		mqCfg:    cfg,
 	}
 }
 
-func (repo *Repository) SetupMQ(cfg *config.OrderMQConfig) error {
-	mqCh := repo.mqCh
+// SetupMQ 声明订单超时相关的交换机与队列（幂等），作为 mq.SetupFunc 注册，重连后自动重新声明
+func (repo *Repository) SetupMQ(mqCh *amqp.Channel) error {
+	cfg := repo.mqCfg
 
 	err := mqCh.ExchangeDeclare(cfg.Exchange, "direct", true, false, false, false, nil)
 	if err != nil {
@@ -53,27 +55,60 @@ func (repo *Repository) SetupMQ(cfg *config.OrderMQConfig) error {
 		return fmt.Errorf("failed to bind queue %s routing key %s: %w", cfg.ConsumerQueue, cfg.RoutingKey, err)
 	}
 
-	args := amqp.Table{
-		"x-dead-letter-exchange":    cfg.Exchange,
-		"x-dead-letter-routing-key": cfg.RoutingKey,
-		"x-message-ttl":             cfg.TTLMs,
+	// 每次重试一个独立的延迟队列，TTL 按指数增长，到期后回到消费队列
+	for attempt := 1; attempt <= cfg.MaxRetries; attempt++ {
+		_, err = mqCh.QueueDeclare(cfg.RetryQueue(attempt), true, false, false, false, amqp.Table{
+			"x-dead-letter-exchange":    cfg.Exchange,
+			"x-dead-letter-routing-key": cfg.RoutingKey,
+			"x-message-ttl":             cfg.RetryTTLMs(attempt),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to declare retry queue %s: %w", cfg.RetryQueue(attempt), err)
+		}
 	}
 
-	_, err = mqCh.QueueDeclare(
-		cfg.DelayQueue,
-		true,
-		false,
-		false,
-		false,
-		args,
-	)
+	_, err = mqCh.QueueDeclare(cfg.DeadLetterQueue, true, false, false, false, nil)
 	if err != nil {
-		return fmt.Errorf("failed to declare delay queue %s: %w", cfg.DelayQueue, err)
+		return fmt.Errorf("failed to declare dead letter queue %s: %w", cfg.DeadLetterQueue, err)
 	}
 	return nil
 }
 
-func (repo *Repository) CreateOrder(ctx context.Context, order *model.Order) error {
+// StatusChange 订单状态变更的操作者与备注，写入 order_status_history
+type StatusChange struct {
+	ActorType model.OrderActorType
+	ActorID   *uuid.UUID
+	Note      string
+}
+
+// BuyerChange 买家触发的状态变更
+func BuyerChange(userID uuid.UUID, note string) StatusChange {
+	return StatusChange{ActorType: model.OrderActorBuyer, ActorID: &userID, Note: note}
+}
+
+// SellerChange 商品发布者触发的状态变更
+func SellerChange(publisher uuid.UUID, note string) StatusChange {
+	return StatusChange{ActorType: model.OrderActorSeller, ActorID: &publisher, Note: note}
+}
+
+// SystemChange 系统自动触发的状态变更
+func SystemChange(note string) StatusChange {
+	return StatusChange{ActorType: model.OrderActorSystem, Note: note}
+}
+
+func (repo *Repository) createStatusHistory(ctx context.Context, orderID uuid.UUID, from *model.OrderStatus, to model.OrderStatus, change StatusChange) error {
+	return repo.GetDB(ctx).Create(&model.OrderStatusHistory{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorType:  change.ActorType,
+		ActorID:    change.ActorID,
+		Note:       change.Note,
+	}).Error
+}
+
+// CreateOrder 创建订单及明细，并记录初始状态
+func (repo *Repository) CreateOrder(ctx context.Context, order *model.Order, change StatusChange) error {
 	if err := repo.GetDB(ctx).Create(order).Error; err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.SQLState() == pgerrcode.UniqueViolation {
@@ -83,44 +118,178 @@ func (repo *Repository) CreateOrder(ctx context.Context, order *model.Order) err
 		}
 		return fmt.Errorf("failed to create order %s: %w", order.ID, err)
 	}
-	return nil
+	return repo.createStatusHistory(ctx, order.ID, nil, order.Status, change)
+}
+
+// GetOrderByIdempotencyKey 按幂等键查询用户订单（幂等重放时使用）
+func (repo *Repository) GetOrderByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) (*model.Order, error) {
+	var order model.Order
+	err := repo.GetDB(ctx).
+		Preload("Items").
+		Where("idempotency_key = ? AND user_id = ?", key, userID).
+		First(&order).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询订单失败 %s: %w", key, err)
+	}
+	return &order, nil
 }
 
-func (repo *Repository) PublishTimeoutMessage(ctx context.Context, orderID uuid.UUID) error {
-	return repo.mqCh.PublishWithContext(ctx,
-		"",
-		repo.mqCfg.DelayQueue,
-		false,
-		false,
-		amqp.Publishing{
-			DeliveryMode: amqp.Persistent,
-			Body:         []byte(orderID.String()),
-		},
-	)
+// EnqueueTimeoutMessage 将订单超时消息写入发件箱（事务内使用），outbox relay 在支付期限到达后投递到消费队列
+func (repo *Repository) EnqueueTimeoutMessage(ctx context.Context, orderID uuid.UUID, deadline time.Time) error {
+	return repo.GetDB(ctx).Create(&model.OutboxMessage{
+		Exchange:      repo.mqCfg.Exchange,
+		RoutingKey:    repo.mqCfg.RoutingKey,
+		Body:          orderID.String(),
+		Status:        model.OutboxStatusPending,
+		NextAttemptAt: deadline,
+	}).Error
 }
 
+// HandleOrderTimeout 将已过支付期限的处理中订单置为超时并预载明细（事务内使用）；订单已被处理（支付/取消/重复消息）或未到期时返回 nil
 func (repo *Repository) HandleOrderTimeout(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
 	var order model.Order
-	err := repo.GetDB(ctx).Where("id = ?", orderID).First(&order).Error
+	err := repo.GetDB(ctx).
+		Clauses(clause.Locking{Strength: string(database.LockUpdate)}).
+		Where("id = ?", orderID).
+		First(&order).Error
 	if err != nil {
 		return nil, fmt.Errorf("查询订单失败 %s: %w", orderID, err)
 	}
 
 	if order.Status != model.OrderStatusProcessing {
-		return &order, nil // 已处理过
+		return nil, nil // 已处理过
+	}
+	if time.Now().Before(order.PayDeadline) {
+		// 提前到达的消息（如死信重放），按支付期限重新调度
+		return nil, repo.EnqueueTimeoutMessage(ctx, orderID, order.PayDeadline)
+	}
+
+	err = repo.UpdateOrderStatus(ctx, orderID, model.OrderStatusProcessing, model.OrderStatusTimeout, SystemChange("支付超时自动关单"))
+	if errors.Is(err, repoErrOrderStatusChanged) {
+		return nil, nil // 并发支付/取消已先一步修改状态
+	}
+	if err != nil {
+		return nil, fmt.Errorf("更新订单超时状态失败 %s: %w", orderID, err)
 	}
 
+	if err := repo.GetDB(ctx).Where("order_id = ?", orderID).Find(&order.Items).Error; err != nil {
+		return nil, fmt.Errorf("查询订单明细失败 %s: %w", orderID, err)
+	}
+
+	order.Status = model.OrderStatusTimeout
+	return &order, nil
+}
+
+// GetUserOrderForUpdate 获取用户订单（带行锁+预载明细，事务内使用）
+func (repo *Repository) GetUserOrderForUpdate(ctx context.Context, orderID, userID uuid.UUID) (*model.Order, error) {
+	var order model.Order
+	err := repo.GetDB(ctx).
+		Clauses(clause.Locking{Strength: string(database.LockUpdate)}).
+		Where("id = ? AND user_id = ?", orderID, userID).
+		First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	// 行锁只加在订单行上，明细单独查询
+	if err := repo.GetDB(ctx).Where("order_id = ?", orderID).Find(&order.Items).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// GetOrderForUpdate 获取订单（带行锁+预载明细，不校验归属，事务内使用）
+func (repo *Repository) GetOrderForUpdate(ctx context.Context, orderID uuid.UUID) (*model.Order, error) {
+	var order model.Order
+	err := repo.GetDB(ctx).
+		Clauses(clause.Locking{Strength: string(database.LockUpdate)}).
+		Where("id = ?", orderID).
+		First(&order).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.GetDB(ctx).Where("order_id = ?", orderID).Find(&order.Items).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+// ApplyRefund 累加明细已退数量与订单已退金额（事务内使用）
+func (repo *Repository) ApplyRefund(ctx context.Context, orderID, orderItemID uuid.UUID, quantity int, amount float64) error {
+	result := repo.GetDB(ctx).Model(&model.OrderItem{}).
+		Where("id = ? AND order_id = ? AND quantity - refunded_quantity >= ?", orderItemID, orderID, quantity).
+		Update("refunded_quantity", gorm.Expr("refunded_quantity + ?", quantity))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("订单明细可退数量不足 order=%s item=%s", orderID, orderItemID)
+	}
+
+	return repo.GetDB(ctx).Model(&model.Order{}).
+		Where("id = ?", orderID).
+		Updates(map[string]interface{}{
+			"refunded_amount": gorm.Expr("refunded_amount + ?", amount),
+			"updated_at":      time.Now(),
+		}).Error
+}
+
+// UpdateOrderStatus 条件更新订单状态（仅当当前状态为 from 时生效）并记录状态流转
+func (repo *Repository) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, from, to model.OrderStatus, change StatusChange) error {
 	result := repo.GetDB(ctx).
 		Model(&model.Order{}).
-		Where("id = ? AND status = ?", orderID, model.OrderStatusProcessing).
-		Update("status", model.OrderStatusTimeout)
+		Where("id = ? AND status = ?", orderID, from).
+		Updates(map[string]interface{}{
+			"status":     to,
+			"updated_at": time.Now(),
+		})
 	if result.Error != nil {
-		return nil, fmt.Errorf("更新订单超时状态失败 %s: %w", orderID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return repoErrOrderStatusChanged
 	}
+	return repo.createStatusHistory(ctx, orderID, &from, to, change)
+}
 
+// GetUserOrderDetail 获取用户订单详情（预载明细与状态流转记录）
+func (repo *Repository) GetUserOrderDetail(ctx context.Context, orderID, userID uuid.UUID) (*model.Order, error) {
+	var order model.Order
+	err := repo.GetDB(ctx).
+		Preload("Items").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Where("id = ? AND user_id = ?", orderID, userID).
+		First(&order).Error
+	if err != nil {
+		return nil, err
+	}
 	return &order, nil
 }
 
+// ListAllOrdersByUserID 返回用户的全部订单（含明细和状态流转记录），用于导出个人数据
+func (repo *Repository) ListAllOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]*model.Order, error) {
+	var orders []*model.Order
+	err := repo.GetDB(ctx).
+		Preload("Items").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC")
+		}).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&orders).Error
+	return orders, err
+}
+
+// CountOpenOrders 统计用户未结束的订单（待支付或退款审核中）
+func (repo *Repository) CountOpenOrders(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	err := repo.GetDB(ctx).Model(&model.Order{}).
+		Where("user_id = ? AND status IN ?", userID, []model.OrderStatus{model.OrderStatusProcessing, model.OrderStatusRefunding}).
+		Count(&count).Error
+	return count, err
+}
+
 func (repo *Repository) ListOrdersByUserID(ctx context.Context, userID uuid.UUID, pageNum, pageSize int) ([]*model.Order, int64, error) {
 	var orders []*model.Order
 	var total int64
@@ -132,6 +301,7 @@ func (repo *Repository) ListOrdersByUserID(ctx context.Context, userID uuid.UUID
 	}
 
 	err := baseQuery.Session(&gorm.Session{}).
+		Preload("Items").
 		Offset((pageNum - 1) * pageSize).
 		Limit(pageSize).
 		Order("created_at DESC").
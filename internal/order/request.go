@@ -1,13 +1,36 @@
 package order
 
+type CreateOrderItemBody struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
 type CreateOrderBody struct {
-	ProductID      string `json:"product_id" binding:"required"`
-	Quantity       int    `json:"quantity" binding:"required,min=1"`
-	CouponID       string `json:"coupon_id" binding:"omitempty"`
-	IdempotencyKey string `json:"idempotency_key" binding:"required"`
+	// Items 上限与 MaxOrderItems 一致
+	Items          []CreateOrderItemBody `json:"items" binding:"required,min=1,max=50,dive"`
+	CouponID       string                `json:"coupon_id" binding:"omitempty"`
+	AddressID      string                `json:"address_id" binding:"omitempty"`
+	IdempotencyKey string                `json:"idempotency_key" binding:"required"`
 }
 
 type ListOrdersQuery struct {
 	PageNum  int `form:"page_num" binding:"required,gt=0"`
 	PageSize int `form:"page_size" binding:"required,max=20"`
-}
\ No newline at end of file
+}
+
+type UriWithOrderID struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+type PayOrderBody struct {
+	IdempotencyKey string `json:"idempotency_key" binding:"required,max=64"`
+}
+
+type ListDeadLettersQuery struct {
+	Limit int `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+type ReplayDeadLettersBody struct {
+	// MessageIDs 为空时重放全部死信
+	MessageIDs []string `json:"message_ids" binding:"omitempty,max=100"`
+}
@@ -1,19 +1,60 @@
 package order
 
 import (
+	"e-commerce/internal/address"
+	"e-commerce/internal/coupon"
 	"e-commerce/internal/model"
+	"time"
 )
 
+type LineItem struct {
+	ProductID     string  `json:"product_id"`
+	Quantity      int     `json:"quantity"`
+	SnapshotTitle string  `json:"snapshot_title"`
+	SnapshotPrice float64 `json:"snapshot_price"`
+	Subtotal      float64 `json:"subtotal"`
+}
+
 type OrderItem struct {
-	ID             string  `json:"id"`
-	ProductID      string  `json:"product_id"`
-	Quantity       int     `json:"quantity"`
-	SnapshotTitle  string  `json:"snapshot_title"`
-	SnapshotPrice  float64 `json:"snapshot_price"`
-	DiscountAmount float64 `json:"discount_amount"`
-	TotalAmount    float64 `json:"total_amount"`
-	Status         int     `json:"status"`
-	CreatedAt      string  `json:"created_at"`
+	ID             string     `json:"id"`
+	Items          []LineItem `json:"items"`
+	DiscountAmount float64    `json:"discount_amount"`
+	TotalAmount    float64    `json:"total_amount"`
+	Status         int        `json:"status"`
+	CreatedAt      string     `json:"created_at"`
+	// PayDeadline 支付截止时间（RFC3339），客户端据此展示倒计时
+	PayDeadline string `json:"pay_deadline"`
+	// ShippingAddress 下单时的收货信息快照，未选择地址时为空
+	ShippingAddress *address.ShippingInfo `json:"shipping_address"`
+}
+
+type CreateOrderResponse struct {
+	OrderID     string `json:"order_id"`
+	PayDeadline string `json:"pay_deadline"`
+}
+
+type PayOrderResponse struct {
+	OrderID    string  `json:"order_id"`
+	PaidAmount float64 `json:"paid_amount"`
+	Status     int     `json:"status"`
+}
+
+type StatusHistoryItem struct {
+	FromStatus *int   `json:"from_status"`
+	ToStatus   int    `json:"to_status"`
+	ActorType  string `json:"actor_type"`
+	ActorID    string `json:"actor_id,omitempty"`
+	Note       string `json:"note"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type OrderDetailResponse struct {
+	OrderItem
+	GoodsAmount    float64                `json:"goods_amount"`
+	PayableAmount  float64                `json:"payable_amount"`
+	RefundedAmount float64                `json:"refunded_amount"`
+	Coupon         *coupon.UserCouponItem `json:"coupon"`
+	History        []StatusHistoryItem    `json:"history"`
 }
 
 type ListOrdersResponse struct {
@@ -22,19 +63,79 @@ type ListOrdersResponse struct {
 }
 
 func FormatOrderItem(o *model.Order) *OrderItem {
-	total := o.SnapshotPrice*float64(o.Quantity) - o.DiscountAmount
-	if total < 0 {
-		total = 0
+	lines := make([]LineItem, 0, len(o.Items))
+	for i := range o.Items {
+		oi := &o.Items[i]
+		lines = append(lines, LineItem{
+			ProductID:     oi.ProductID.String(),
+			Quantity:      oi.Quantity,
+			SnapshotTitle: oi.SnapshotTitle,
+			SnapshotPrice: oi.SnapshotPrice,
+			Subtotal:      oi.Subtotal(),
+		})
 	}
-	return &OrderItem{
+	item := &OrderItem{
 		ID:             o.ID.String(),
-		ProductID:      o.ProductId.String(),
-		Quantity:       o.Quantity,
-		SnapshotTitle:  o.SnapshotTitle,
-		SnapshotPrice:  o.SnapshotPrice,
+		Items:          lines,
 		DiscountAmount: o.DiscountAmount,
-		TotalAmount:    total,
+		TotalAmount:    o.PayableAmount(),
 		Status:         int(o.Status),
 		CreatedAt:      o.CreatedAt.Format("2006-01-02 15:04:05"),
+		PayDeadline:    o.PayDeadline.Format(time.RFC3339),
+	}
+	if o.AddressID != nil {
+		item.ShippingAddress = address.FormatShippingInfo(&o.SnapshotAddress)
+	}
+	return item
+}
+
+func FormatOrderDetail(d *OrderDetail) *OrderDetailResponse {
+	o := d.Order
+	history := make([]StatusHistoryItem, 0, len(o.History))
+	for i := range o.History {
+		h := &o.History[i]
+		item := StatusHistoryItem{
+			ToStatus:  int(h.ToStatus),
+			ActorType: string(h.ActorType),
+			Note:      h.Note,
+			CreatedAt: h.CreatedAt.Format(time.DateTime),
+		}
+		if h.FromStatus != nil {
+			from := int(*h.FromStatus)
+			item.FromStatus = &from
+		}
+		if h.ActorID != nil {
+			item.ActorID = h.ActorID.String()
+		}
+		history = append(history, item)
+	}
+
+	resp := &OrderDetailResponse{
+		OrderItem:      *FormatOrderItem(o),
+		GoodsAmount:    o.TotalAmount,
+		PayableAmount:  o.PayableAmount(),
+		RefundedAmount: o.RefundedAmount,
+		History:        history,
+	}
+	if d.Coupon != nil {
+		resp.Coupon = coupon.FormatUserCoupon(d.Coupon)
 	}
-}
\ No newline at end of file
+	return resp
+}
+
+type DeadLetterItem struct {
+	MessageID      string `json:"message_id"`
+	Body           string `json:"body"`
+	RetryCount     int    `json:"retry_count"`
+	Reason         string `json:"reason"`
+	DeadLetteredAt string `json:"dead_lettered_at"`
+}
+
+type ListDeadLettersResponse struct {
+	Messages []DeadLetterItem `json:"messages"`
+	Total    int              `json:"total"`
+}
+
+type ReplayDeadLettersResponse struct {
+	Replayed int `json:"replayed"`
+}
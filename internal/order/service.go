@@ -1,15 +1,20 @@
 package order
 
 import (
+	"bytes"
 	"context"
+	"e-commerce/internal/address"
+	"e-commerce/internal/config"
 	"e-commerce/internal/coupon"
 	"e-commerce/internal/model"
 	"e-commerce/internal/pkg/database"
 	"e-commerce/internal/product"
-	"e-commerce/pkg/errno"
+	"e-commerce/internal/wallet"
 	"e-commerce/pkg/clog"
+	"e-commerce/pkg/errno"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -17,34 +22,78 @@ import (
 	"gorm.io/gorm"
 )
 
+// MaxOrderItems 单个订单最多包含的商品种类数，下单接口与购物车结算共用
+const MaxOrderItems = 50
+
 type Service struct {
 	db          *gorm.DB
 	repo        *Repository
 	productRepo *product.Repository
 	couponRepo  *coupon.Repository
+	walletRepo  *wallet.Repository
+	addressRepo *address.Repository
+	cfg         *config.OrderSection
 }
 
-func NewService(db *gorm.DB, repo *Repository, productRepo *product.Repository, couponRepo *coupon.Repository) *Service {
-	return &Service{db: db, repo: repo, productRepo: productRepo, couponRepo: couponRepo}
+func NewService(db *gorm.DB, repo *Repository, productRepo *product.Repository, couponRepo *coupon.Repository, walletRepo *wallet.Repository,
+	addressRepo *address.Repository, cfg *config.OrderSection) *Service {
+	return &Service{
+		db:          db,
+		repo:        repo,
+		productRepo: productRepo,
+		couponRepo:  couponRepo,
+		walletRepo:  walletRepo,
+		addressRepo: addressRepo,
+		cfg:         cfg,
+	}
 }
 
-// CreateOrder 创建订单（支持可选优惠券）
-func (svc *Service) CreateOrder(ctx context.Context, userID uuid.UUID, param CreateOrderParam) error {
+// CreateOrder 创建订单（支持多商品与可选优惠券）
+func (svc *Service) CreateOrder(ctx context.Context, userID uuid.UUID, param CreateOrderParam) (*model.Order, error) {
+	lines := mergeOrderItems(param.Items)
+	if len(lines) == 0 {
+		return nil, errno.ErrInvalidParam
+	}
+	if len(lines) > MaxOrderItems {
+		return nil, errno.ErrInvalidParam.WithRaw(fmt.Errorf("too many order items: %d > %d", len(lines), MaxOrderItems))
+	}
+
 	var order *model.Order
 
 	err := database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
-		p, err := svc.productRepo.GetProductByID(ctx, param.ProductID, database.LockUpdate)
+		addr, err := svc.resolveAddress(ctx, userID, param.AddressID)
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return errno.ErrOrderProductIdNotFound
-			}
-			return errno.ErrOrderProductIdNotFound.WithRaw(
-				fmt.Errorf("get product by id: %s, error: %w", param.ProductID, err),
-			)
+			return err
 		}
 
-		if err := svc.productRepo.DeductStock(ctx, param.ProductID, param.Quantity); err != nil {
-			return err
+		items := make([]model.OrderItem, 0, len(lines))
+		var orderAmount float64
+		payTimeout := svc.cfg.PayTimeout
+
+		// 按商品 ID 有序加锁冻结库存，避免并发下单时互相等待造成死锁
+		for _, line := range lines {
+			p, err := svc.productRepo.GetProductByID(ctx, line.ProductID, database.LockUpdate)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return errno.ErrOrderProductIdNotFound
+				}
+				return errno.ErrOrderProductIdNotFound.WithRaw(
+					fmt.Errorf("get product by id: %s, error: %w", line.ProductID, err),
+				)
+			}
+
+			if err := svc.productRepo.ReserveStock(ctx, line.ProductID, line.Quantity); err != nil {
+				return err
+			}
+
+			items = append(items, model.OrderItem{
+				ProductID:     line.ProductID,
+				Quantity:      line.Quantity,
+				SnapshotTitle: p.Name,
+				SnapshotPrice: p.Price,
+			})
+			orderAmount += p.Price * float64(line.Quantity)
+			payTimeout = minPayTimeout(payTimeout, p)
 		}
 
 		var discountAmount float64
@@ -68,7 +117,6 @@ func (svc *Service) CreateOrder(ctx context.Context, userID uuid.UUID, param Cre
 				return coupon.ErrTemplateNotFound
 			}
 
-			orderAmount := p.Price * float64(param.Quantity)
 			if template.MinAmount > 0 && orderAmount < template.MinAmount {
 				return coupon.ErrCouponMinAmountNotMet
 			}
@@ -85,30 +133,199 @@ func (svc *Service) CreateOrder(ctx context.Context, userID uuid.UUID, param Cre
 
 		order = &model.Order{
 			UserID:         userID,
-			ProductId:      param.ProductID,
-			Quantity:       param.Quantity,
-			SnapshotTitle:  p.Name,
-			SnapshotPrice:  p.Price,
+			TotalAmount:    orderAmount,
 			Status:         model.OrderStatusProcessing,
 			UserCouponID:   userCouponID,
 			DiscountAmount: discountAmount,
 			IdempotencyKey: param.IdempotencyKey,
+			PayDeadline:    time.Now().Add(payTimeout),
+			Items:          items,
+		}
+		if addr != nil {
+			order.AddressID = &addr.ID
+			order.SnapshotAddress = addr.AddressInfo
 		}
-		return svc.repo.CreateOrder(ctx, order)
+		if err := svc.repo.CreateOrder(ctx, order, BuyerChange(userID, "创建订单")); err != nil {
+			return err
+		}
+		// 超时消息与订单同事务写入发件箱，保证下单与超时调度的原子性
+		return svc.repo.EnqueueTimeoutMessage(ctx, order.ID, order.PayDeadline)
 	})
 	if err != nil {
 		if errors.Is(err, repoErrOrderIdempotencyConflict) {
-			return nil
+			// 重复提交：返回首次创建的订单；查不到本人的订单说明幂等键被其他用户占用
+			existing, err := svc.GetOrderByIdempotencyKey(ctx, userID, param.IdempotencyKey)
+			if errors.Is(err, errno.ErrNotFoundRecord) {
+				return nil, errno.ErrOrderIdempotencyKeyConflict
+			}
+			return existing, err
 		}
-		return err
+		return nil, err
 	}
 
-	if err := svc.repo.PublishTimeoutMessage(ctx, order.ID); err != nil {
-		clog.L(ctx).Error("发送订单超时消息失败",
-			zap.String("order_id", order.ID.String()),
-			zap.Error(err),
-		)
-		return fmt.Errorf("订单已创建但超时调度失败: %w", err)
+	return order, nil
+}
+
+// GetOrderByIdempotencyKey 查询用户使用该幂等键创建的订单
+func (svc *Service) GetOrderByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) (*model.Order, error) {
+	o, err := svc.repo.GetOrderByIdempotencyKey(ctx, userID, key)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrNotFoundRecord
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// minPayTimeout 订单支付时限取各商品时限的最小值（如包含秒杀商品则按秒杀时限）
+func minPayTimeout(current time.Duration, p *model.Product) time.Duration {
+	if p.PayTimeoutSeconds <= 0 {
+		return current
+	}
+	if d := time.Duration(p.PayTimeoutSeconds) * time.Second; d < current {
+		return d
+	}
+	return current
+}
+
+// mergeOrderItems 合并相同商品的数量，并按商品 ID 排序以保证加锁顺序一致
+func mergeOrderItems(items []CreateOrderItemParam) []CreateOrderItemParam {
+	quantities := make(map[uuid.UUID]int, len(items))
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			continue
+		}
+		quantities[item.ProductID] += item.Quantity
+	}
+
+	merged := make([]CreateOrderItemParam, 0, len(quantities))
+	for productID, quantity := range quantities {
+		merged = append(merged, CreateOrderItemParam{ProductID: productID, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return bytes.Compare(merged[i].ProductID[:], merged[j].ProductID[:]) < 0
+	})
+	return merged
+}
+
+// PayOrder 使用钱包余额支付订单：扣款、记流水、扣减冻结库存、订单置为已完成在同一事务内
+func (svc *Service) PayOrder(ctx context.Context, param PayOrderParam) (*model.Order, error) {
+	var order *model.Order
+
+	err := database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		var err error
+		order, err = svc.repo.GetUserOrderForUpdate(ctx, param.OrderID, param.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errno.ErrOrderNotFound
+			}
+			return err
+		}
+
+		switch order.Status {
+		case model.OrderStatusCompleted, model.OrderStatusRefunding, model.OrderStatusRefunded:
+			return nil // 已支付，重复请求直接返回成功
+		case model.OrderStatusProcessing:
+		default:
+			return errno.ErrOrderStatusInvalid
+		}
+		// 已过支付期限但超时消息尚未处理，同样不允许支付
+		if time.Now().After(order.PayDeadline) {
+			return errno.ErrOrderPayExpired
+		}
+
+		if err := svc.walletRepo.Debit(ctx, wallet.DebitData{
+			UserID:         param.UserID,
+			SessionID:      param.SessionID,
+			Amount:         order.PayableAmount(),
+			Type:           model.WalletLogTypePayment,
+			IdempotencyKey: param.IdempotencyKey,
+		}); err != nil {
+			if errors.Is(err, wallet.ErrWalletLogAlreadyExists) {
+				return errno.ErrOrderIdempotencyKeyConflict
+			}
+			return err
+		}
+
+		if err := svc.repo.UpdateOrderStatus(ctx, order.ID, model.OrderStatusProcessing, model.OrderStatusCompleted, BuyerChange(param.UserID, "钱包支付")); err != nil {
+			if errors.Is(err, repoErrOrderStatusChanged) {
+				return errno.ErrOrderStatusInvalid
+			}
+			return err
+		}
+
+		for _, item := range sortedItems(order) {
+			if err := svc.productRepo.CommitStock(ctx, item.ProductID, item.Quantity); err != nil {
+				return fmt.Errorf("扣减冻结库存失败 order=%s product=%s: %w", order.ID, item.ProductID, err)
+			}
+		}
+		order.Status = model.OrderStatusCompleted
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clog.L(ctx).Info("订单支付成功",
+		zap.String("order_id", order.ID.String()),
+		zap.Float64("amount", order.PayableAmount()),
+	)
+	return order, nil
+}
+
+// CancelOrder 用户取消处理中的订单：改状态、释放冻结库存与退券在同一事务内
+func (svc *Service) CancelOrder(ctx context.Context, userID, orderID uuid.UUID) error {
+	return database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		order, err := svc.repo.GetUserOrderForUpdate(ctx, orderID, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errno.ErrOrderNotFound
+			}
+			return err
+		}
+
+		switch order.Status {
+		case model.OrderStatusCancelled:
+			return nil // 已取消，重复请求直接返回成功
+		case model.OrderStatusProcessing:
+		default:
+			return errno.ErrOrderStatusInvalid
+		}
+
+		if err := svc.repo.UpdateOrderStatus(ctx, order.ID, model.OrderStatusProcessing, model.OrderStatusCancelled, BuyerChange(userID, "用户取消")); err != nil {
+			if errors.Is(err, repoErrOrderStatusChanged) {
+				return errno.ErrOrderStatusInvalid
+			}
+			return err
+		}
+
+		return svc.releaseOrder(ctx, order, model.StockChangeRelease)
+	})
+}
+
+// sortedItems 按商品 ID 排序订单明细，保证与下单时相同的加锁顺序
+func sortedItems(order *model.Order) []model.OrderItem {
+	items := make([]model.OrderItem, len(order.Items))
+	copy(items, order.Items)
+	sort.Slice(items, func(i, j int) bool {
+		return bytes.Compare(items[i].ProductID[:], items[j].ProductID[:]) < 0
+	})
+	return items
+}
+
+// releaseOrder 释放订单冻结的库存并退还优惠券（事务内使用）
+func (svc *Service) releaseOrder(ctx context.Context, order *model.Order, reason model.StockChangeReason) error {
+	for _, item := range sortedItems(order) {
+		if err := svc.productRepo.ReleaseStock(ctx, item.ProductID, item.Quantity, reason); err != nil {
+			return fmt.Errorf("释放冻结库存失败 order=%s product=%s: %w", order.ID, item.ProductID, err)
+		}
+	}
+
+	if order.UserCouponID != nil {
+		if err := svc.couponRepo.ReturnCoupon(ctx, *order.UserCouponID); err != nil {
+			return fmt.Errorf("退还优惠券失败 order=%s coupon=%s: %w", order.ID, order.UserCouponID, err)
+		}
 	}
 	return nil
 }
@@ -130,22 +347,71 @@ func calcCouponDeduction(t *model.CouponTemplate, orderAmount float64) float64 {
 }
 
 func (svc *Service) HandleOrderTimeout(ctx context.Context, orderID uuid.UUID) error {
-	order, err := svc.repo.HandleOrderTimeout(ctx, orderID)
+	// 改状态、释放冻结库存与退券在同一事务内，避免订单已超时但库存仍被占用
+	return database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		order, err := svc.repo.HandleOrderTimeout(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		if order == nil {
+			return nil
+		}
+
+		return svc.releaseOrder(ctx, order, model.StockChangeTimeout)
+	})
+}
+
+// resolveAddress 确定收货地址：指定了地址则使用该地址，否则使用默认地址；
+// 未指定且没有默认地址时返回 nil，订单不带收货信息
+func (svc *Service) resolveAddress(ctx context.Context, userID, addressID uuid.UUID) (*model.Address, error) {
+	var (
+		addr *model.Address
+		err  error
+	)
+	if addressID != uuid.Nil {
+		addr, err = svc.addressRepo.GetUserAddress(ctx, addressID, userID)
+	} else {
+		addr, err = svc.addressRepo.GetDefaultAddress(ctx, userID)
+	}
 	if err != nil {
-		return err
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrDatabase.WithRaw(err)
+		}
+		if addressID != uuid.Nil {
+			return nil, errno.ErrAddressNotFound
+		}
+		return nil, nil
 	}
+	return addr, nil
+}
 
-	if order.UserCouponID != nil {
-		if err := svc.couponRepo.ReturnCoupon(ctx, *order.UserCouponID); err != nil {
-			clog.L(ctx).Error("退还优惠券失败",
-				zap.String("order_id", orderID.String()),
-				zap.String("coupon_id", order.UserCouponID.String()),
-				zap.Error(err),
-			)
+// OrderDetail 订单详情（含使用的优惠券）
+type OrderDetail struct {
+	Order  *model.Order
+	Coupon *model.UserCoupon
+}
+
+// GetOrderDetail 用户查看订单详情：明细、优惠券与状态流转记录
+func (svc *Service) GetOrderDetail(ctx context.Context, userID, orderID uuid.UUID) (*OrderDetail, error) {
+	o, err := svc.repo.GetUserOrderDetail(ctx, orderID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrOrderNotFound
 		}
+		return nil, errno.ErrDatabase.WithRaw(err)
 	}
 
-	return nil
+	detail := &OrderDetail{Order: o}
+	if o.UserCouponID != nil {
+		uc, err := svc.couponRepo.GetUserCoupon(ctx, *o.UserCouponID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrDatabase.WithRaw(err)
+		}
+		if err == nil {
+			detail.Coupon = uc
+		}
+	}
+	return detail, nil
 }
 
 func (svc *Service) ListOrders(ctx context.Context, param ListOrdersParam) ([]*model.Order, int64, error) {
@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/clog"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// pollInterval 扫描待投递消息的间隔
+	pollInterval = time.Second
+	// batchSize 每轮最多投递的消息数
+	batchSize = 100
+	// confirmTimeout 等待 broker 确认的超时时间
+	confirmTimeout = 5 * time.Second
+	// claimLease 认领消息的租约时长，超过后未投递完的消息可被其他 relay 重新认领
+	claimLease = time.Minute
+	// purgeInterval 清理已发送消息的间隔，sentRetention 已发送消息的保留时长
+	purgeInterval  = time.Hour
+	sentRetention  = 7 * 24 * time.Hour
+	purgeBatchSize = 1000
+	// 投递失败后的重试退避：baseBackoff * 2^attempts，最多 maxBackoff
+	baseBackoff = time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+var (
+	errPublishNacked  = errors.New("broker nacked the message")
+	errChannelMissing = errors.New("publisher channel is not available")
+)
+
+// Relay 将发件箱中的待投递消息发布到 RabbitMQ（publisher confirm），成功后标记已发送
+type Relay struct {
+	repo *Repository
+
+	mu sync.RWMutex
+	ch *amqp.Channel
+}
+
+func NewRelay(repo *Repository) *Relay {
+	return &Relay{repo: repo}
+}
+
+// Attach 在新 Channel 上开启 publisher confirm 并替换发布通道，作为 mq.SetupFunc 注册，重连后自动重新绑定
+func (r *Relay) Attach(ch *amqp.Channel) error {
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("开启 publisher confirm 失败: %w", err)
+	}
+	r.mu.Lock()
+	r.ch = ch
+	r.mu.Unlock()
+	return nil
+}
+
+// Start 在后台轮询投递，ctx 取消时退出；发布通道不可用期间消息按退避重试
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				clog.L(ctx).Error("outbox relay panic", zap.Any("recover", rec))
+			}
+		}()
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		purgeTicker := time.NewTicker(purgeInterval)
+		defer purgeTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				clog.L(ctx).Info("outbox relay 退出")
+				return
+			case <-ticker.C:
+				if _, err := r.RelayOnce(ctx); err != nil {
+					clog.L(ctx).Error("outbox 投递失败", zap.Error(err))
+				}
+			case <-purgeTicker.C:
+				if _, err := r.PurgeSent(ctx); err != nil {
+					clog.L(ctx).Error("outbox 清理已发送消息失败", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// RelayOnce 认领一批到期消息并逐条投递，返回成功投递的条数；
+// 投递不在数据库事务内进行，broker 慢或不可用时不会长时间占用连接和行锁
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	claimedAt := time.Now()
+	msgs, err := r.repo.ClaimDue(ctx, batchSize, claimedAt.Add(claimLease))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, msg := range msgs {
+		// 租约到期后剩余消息可能已被其他 relay 认领，留给下一轮
+		if time.Since(claimedAt) > claimLease-confirmTimeout {
+			break
+		}
+		if err := r.publish(ctx, msg); err != nil {
+			clog.L(ctx).Warn("outbox 消息投递失败，稍后重试",
+				zap.String("message_id", msg.ID.String()),
+				zap.Int("attempts", msg.Attempts+1),
+				zap.Error(err),
+			)
+			if err := r.repo.MarkRetry(ctx, msg.ID, time.Now().Add(backoff(msg.Attempts)), err.Error()); err != nil {
+				return sent, err
+			}
+			continue
+		}
+		if err := r.repo.MarkSent(ctx, msg.ID); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// PurgeSent 分批删除超过保留时长的已发送消息，返回删除条数
+func (r *Relay) PurgeSent(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-sentRetention)
+	var total int64
+	for {
+		n, err := r.repo.PurgeSent(ctx, before, purgeBatchSize)
+		total += n
+		if err != nil || n < purgeBatchSize {
+			return total, err
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, msg *model.OutboxMessage) error {
+	r.mu.RLock()
+	ch := r.ch
+	r.mu.RUnlock()
+	if ch == nil || ch.IsClosed() {
+		return errChannelMissing
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, confirmTimeout)
+	defer cancel()
+
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx,
+		msg.Exchange,
+		msg.RoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			MessageId:    msg.ID.String(),
+			DeliveryMode: amqp.Persistent,
+			Body:         []byte(msg.Body),
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errPublishNacked
+	}
+	return nil
+}
+
+func backoff(attempts int) time.Duration {
+	d := baseBackoff
+	for i := 0; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/database"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository struct {
+	*database.BaseRepo
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{BaseRepo: database.NewBaseRepo(db)}
+}
+
+// ClaimDue 认领到期待投递的消息：SKIP LOCKED 选出后把 next_attempt_at 推迟到 leaseUntil 作为租约，
+// 租约期内其他 relay 不会再选中；行锁只在本次短事务内持有，投递在事务外进行
+func (repo *Repository) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]*model.OutboxMessage, error) {
+	var msgs []*model.OutboxMessage
+	err := database.ExecuteTransaction(ctx, repo.GetDB(ctx), func(ctx context.Context) error {
+		db := repo.GetDB(ctx)
+		if err := db.
+			Clauses(clause.Locking{Strength: string(database.LockUpdate), Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", model.OutboxStatusPending, time.Now()).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Find(&msgs).Error; err != nil {
+			return err
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, 0, len(msgs))
+		for _, msg := range msgs {
+			ids = append(ids, msg.ID)
+		}
+		return db.Model(&model.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", leaseUntil).Error
+	})
+	return msgs, err
+}
+
+func (repo *Repository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	return repo.GetDB(ctx).Model(&model.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":   model.OutboxStatusSent,
+			"attempts": gorm.Expr("attempts + 1"),
+			"sent_at":  now,
+		}).Error
+}
+
+// MarkRetry 记录投递失败并推迟下次投递时间
+func (repo *Repository) MarkRetry(ctx context.Context, id uuid.UUID, nextAttemptAt time.Time, lastErr string) error {
+	return repo.GetDB(ctx).Model(&model.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": nextAttemptAt,
+			"last_error":      lastErr,
+		}).Error
+}
+
+// PurgeSent 删除 sent_at 早于 before 的已发送消息，每次最多 limit 条，返回删除条数
+func (repo *Repository) PurgeSent(ctx context.Context, before time.Time, limit int) (int64, error) {
+	db := repo.GetDB(ctx)
+	result := db.
+		Where("id IN (?)", db.Model(&model.OutboxMessage{}).
+			Select("id").
+			Where("status = ? AND sent_at < ?", model.OutboxStatusSent, before).
+			Limit(limit)).
+		Delete(&model.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
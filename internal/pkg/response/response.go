@@ -56,7 +56,7 @@ func Write(c *gin.Context, err error, data interface{}) {
 
 	resp["code"] = e.FullCode()
 	resp["userMsg"] = displayMsg
-	resp["data"] = nil
+	resp["data"] = e.Data
 
 	if cfg.IsDev() {
 		resp["devMsg"] = e.Error()
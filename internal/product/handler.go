@@ -42,6 +42,8 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 		Status:      body.Status,
 		Stock:       body.Stock,
 		Publisher:   accountInfo.AccountId,
+
+		PayTimeoutSeconds: body.PayTimeoutSeconds,
 	}); err != nil {
 		response.Write(c, err, nil)
 		return
@@ -173,6 +175,8 @@ func (h *Handler) UpdateProductProperty(c *gin.Context) {
 		Name:        body.Name,
 		Description: body.Description,
 		Price:       body.Price,
+
+		PayTimeoutSeconds: body.PayTimeoutSeconds,
 	}); err != nil {
 		response.Write(c, err, nil)
 		return
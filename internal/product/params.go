@@ -13,6 +13,8 @@ type CreateProductParam struct {
 	Status      *model.ProductStatus
 	Stock       int
 	Publisher   uuid.UUID
+
+	PayTimeoutSeconds int
 }
 type UpdateProductStatusParam struct {
 	ProductID uuid.UUID
@@ -36,6 +38,8 @@ type UpdateProductPropertyParam struct {
 	Name        *string
 	Description *string
 	Price       *float64
+
+	PayTimeoutSeconds *int
 }
 
 type UpdateProductStockParam struct {
@@ -28,6 +28,8 @@ type CreateProductData struct {
 	Status      *model.ProductStatus
 	Stock       int
 	Publisher   uuid.UUID
+
+	PayTimeoutSeconds int
 }
 
 func (repo *Repository) CreateProduct(ctx context.Context, data CreateProductData) error {
@@ -44,6 +46,8 @@ func (repo *Repository) CreateProduct(ctx context.Context, data CreateProductDat
 		Stock:       data.Stock,
 		Status:      pStatus,
 		Version:     1,
+
+		PayTimeoutSeconds: data.PayTimeoutSeconds,
 	}
 	return repo.GetDB(ctx).Create(p).Error
 }
@@ -73,6 +77,18 @@ func (repo *Repository) GetProduct(ctx context.Context, id uuid.UUID) (*model.Pr
 	return p, err
 }
 
+// ListProductsByIDs 批量查询商品（购物车校验价格与库存）
+func (repo *Repository) ListProductsByIDs(ctx context.Context, ids []uuid.UUID) ([]*model.Product, error) {
+	var products []*model.Product
+	if len(ids) == 0 {
+		return products, nil
+	}
+	err := repo.GetDB(ctx).
+		Where("id IN ?", ids).
+		Find(&products).Error
+	return products, err
+}
+
 type UpdateStockData struct {
 	ProductID uuid.UUID
 	Publisher uuid.UUID
@@ -81,13 +97,14 @@ type UpdateStockData struct {
 }
 
 // createStockChangeLog 记录库存变动
-func (repo *Repository) createStockChangeLog(ctx context.Context, productID uuid.UUID, quantity, before int, reason model.StockChangeReason) error {
+func (repo *Repository) createStockChangeLog(ctx context.Context, productID uuid.UUID, quantity, before, frozenQuantity int, reason model.StockChangeReason) error {
 	return repo.GetDB(ctx).Create(&model.StockChangeLog{
-		ProductID: productID,
-		Quantity:  quantity,
-		Before:    before,
-		After:     before + quantity,
-		Reason:    reason,
+		ProductID:      productID,
+		Quantity:       quantity,
+		Before:         before,
+		After:          before + quantity,
+		FrozenQuantity: frozenQuantity,
+		Reason:         reason,
 	}).Error
 }
 
@@ -121,36 +138,92 @@ func (repo *Repository) UpdateStock(ctx context.Context, data UpdateStockData) e
 		return errors.New("update failed: insufficient stock or product not found")
 	}
 
-	return repo.createStockChangeLog(ctx, data.ProductID, data.Quantity, result.Stock-data.Quantity, data.Reason)
+	return repo.createStockChangeLog(ctx, data.ProductID, data.Quantity, result.Stock-data.Quantity, 0, data.Reason)
 }
 
-// DeductStock 下单扣减库存（无 publisher 校验，事务内使用）
-func (repo *Repository) DeductStock(ctx context.Context, productID uuid.UUID, quantity int) error {
+// moveStock 在可售库存与冻结库存之间流转（事务内使用），返回 false 表示商品不存在或库存不足
+func (repo *Repository) moveStock(ctx context.Context, productID uuid.UUID, stockDelta, frozenDelta int, reason model.StockChangeReason) (bool, error) {
+	db := repo.GetDB(ctx).Model(&model.Product{}).Where("id = ?", productID)
+	if stockDelta < 0 {
+		db = db.Where("stock >= ?", -stockDelta)
+	}
+	if frozenDelta < 0 {
+		db = db.Where("frozen_stock >= ?", -frozenDelta)
+	}
+
 	var result struct {
 		ID    uuid.UUID
 		Stock int
 	}
-	err := repo.GetDB(ctx).Model(&model.Product{}).
-		Where("id = ? AND stock >= ?", productID, quantity).
+	err := db.
 		Clauses(clause.Returning{Columns: []clause.Column{
 			{Name: "id"},
 			{Name: "stock"},
 		}}).
 		Updates(map[string]interface{}{
-			"stock":      gorm.Expr("stock - ?", quantity),
-			"version":    gorm.Expr("version + 1"),
-			"updated_at": time.Now(),
+			"stock":        gorm.Expr("stock + ?", stockDelta),
+			"frozen_stock": gorm.Expr("frozen_stock + ?", frozenDelta),
+			"version":      gorm.Expr("version + 1"),
+			"updated_at":   time.Now(),
 		}).
 		Scan(&result).Error
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	if result.ID == uuid.Nil {
+		return false, nil
+	}
+
+	return true, repo.createStockChangeLog(ctx, productID, stockDelta, result.Stock-stockDelta, frozenDelta, reason)
+}
+
+// ReserveStock 下单冻结库存：可售库存转入冻结库存（无 publisher 校验，事务内使用）
+func (repo *Repository) ReserveStock(ctx context.Context, productID uuid.UUID, quantity int) error {
+	ok, err := repo.moveStock(ctx, productID, -quantity, quantity, model.StockChangeReserve)
+	if err != nil {
+		return err
+	}
+	if !ok {
 		return errno.ErrProductStockInsufficient
 	}
+	return nil
+}
+
+// CommitStock 支付成功后扣减冻结库存（事务内使用）
+func (repo *Repository) CommitStock(ctx context.Context, productID uuid.UUID, quantity int) error {
+	ok, err := repo.moveStock(ctx, productID, 0, -quantity, model.StockChangeCommit)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("commit failed: insufficient frozen stock or product not found")
+	}
+	return nil
+}
 
-	return repo.createStockChangeLog(ctx, productID, -quantity, result.Stock+quantity, model.StockChangeOrder)
+// ReleaseStock 取消/超时关单释放冻结库存回可售库存（事务内使用）
+func (repo *Repository) ReleaseStock(ctx context.Context, productID uuid.UUID, quantity int, reason model.StockChangeReason) error {
+	ok, err := repo.moveStock(ctx, productID, quantity, -quantity, reason)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("release failed: insufficient frozen stock or product not found")
+	}
+	return nil
+}
+
+// RestoreStock 退款商品重新入库到可售库存（事务内使用）
+func (repo *Repository) RestoreStock(ctx context.Context, productID uuid.UUID, quantity int, reason model.StockChangeReason) error {
+	ok, err := repo.moveStock(ctx, productID, quantity, 0, reason)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errno.ErrProductNotFound
+	}
+	return nil
 }
 
 type ListProductsData struct {
@@ -10,6 +10,8 @@ type CreateProductBody struct {
 	Price       float64              `json:"price" binding:"required,gt=0"`
 	Status      *model.ProductStatus `json:"status" binding:"required,oneof=active inactive"`
 	Stock       int                  `json:"stock" binding:"required,gte=0"`
+	// PayTimeoutSeconds 支付时限（秒），不传或 0 使用全局配置
+	PayTimeoutSeconds int `json:"pay_timeout_seconds" binding:"omitempty,min=60,max=86400"`
 }
 
 type UriWithProductID struct {
@@ -25,6 +27,8 @@ type UpdateProductPropertyBody struct {
 	Name        *string  `json:"name" binding:"omitempty,min=2,max=120"`
 	Description *string  `json:"description" binding:"omitempty,max=3000"`
 	Price       *float64 `json:"price" binding:"omitempty,gt=0"`
+	// PayTimeoutSeconds 传 0 恢复使用全局配置
+	PayTimeoutSeconds *int `json:"pay_timeout_seconds" binding:"omitempty,eq=0|min=60,max=86400"`
 }
 type UpdateProductStatusBody struct {
 	Status model.ProductStatus `json:"status" binding:"required,oneof=active inactive"`
@@ -16,6 +16,11 @@ type Item struct {
 type Detail struct {
 	Item
 	Description string `json:"description"`
+	// AvailableStock 可售库存，ReservedStock 已下单未支付的冻结库存
+	AvailableStock int `json:"available_stock"`
+	ReservedStock  int `json:"reserved_stock"`
+	// PayTimeoutSeconds 支付时限（秒），0 表示使用全局配置
+	PayTimeoutSeconds int `json:"pay_timeout_seconds"`
 }
 
 type ListProductsResponse struct {
@@ -44,6 +49,10 @@ func FormatDetail(p *model.Product) *Detail {
 			Status:    string(p.Status),
 			CreatedAt: p.CreatedAt.Format("2006-01-02 15:04:05"),
 		},
-		Description: p.Description,
+		Description:    p.Description,
+		AvailableStock: p.Stock,
+		ReservedStock:  p.FrozenStock,
+
+		PayTimeoutSeconds: p.PayTimeoutSeconds,
 	}
 }
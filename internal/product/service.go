@@ -37,6 +37,8 @@ func (svc *Service) CreateProduct(ctx context.Context, param CreateProductParam)
 		Status:      param.Status,
 		Stock:       param.Stock,
 		Publisher:   param.Publisher,
+
+		PayTimeoutSeconds: param.PayTimeoutSeconds,
 	})
 }
 
@@ -68,6 +70,9 @@ func (svc *Service) UpdateProductProperty(ctx context.Context, param UpdateProdu
 	if param.Price != nil {
 		updateData["price"] = *param.Price
 	}
+	if param.PayTimeoutSeconds != nil {
+		updateData["pay_timeout_seconds"] = *param.PayTimeoutSeconds
+	}
 
 	return svc.repo.Update(ctx, UpdateProductPropertyData{
 		ProductID: param.ProductID,
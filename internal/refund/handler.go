@@ -0,0 +1,176 @@
+package refund
+
+import (
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/response"
+	"e-commerce/pkg/errno"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	svc *Service
+}
+
+func NewHandler(svc *Service) *Handler {
+	return &Handler{svc: svc}
+}
+
+// CreateRefund 买家发起退款申请
+func (h *Handler) CreateRefund(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var body CreateRefundBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	orderID, err := uuid.Parse(body.OrderID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	productID, err := uuid.Parse(body.ProductID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	r, err := h.svc.CreateRefund(ctx, CreateRefundParam{
+		UserID:    accountInfo.AccountId,
+		OrderID:   orderID,
+		ProductID: productID,
+		Quantity:  body.Quantity,
+		Reason:    body.Reason,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, CreateRefundResponse{RefundID: r.ID.String()})
+}
+
+// ApproveRefund 商品发布者同意退款
+func (h *Handler) ApproveRefund(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithRefundID
+	var body ReviewRefundBody
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	refundID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	r, err := h.svc.ApproveRefund(ctx, ReviewRefundParam{
+		RefundID:  refundID,
+		Publisher: accountInfo.AccountId,
+		Note:      body.Note,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, FormatRefundItem(r))
+}
+
+// RejectRefund 商品发布者拒绝退款
+func (h *Handler) RejectRefund(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var uri UriWithRefundID
+	var body RejectRefundBody
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	refundID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	r, err := h.svc.RejectRefund(ctx, ReviewRefundParam{
+		RefundID:  refundID,
+		Publisher: accountInfo.AccountId,
+		Note:      body.Note,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, FormatRefundItem(r))
+}
+
+// ListRefunds 查看退款申请：role=buyer（默认）为自己发起的，role=seller 为待自己审核的
+func (h *Handler) ListRefunds(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var query ListRefundsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	refunds, total, err := h.svc.ListRefunds(ctx, ListRefundsParam{
+		AccountID: accountInfo.AccountId,
+		AsSeller:  query.Role == "seller",
+		Status:    model.RefundStatus(query.Status),
+		PageNum:   query.PageNum,
+		PageSize:  query.PageSize,
+	})
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	items := make([]RefundItem, 0, len(refunds))
+	for _, r := range refunds {
+		items = append(items, *FormatRefundItem(r))
+	}
+	response.Write(c, nil, ListRefundsResponse{
+		Refunds: items,
+		Total:   total,
+	})
+}
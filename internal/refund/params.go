@@ -0,0 +1,29 @@
+package refund
+
+import (
+	"e-commerce/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type CreateRefundParam struct {
+	UserID    uuid.UUID
+	OrderID   uuid.UUID
+	ProductID uuid.UUID
+	Quantity  int
+	Reason    string
+}
+
+type ReviewRefundParam struct {
+	RefundID  uuid.UUID
+	Publisher uuid.UUID
+	Note      string
+}
+
+type ListRefundsParam struct {
+	AccountID uuid.UUID
+	AsSeller  bool
+	Status    model.RefundStatus
+	PageNum   int
+	PageSize  int
+}
@@ -0,0 +1,118 @@
+package refund
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/internal/pkg/database"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	repoErrRefundStatusChanged = errors.New("refund request status has been changed")
+)
+
+type Repository struct {
+	*database.BaseRepo
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{BaseRepo: database.NewBaseRepo(db)}
+}
+
+func (repo *Repository) CreateRefundRequest(ctx context.Context, r *model.RefundRequest) error {
+	return repo.GetDB(ctx).Create(r).Error
+}
+
+func (repo *Repository) GetRefundRequest(ctx context.Context, id uuid.UUID) (*model.RefundRequest, error) {
+	var r model.RefundRequest
+	err := repo.GetDB(ctx).Where("id = ?", id).First(&r).Error
+	return &r, err
+}
+
+// SumPendingQuantity 统计订单明细下待审核的退款数量
+func (repo *Repository) SumPendingQuantity(ctx context.Context, orderItemID uuid.UUID) (int, error) {
+	var total int
+	err := repo.GetDB(ctx).Model(&model.RefundRequest{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("order_item_id = ? AND status = ?", orderItemID, model.RefundStatusPending).
+		Scan(&total).Error
+	return total, err
+}
+
+// CountPendingByOrder 统计订单下待审核的退款申请数
+func (repo *Repository) CountPendingByOrder(ctx context.Context, orderID uuid.UUID) (int64, error) {
+	var count int64
+	err := repo.GetDB(ctx).Model(&model.RefundRequest{}).
+		Where("order_id = ? AND status = ?", orderID, model.RefundStatusPending).
+		Count(&count).Error
+	return count, err
+}
+
+type ReviewData struct {
+	ID     uuid.UUID
+	Status model.RefundStatus
+	Amount float64
+	Note   string
+}
+
+// Review 审核退款申请（仅待审核状态可更新）
+func (repo *Repository) Review(ctx context.Context, data ReviewData) error {
+	now := time.Now()
+	result := repo.GetDB(ctx).Model(&model.RefundRequest{}).
+		Where("id = ? AND status = ?", data.ID, model.RefundStatusPending).
+		Updates(map[string]interface{}{
+			"status":      data.Status,
+			"amount":      data.Amount,
+			"review_note": data.Note,
+			"reviewed_at": now,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return repoErrRefundStatusChanged
+	}
+	return nil
+}
+
+type ListRefundRequestsData struct {
+	UserID    uuid.UUID
+	Publisher uuid.UUID
+	Status    model.RefundStatus
+	PageNum   int
+	PageSize  int
+}
+
+// ListRefundRequests 按买家或商品发布者查询退款申请
+func (repo *Repository) ListRefundRequests(ctx context.Context, data ListRefundRequestsData) ([]*model.RefundRequest, int64, error) {
+	var requests []*model.RefundRequest
+	var total int64
+
+	baseQuery := repo.GetDB(ctx).Model(&model.RefundRequest{})
+	if data.UserID != uuid.Nil {
+		baseQuery = baseQuery.Where("user_id = ?", data.UserID)
+	}
+	if data.Publisher != uuid.Nil {
+		baseQuery = baseQuery.Where("publisher = ?", data.Publisher)
+	}
+	if data.Status != "" {
+		baseQuery = baseQuery.Where("status = ?", data.Status)
+	}
+
+	if err := baseQuery.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	err := baseQuery.Session(&gorm.Session{}).
+		Offset((data.PageNum - 1) * data.PageSize).
+		Limit(data.PageSize).
+		Order("created_at DESC").
+		Find(&requests).Error
+
+	return requests, total, err
+}
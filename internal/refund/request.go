@@ -0,0 +1,27 @@
+package refund
+
+type CreateRefundBody struct {
+	OrderID   string `json:"order_id" binding:"required"`
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+	Reason    string `json:"reason" binding:"required,max=512"`
+}
+
+type UriWithRefundID struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+type ReviewRefundBody struct {
+	Note string `json:"note" binding:"omitempty,max=512"`
+}
+
+type RejectRefundBody struct {
+	Note string `json:"note" binding:"required,max=512"`
+}
+
+type ListRefundsQuery struct {
+	Role     string `form:"role" binding:"omitempty,oneof=buyer seller"`
+	Status   string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	PageNum  int    `form:"page_num" binding:"required,gt=0"`
+	PageSize int    `form:"page_size" binding:"required,max=20"`
+}
@@ -0,0 +1,46 @@
+package refund
+
+import (
+	"e-commerce/internal/model"
+	"time"
+)
+
+type RefundItem struct {
+	ID         string  `json:"id"`
+	OrderID    string  `json:"order_id"`
+	ProductID  string  `json:"product_id"`
+	Quantity   int     `json:"quantity"`
+	Amount     float64 `json:"amount"`
+	Reason     string  `json:"reason"`
+	Status     string  `json:"status"`
+	ReviewNote string  `json:"review_note"`
+	ReviewedAt string  `json:"reviewed_at,omitempty"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+type CreateRefundResponse struct {
+	RefundID string `json:"refund_id"`
+}
+
+type ListRefundsResponse struct {
+	Refunds []RefundItem `json:"refunds"`
+	Total   int64        `json:"total"`
+}
+
+func FormatRefundItem(r *model.RefundRequest) *RefundItem {
+	item := &RefundItem{
+		ID:         r.ID.String(),
+		OrderID:    r.OrderID.String(),
+		ProductID:  r.ProductID.String(),
+		Quantity:   r.Quantity,
+		Amount:     r.Amount,
+		Reason:     r.Reason,
+		Status:     string(r.Status),
+		ReviewNote: r.ReviewNote,
+		CreatedAt:  r.CreatedAt.Format(time.DateTime),
+	}
+	if r.ReviewedAt != nil {
+		item.ReviewedAt = r.ReviewedAt.Format(time.DateTime)
+	}
+	return item
+}
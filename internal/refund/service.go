@@ -0,0 +1,313 @@
+package refund
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/internal/order"
+	"e-commerce/internal/pkg/database"
+	"e-commerce/internal/product"
+	"e-commerce/internal/wallet"
+	"e-commerce/pkg/clog"
+	"e-commerce/pkg/errno"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type Service struct {
+	db          *gorm.DB
+	repo        *Repository
+	orderRepo   *order.Repository
+	productRepo *product.Repository
+	walletRepo  *wallet.Repository
+}
+
+func NewService(db *gorm.DB, repo *Repository, orderRepo *order.Repository, productRepo *product.Repository, walletRepo *wallet.Repository) *Service {
+	return &Service{db: db, repo: repo, orderRepo: orderRepo, productRepo: productRepo, walletRepo: walletRepo}
+}
+
+// CreateRefund 买家对已完成订单中的某个商品发起退款申请，订单进入退款中
+func (svc *Service) CreateRefund(ctx context.Context, param CreateRefundParam) (*model.RefundRequest, error) {
+	var request *model.RefundRequest
+
+	err := database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		o, err := svc.orderRepo.GetUserOrderForUpdate(ctx, param.OrderID, param.UserID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errno.ErrOrderNotFound
+			}
+			return err
+		}
+		if o.Status != model.OrderStatusCompleted && o.Status != model.OrderStatusRefunding {
+			return errno.ErrOrderStatusInvalid
+		}
+
+		item := findItemByProduct(o, param.ProductID)
+		if item == nil {
+			return errno.ErrRefundOrderItemNotFound
+		}
+
+		// 订单行锁保证同一订单的申请串行，待审核数量也计入占用
+		pending, err := svc.repo.SumPendingQuantity(ctx, item.ID)
+		if err != nil {
+			return err
+		}
+		if param.Quantity > item.RefundableQuantity()-pending {
+			return errno.ErrRefundQuantityExceeded
+		}
+
+		p, err := svc.productRepo.GetProduct(ctx, item.ProductID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errno.ErrProductNotFound
+			}
+			return err
+		}
+
+		request = &model.RefundRequest{
+			OrderID:     o.ID,
+			OrderItemID: item.ID,
+			UserID:      param.UserID,
+			ProductID:   item.ProductID,
+			Publisher:   p.Publisher,
+			Quantity:    param.Quantity,
+			Reason:      param.Reason,
+			Status:      model.RefundStatusPending,
+		}
+		if err := svc.repo.CreateRefundRequest(ctx, request); err != nil {
+			return err
+		}
+
+		if o.Status == model.OrderStatusCompleted {
+			return svc.orderRepo.UpdateOrderStatus(ctx, o.ID, model.OrderStatusCompleted, model.OrderStatusRefunding,
+				order.BuyerChange(param.UserID, "发起退款"))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// ApproveRefund 商品发布者同意退款：退款入账、商品重新入库、更新订单退款进度在同一事务内
+func (svc *Service) ApproveRefund(ctx context.Context, param ReviewRefundParam) (*model.RefundRequest, error) {
+	var request *model.RefundRequest
+
+	err := database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		var o *model.Order
+		var err error
+		request, o, err = svc.prepareReview(ctx, param)
+		if err != nil {
+			return err
+		}
+
+		item := findItemByID(o, request.OrderItemID)
+		if item == nil {
+			return fmt.Errorf("退款申请对应的订单明细不存在 refund=%s item=%s", request.ID, request.OrderItemID)
+		}
+		if request.Quantity > item.RefundableQuantity() {
+			return errno.ErrRefundQuantityExceeded
+		}
+		amount := calcRefundAmount(o, item, request.Quantity)
+
+		if err := svc.repo.Review(ctx, ReviewData{
+			ID:     request.ID,
+			Status: model.RefundStatusApproved,
+			Amount: amount,
+			Note:   param.Note,
+		}); err != nil {
+			if errors.Is(err, repoErrRefundStatusChanged) {
+				return errno.ErrRefundStatusInvalid
+			}
+			return err
+		}
+
+		if err := svc.orderRepo.ApplyRefund(ctx, o.ID, item.ID, request.Quantity, amount); err != nil {
+			return err
+		}
+		item.RefundedQuantity += request.Quantity
+		o.RefundedAmount += amount
+
+		if amount > 0 {
+			// 入账到买家钱包，审核人的会话不属于买家，流水不记录会话；审核人见订单状态历史
+			if err := svc.walletRepo.Credit(ctx, wallet.CreditData{
+				UserID:         request.UserID,
+				Amount:         amount,
+				Type:           model.WalletLogTypeRefund,
+				IdempotencyKey: "refund:" + request.ID.String(),
+			}); err != nil {
+				return err
+			}
+		}
+
+		if err := svc.productRepo.RestoreStock(ctx, item.ProductID, request.Quantity, model.StockChangeRefund); err != nil {
+			return fmt.Errorf("退款入库失败 refund=%s product=%s: %w", request.ID, item.ProductID, err)
+		}
+
+		request.Status = model.RefundStatusApproved
+		request.Amount = amount
+		return svc.syncOrderStatus(ctx, o, order.SellerChange(param.Publisher, "同意退款"))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	clog.L(ctx).Info("退款审核通过",
+		zap.String("refund_id", request.ID.String()),
+		zap.String("order_id", request.OrderID.String()),
+		zap.Float64("amount", request.Amount),
+	)
+	return request, nil
+}
+
+// RejectRefund 商品发布者拒绝退款
+func (svc *Service) RejectRefund(ctx context.Context, param ReviewRefundParam) (*model.RefundRequest, error) {
+	var request *model.RefundRequest
+
+	err := database.ExecuteTransaction(ctx, svc.db, func(ctx context.Context) error {
+		var o *model.Order
+		var err error
+		request, o, err = svc.prepareReview(ctx, param)
+		if err != nil {
+			return err
+		}
+
+		if err := svc.repo.Review(ctx, ReviewData{
+			ID:     request.ID,
+			Status: model.RefundStatusRejected,
+			Note:   param.Note,
+		}); err != nil {
+			if errors.Is(err, repoErrRefundStatusChanged) {
+				return errno.ErrRefundStatusInvalid
+			}
+			return err
+		}
+
+		request.Status = model.RefundStatusRejected
+		return svc.syncOrderStatus(ctx, o, order.SellerChange(param.Publisher, "拒绝退款"))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// prepareReview 校验审核人并锁定订单（先锁订单再改申请，与发起申请的加锁顺序一致）
+func (svc *Service) prepareReview(ctx context.Context, param ReviewRefundParam) (*model.RefundRequest, *model.Order, error) {
+	request, err := svc.repo.GetRefundRequest(ctx, param.RefundID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, errno.ErrRefundNotFound
+		}
+		return nil, nil, err
+	}
+	// 非本商品发布者按不存在处理，避免泄露他人的申请
+	if request.Publisher != param.Publisher {
+		return nil, nil, errno.ErrRefundNotFound
+	}
+	if request.Status != model.RefundStatusPending {
+		return nil, nil, errno.ErrRefundStatusInvalid
+	}
+
+	o, err := svc.orderRepo.GetOrderForUpdate(ctx, request.OrderID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询退款订单失败 refund=%s: %w", request.ID, err)
+	}
+	return request, o, nil
+}
+
+// syncOrderStatus 按退款进度更新订单状态：有待审核申请为退款中，全部退完为已退款，否则回到已完成
+func (svc *Service) syncOrderStatus(ctx context.Context, o *model.Order, change order.StatusChange) error {
+	pending, err := svc.repo.CountPendingByOrder(ctx, o.ID)
+	if err != nil {
+		return err
+	}
+
+	target := model.OrderStatusCompleted
+	switch {
+	case pending > 0:
+		target = model.OrderStatusRefunding
+	case fullyRefunded(o):
+		target = model.OrderStatusRefunded
+	}
+	if target == o.Status {
+		return nil
+	}
+
+	if err := svc.orderRepo.UpdateOrderStatus(ctx, o.ID, o.Status, target, change); err != nil {
+		return err
+	}
+	o.Status = target
+	return nil
+}
+
+func (svc *Service) ListRefunds(ctx context.Context, param ListRefundsParam) ([]*model.RefundRequest, int64, error) {
+	data := ListRefundRequestsData{
+		Status:   param.Status,
+		PageNum:  param.PageNum,
+		PageSize: param.PageSize,
+	}
+	if param.AsSeller {
+		data.Publisher = param.AccountID
+	} else {
+		data.UserID = param.AccountID
+	}
+	return svc.repo.ListRefundRequests(ctx, data)
+}
+
+// calcRefundAmount 按实付比例分摊优惠计算退款金额；最后一笔退款补齐剩余实付金额，避免分摊误差
+func calcRefundAmount(o *model.Order, item *model.OrderItem, quantity int) float64 {
+	remaining := o.PayableAmount() - o.RefundedAmount
+	if remaining <= 0 {
+		return 0
+	}
+
+	refundedQty := 0
+	totalQty := 0
+	for i := range o.Items {
+		refundedQty += o.Items[i].RefundedQuantity
+		totalQty += o.Items[i].Quantity
+	}
+	if refundedQty+quantity >= totalQty {
+		return remaining
+	}
+
+	amount := item.SnapshotPrice * float64(quantity)
+	if o.TotalAmount > 0 {
+		amount = amount * o.PayableAmount() / o.TotalAmount
+	}
+	amount = math.Round(amount*100) / 100
+	return math.Min(amount, remaining)
+}
+
+func fullyRefunded(o *model.Order) bool {
+	for i := range o.Items {
+		if o.Items[i].RefundableQuantity() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func findItemByProduct(o *model.Order, productID uuid.UUID) *model.OrderItem {
+	for i := range o.Items {
+		if o.Items[i].ProductID == productID {
+			return &o.Items[i]
+		}
+	}
+	return nil
+}
+
+func findItemByID(o *model.Order, itemID uuid.UUID) *model.OrderItem {
+	for i := range o.Items {
+		if o.Items[i].ID == itemID {
+			return &o.Items[i]
+		}
+	}
+	return nil
+}
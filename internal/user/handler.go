@@ -1,11 +1,23 @@
 package user
 
 import (
+	"e-commerce/internal/address"
+	"e-commerce/internal/app/identity"
 	"e-commerce/internal/auth"
+	"e-commerce/internal/coupon"
+	"e-commerce/internal/model"
+	"e-commerce/internal/order"
 	"e-commerce/internal/pkg/response"
+	"e-commerce/internal/wallet"
 	"e-commerce/pkg/clog"
+	"e-commerce/pkg/errno"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -22,6 +34,54 @@ type RegisterDTO struct {
 	Password string `json:"password" binding:"required,min=8,max=32"`
 }
 
+type UriWithUserID struct {
+	ID string `uri:"id" binding:"required"`
+}
+
+type UpdateRoleDTO struct {
+	Role model.UserRole `json:"role" binding:"required,oneof=admin seller buyer"`
+}
+
+type ChangePasswordDTO struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=32"`
+}
+
+type ForgotPasswordDTO struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordDTO struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=32"`
+}
+
+// UpdateProfileDTO 只修改传入的字段，修改邮箱时需提供当前密码
+type UpdateProfileDTO struct {
+	UserName *string `json:"user_name" binding:"omitempty,min=3,max=32"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+	Password string  `json:"password" binding:"required_with=Email"`
+}
+
+type DeleteAccountDTO struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// AccountExport 个人数据导出文件
+type AccountExport struct {
+	ExportedAt string                      `json:"exported_at"`
+	Profile    *Profile                    `json:"profile"`
+	Orders     []order.OrderDetailResponse `json:"orders"`
+	WalletLogs []wallet.WalletLogItem      `json:"wallet_logs"`
+	Coupons    []coupon.UserCouponItem     `json:"coupons"`
+	Addresses  []address.AddressItem       `json:"addresses"`
+	Sessions   []auth.SessionItem          `json:"sessions"`
+}
+
+type VerifyEmailQuery struct {
+	Token string `form:"token" binding:"required"`
+}
+
 func NewHandler(userSvc *Service, authSvc *auth.Service) *Handler {
 	return &Handler{userSvc: userSvc, authSvc: authSvc}
 }
@@ -61,3 +121,273 @@ func (h *Handler) Register(c *gin.Context) {
 	logger.Info("用户注册成功")
 	response.Write(c, nil, nil)
 }
+
+// UpdateRole 管理员修改用户角色
+func (h *Handler) UpdateRole(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var uri UriWithUserID
+	if err := c.ShouldBindUri(&uri); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+	var dto UpdateRoleDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	userID, err := uuid.Parse(uri.ID)
+	if err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.userSvc.UpdateRole(ctx, userID, dto.Role); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// ChangePassword 修改密码，成功后注销除当前设备外的所有会话
+func (h *Handler) ChangePassword(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var dto ChangePasswordDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.userSvc.ChangePassword(ctx, accountInfo.AccountId, &ChangePasswordInput{
+		OldPassword: dto.OldPassword,
+		NewPassword: dto.NewPassword,
+	}); err != nil {
+		writePasswordCheckError(c, err)
+		return
+	}
+
+	revoked, err := h.authSvc.RevokeOtherSessions(ctx, accountInfo)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, gin.H{
+		"revoked_sessions": revoked,
+	})
+}
+
+// ForgotPassword 申请重置密码，无论邮箱是否注册都返回成功
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var dto ForgotPasswordDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.userSvc.RequestPasswordReset(ctx, dto.Email); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// ResetPassword 使用邮件中的一次性令牌重置密码，成功后注销该用户的全部会话
+func (h *Handler) ResetPassword(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var dto ResetPasswordDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	userID, err := h.userSvc.ResetPassword(ctx, dto.Token, dto.NewPassword)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	if _, err := h.authSvc.RevokeAllSessions(ctx, userID); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// VerifyEmail 打开验证邮件中的链接完成邮箱验证
+func (h *Handler) VerifyEmail(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var query VerifyEmailQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.userSvc.VerifyEmail(ctx, query.Token); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// ResendVerification 重新发送邮箱验证邮件
+func (h *Handler) ResendVerification(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	err := h.userSvc.ResendVerification(ctx, accountInfo.AccountId)
+	var e *errno.Errno
+	if errors.As(err, &e) {
+		if data, ok := e.Data.(VerifyResendData); ok {
+			c.Header("Retry-After", strconv.Itoa(data.RetryAfterSeconds))
+		}
+	}
+	response.Write(c, err, nil)
+}
+
+// GetProfile 查看个人资料
+func (h *Handler) GetProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	profile, err := h.userSvc.GetProfile(ctx, accountInfo.AccountId)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, profile)
+}
+
+// UpdateProfile 修改用户名或邮箱，修改邮箱后需重新验证
+func (h *Handler) UpdateProfile(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var dto UpdateProfileDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	profile, err := h.userSvc.UpdateProfile(ctx, accountInfo.AccountId, &UpdateProfileInput{
+		UserName: dto.UserName,
+		Email:    dto.Email,
+		Password: dto.Password,
+	})
+	if err != nil {
+		writePasswordCheckError(c, err)
+		return
+	}
+	response.Write(c, nil, profile)
+}
+
+// ExportAccount 导出个人资料、订单、钱包流水、优惠券、收货地址和登录会话，以附件形式下载
+func (h *Handler) ExportAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	data, err := h.userSvc.ExportAccount(ctx, accountInfo.AccountId)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	sessions, err := h.authSvc.ListSessions(ctx, accountInfo)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	export := &AccountExport{
+		ExportedAt: time.Now().Format(time.RFC3339),
+		Profile:    data.Profile,
+		Orders:     make([]order.OrderDetailResponse, 0, len(data.Orders)),
+		WalletLogs: make([]wallet.WalletLogItem, 0, len(data.WalletLogs)),
+		Coupons:    make([]coupon.UserCouponItem, 0, len(data.Coupons)),
+		Addresses:  make([]address.AddressItem, 0, len(data.Addresses)),
+		Sessions:   auth.FormatSessions(sessions, accountInfo.SessionID),
+	}
+	for _, o := range data.Orders {
+		export.Orders = append(export.Orders, *order.FormatOrderDetail(&order.OrderDetail{Order: o}))
+	}
+	for _, l := range data.WalletLogs {
+		export.WalletLogs = append(export.WalletLogs, *wallet.FormatWalletLog(l))
+	}
+	for _, uc := range data.Coupons {
+		export.Coupons = append(export.Coupons, *coupon.FormatUserCoupon(uc))
+	}
+	for _, a := range data.Addresses {
+		export.Addresses = append(export.Addresses, *address.FormatAddressItem(a))
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="account-%s.json"`, accountInfo.AccountId))
+	response.Write(c, nil, export)
+}
+
+// DeleteAccount 注销账号，成功后注销该用户的全部会话
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	var dto DeleteAccountDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	if err := h.userSvc.DeleteAccount(ctx, accountInfo.AccountId, dto.Password); err != nil {
+		writePasswordCheckError(c, err)
+		return
+	}
+	if _, err := h.authSvc.RevokeAllSessions(ctx, accountInfo.AccountId); err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+	response.Write(c, nil, nil)
+}
+
+// writePasswordCheckError 当前密码错误次数过多、账号被锁定时设置 Retry-After 响应头
+func writePasswordCheckError(c *gin.Context, err error) {
+	var e *errno.Errno
+	if errors.As(err, &e) {
+		if locked, ok := e.Data.(auth.LoginLockedData); ok {
+			c.Header("Retry-After", strconv.Itoa(locked.RetryAfterSeconds))
+		}
+	}
+	response.Write(c, err, nil)
+}
@@ -6,7 +6,10 @@ import (
 	"e-commerce/internal/pkg/database"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
@@ -15,8 +18,21 @@ import (
 var (
 	repoErrEmailAlreadyExists    = errors.New("email already exists")
 	repoErrUserNameAlreadyExists = errors.New("user_name already exists")
+	repoErrTokenNotFound         = errors.New("one-time token not found")
 )
 
+// tokenPurpose 一次性令牌用途，Redis 中令牌（SHA-256 摘要）到用户 ID 的映射为 user:<purpose>:<hash>，
+// 用户当前有效的令牌摘要为 user:<purpose>_user:<uid>，重新申请时用于作废旧令牌
+type tokenPurpose string
+
+const (
+	tokenPurposePasswordReset tokenPurpose = "pwd_reset"
+	tokenPurposeEmailVerify   tokenPurpose = "email_verify"
+)
+
+// verifyResendPrefix 重发验证邮件的冷却标记
+var verifyResendPrefix = "user:email_verify_cooldown"
+
 var constraintMap = map[string]error{
 	model.ConstraintUserEmail: repoErrEmailAlreadyExists,
 	model.ConstraintUserName:  repoErrUserNameAlreadyExists,
@@ -24,25 +40,225 @@ var constraintMap = map[string]error{
 
 type Repository struct {
 	*database.BaseRepo
+	rdb *redis.Client
 }
 
-func NewRepository(db *gorm.DB) *Repository {
+func NewRepository(db *gorm.DB, rdb *redis.Client) *Repository {
 	return &Repository{
 		BaseRepo: database.NewBaseRepo(db),
+		rdb:      rdb,
 	}
 }
 
+func genTokenKey(purpose tokenPurpose, tokenHash string) string {
+	return fmt.Sprintf("user:%s:%s", purpose, tokenHash)
+}
+func genTokenUserKey(purpose tokenPurpose, userID uuid.UUID) string {
+	return fmt.Sprintf("user:%s_user:%s", purpose, userID)
+}
+func genVerifyResendKey(userID uuid.UUID) string {
+	return fmt.Sprintf("%s:%s", verifyResendPrefix, userID)
+}
+
+// translateError 将用户名、邮箱唯一约束冲突转换为业务错误
+func translateError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.SQLState() == pgerrcode.UniqueViolation {
+		if businessErr, ok := constraintMap[pgErr.ConstraintName]; ok {
+			return businessErr
+		}
+	}
+	return fmt.Errorf("execute query error %w", err)
+}
+
 func (repo *Repository) CreateUser(ctx context.Context, user *model.User) error {
 	result := repo.GetDB(ctx).Create(user)
 	if result.Error != nil {
-		var pgErr *pgconn.PgError
-		if errors.As(result.Error, &pgErr) && pgErr.SQLState() == pgerrcode.UniqueViolation {
-			if businessErr, ok := constraintMap[pgErr.ConstraintName]; ok {
-				return businessErr
-			}
-		}
+		return translateError(result.Error)
+	}
+
+	return nil
+}
+
+// ProfileUpdate 修改资料时需要更新的字段，为空表示不修改
+type ProfileUpdate struct {
+	UserName *string
+	// Email 修改邮箱时同时清空验证时间
+	Email *string
+}
+
+// UpdateProfile 更新用户名或邮箱，用户不存在时返回 gorm.ErrRecordNotFound
+func (repo *Repository) UpdateProfile(ctx context.Context, userID uuid.UUID, update ProfileUpdate) error {
+	updates := map[string]interface{}{}
+	if update.UserName != nil {
+		updates["user_name"] = *update.UserName
+	}
+	if update.Email != nil {
+		updates["email"] = *update.Email
+		updates["email_verified_at"] = nil
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	result := repo.GetDB(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Updates(updates)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// UpdateRole 修改用户角色，用户不存在时返回 gorm.ErrRecordNotFound
+func (repo *Repository) UpdateRole(ctx context.Context, userID uuid.UUID, role model.UserRole) error {
+	result := repo.GetDB(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Update("role", role)
+	if result.Error != nil {
+		return fmt.Errorf("execute query error %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (repo *Repository) FindUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	var user model.User
+	result := repo.GetDB(ctx).Where("id = ?", userID).First(&user)
+	return &user, result.Error
+}
+
+func (repo *Repository) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	result := repo.GetDB(ctx).Where("email = ?", email).First(&user)
+	return &user, result.Error
+}
+
+// UpdatePassword 更新密码哈希，用户不存在时返回 gorm.ErrRecordNotFound
+func (repo *Repository) UpdatePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	result := repo.GetDB(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Update("password", passwordHash)
+	if result.Error != nil {
+		return fmt.Errorf("execute query error %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteAccount 软删除用户并将用户名、邮箱替换为匿名值以释放唯一约束，同时清空密码和两步验证数据
+func (repo *Repository) DeleteAccount(ctx context.Context, userID uuid.UUID, anonUserName, anonEmail string) error {
+	db := repo.GetDB(ctx)
+	result := db.Model(&model.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"user_name":         anonUserName,
+			"email":             anonEmail,
+			"password":          "",
+			"email_verified_at": nil,
+			"deleted_at":        time.Now(),
+		})
+	if result.Error != nil {
 		return fmt.Errorf("execute query error %w", result.Error)
 	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&model.UserRecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("execute query error %w", err)
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&model.UserTOTP{}).Error; err != nil {
+		return fmt.Errorf("execute query error %w", err)
+	}
+	return nil
+}
+
+// saveToken 保存一次性令牌摘要，同一用户同一用途只保留最新的令牌
+func (repo *Repository) saveToken(ctx context.Context, purpose tokenPurpose, userID uuid.UUID, tokenHash string, expire time.Duration) error {
+	userKey := genTokenUserKey(purpose, userID)
+	oldHash, err := repo.rdb.Get(ctx, userKey).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return err
+	}
+	_, err = repo.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if oldHash != "" {
+			pipe.Del(ctx, genTokenKey(purpose, oldHash))
+		}
+		pipe.Set(ctx, genTokenKey(purpose, tokenHash), userID.String(), expire)
+		pipe.Set(ctx, userKey, tokenHash, expire)
+		return nil
+	})
+	return err
+}
+
+// consumeToken 取出并删除一次性令牌，令牌不存在或已过期时返回 repoErrTokenNotFound
+func (repo *Repository) consumeToken(ctx context.Context, purpose tokenPurpose, tokenHash string) (uuid.UUID, error) {
+	val, err := repo.rdb.GetDel(ctx, genTokenKey(purpose, tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return uuid.Nil, repoErrTokenNotFound
+	} else if err != nil {
+		return uuid.Nil, err
+	}
+	userID, err := uuid.Parse(val)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid token owner %q: %w", val, err)
+	}
+	if err := repo.rdb.Del(ctx, genTokenUserKey(purpose, userID)).Err(); err != nil {
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// deleteToken 作废用户尚未使用的一次性令牌
+func (repo *Repository) deleteToken(ctx context.Context, purpose tokenPurpose, userID uuid.UUID) error {
+	tokenHash, err := repo.rdb.GetDel(ctx, genTokenUserKey(purpose, userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return repo.rdb.Del(ctx, genTokenKey(purpose, tokenHash)).Err()
+}
 
+// MarkEmailVerified 标记邮箱已验证，已验证过的保持原时间
+func (repo *Repository) MarkEmailVerified(ctx context.Context, userID uuid.UUID, verifiedAt time.Time) error {
+	result := repo.GetDB(ctx).Model(&model.User{}).
+		Where("id = ? AND email_verified_at IS NULL", userID).
+		Update("email_verified_at", verifiedAt)
+	if result.Error != nil {
+		return fmt.Errorf("execute query error %w", result.Error)
+	}
 	return nil
 }
+
+// resetVerifyResend 重新开始重发冷却期，用于修改邮箱后立即发送的验证邮件
+func (repo *Repository) resetVerifyResend(ctx context.Context, userID uuid.UUID, interval time.Duration) error {
+	return repo.rdb.Set(ctx, genVerifyResendKey(userID), 1, interval).Err()
+}
+
+// acquireVerifyResend 占用重发冷却期，冷却中返回剩余时间
+func (repo *Repository) acquireVerifyResend(ctx context.Context, userID uuid.UUID, interval time.Duration) (time.Duration, error) {
+	key := genVerifyResendKey(userID)
+	ok, err := repo.rdb.SetNX(ctx, key, 1, interval).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ok {
+		return 0, nil
+	}
+	ttl, err := repo.rdb.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		// 冷却标记恰好过期，按冷却已结束处理
+		return 0, repo.rdb.Set(ctx, key, 1, interval).Err()
+	}
+	return ttl, nil
+}
@@ -2,15 +2,32 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"e-commerce/internal/address"
+	"e-commerce/internal/auth"
+	"e-commerce/internal/config"
+	"e-commerce/internal/coupon"
 	"e-commerce/internal/model"
+	"e-commerce/internal/order"
 	"e-commerce/internal/pkg/database"
 	"e-commerce/internal/wallet"
+	"e-commerce/pkg/clog"
 	"e-commerce/pkg/errno"
+	"e-commerce/pkg/mailer"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
+	"net/url"
+	"time"
 
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type RegisterInput struct {
@@ -19,14 +36,65 @@ type RegisterInput struct {
 	Password string
 }
 
+type ChangePasswordInput struct {
+	OldPassword string
+	NewPassword string
+}
+
+type UpdateProfileInput struct {
+	UserName *string
+	Email    *string
+	// Password 修改邮箱时需校验当前密码
+	Password string
+}
+
+// Profile 个人资料，附带钱包余额、可用优惠券和未结束订单数量
+type Profile struct {
+	ID            uuid.UUID      `json:"id"`
+	UserName      string         `json:"user_name"`
+	Email         string         `json:"email"`
+	EmailVerified bool           `json:"email_verified"`
+	Role          model.UserRole `json:"role"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Balance       float64        `json:"balance"`
+	UnusedCoupons int64          `json:"unused_coupons"`
+	OpenOrders    int64          `json:"open_orders"`
+}
+
+// AccountData 导出的个人数据，会话信息由调用方从认证服务补充
+type AccountData struct {
+	Profile    *Profile
+	Orders     []*model.Order
+	WalletLogs []*model.WalletLog
+	Coupons    []*model.UserCoupon
+	Addresses  []*model.Address
+}
+
 type Service struct {
-	repo       *Repository
-	walletRepo *wallet.Repository
-	metrics    *Metrics
+	repo        *Repository
+	walletRepo  *wallet.Repository
+	couponRepo  *coupon.Repository
+	orderRepo   *order.Repository
+	addressRepo *address.Repository
+	authSvc     *auth.Service
+	metrics     *Metrics
+	mailer      mailer.Mailer
+	config      *config.UserSection
 }
 
-func NewService(repository *Repository, walletRepo *wallet.Repository, metrics *Metrics) *Service {
-	return &Service{repo: repository, walletRepo: walletRepo, metrics: metrics}
+func NewService(repository *Repository, walletRepo *wallet.Repository, couponRepo *coupon.Repository, orderRepo *order.Repository,
+	addressRepo *address.Repository, authSvc *auth.Service, metrics *Metrics, mailer mailer.Mailer, config *config.UserSection) *Service {
+	return &Service{
+		repo:        repository,
+		walletRepo:  walletRepo,
+		couponRepo:  couponRepo,
+		orderRepo:   orderRepo,
+		addressRepo: addressRepo,
+		authSvc:     authSvc,
+		metrics:     metrics,
+		mailer:      mailer,
+		config:      config,
+	}
 }
 
 func (svc *Service) Register(ctx context.Context, input *RegisterInput) (err error) {
@@ -49,12 +117,12 @@ func (svc *Service) Register(ctx context.Context, input *RegisterInput) (err err
 	}
 	span.End()
 
+	user := &model.User{
+		UserName: input.UserName,
+		Email:    input.Email,
+		Password: string(bytes),
+	}
 	if err := database.ExecuteTransaction(ctx, svc.repo.GetDB(ctx), func(txCtx context.Context) error {
-		user := &model.User{
-			UserName: input.UserName,
-			Email:    input.Email,
-			Password: string(bytes),
-		}
 		if err := svc.repo.CreateUser(txCtx, user); err != nil {
 			if errors.Is(err, repoErrUserNameAlreadyExists) {
 				errCode = MetErrCodeUserRegistered
@@ -72,5 +140,415 @@ func (svc *Service) Register(ctx context.Context, input *RegisterInput) (err err
 	}); err != nil {
 		return fmt.Errorf("transaction failed: %w", err)
 	}
+
+	// 验证邮件发送失败不影响注册，用户可立即重发；发送成功后开始重发冷却
+	if err := svc.sendVerificationEmail(ctx, user); err != nil {
+		clog.L(ctx).Error("发送邮箱验证邮件失败", zap.String("user_id", user.ID.String()), zap.Error(err))
+	} else if _, err := svc.repo.acquireVerifyResend(ctx, user.ID, svc.config.VerifyResendInterval); err != nil {
+		clog.L(ctx).Warn("设置验证邮件重发冷却失败", zap.String("user_id", user.ID.String()), zap.Error(err))
+	}
+	return nil
+}
+
+// UpdateRole 管理员修改用户角色，已签发的令牌在下次刷新时携带新角色
+func (svc *Service) UpdateRole(ctx context.Context, userID uuid.UUID, role model.UserRole) error {
+	if !role.IsValid() {
+		return errno.ErrInvalidParam
+	}
+	if err := svc.repo.UpdateRole(ctx, userID, role); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrNotFoundRecord
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	return nil
+}
+
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := otel.Tracer("user-service").Start(ctx, "BcryptHash")
+	defer span.End()
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		span.RecordError(err)
+		return "", fmt.Errorf("password hash failed: %w", err)
+	}
+	return string(bytes), nil
+}
+
+// verifyPassword 校验已登录用户的当前密码：错误计入账号的登录防爆破，账号锁定期内直接拒绝
+func (svc *Service) verifyPassword(ctx context.Context, user *model.User, password string) error {
+	if err := svc.authSvc.CheckPasswordLock(ctx, user.Email); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		if err := svc.authSvc.RecordPasswordFailure(ctx, user.Email); err != nil {
+			return err
+		}
+		return errno.ErrUserPasswordIncorrect
+	}
+	return nil
+}
+
+// ChangePassword 校验原密码后更新密码，并作废尚未使用的重置令牌；注销其他会话由调用方负责
+func (svc *Service) ChangePassword(ctx context.Context, userID uuid.UUID, input *ChangePasswordInput) error {
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrNotFoundRecord
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	if err := svc.verifyPassword(ctx, user, input.OldPassword); err != nil {
+		return err
+	}
+	if input.OldPassword == input.NewPassword {
+		return errno.ErrUserPasswordUnchanged
+	}
+	return svc.setPassword(ctx, userID, input.NewPassword)
+}
+
+func (svc *Service) setPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	hash, err := hashPassword(ctx, password)
+	if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if err := svc.repo.UpdatePassword(ctx, userID, hash); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrNotFoundRecord
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	if err := svc.repo.deleteToken(ctx, tokenPurposePasswordReset, userID); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	return nil
+}
+
+// hashToken Redis 中只保存令牌摘要，缓存泄露时无法直接使用
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func genToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token fail: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// tokenLink 将令牌作为 token 查询参数附加到页面地址
+func tokenLink(base, token string) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return token
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// RequestPasswordReset 生成一次性重置令牌并通过邮件发送；邮箱未注册时同样返回成功，避免泄露账号是否存在
+func (svc *Service) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := svc.repo.FindUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			clog.L(ctx).Info("密码重置邮箱未注册，忽略请求")
+			return nil
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+
+	token, err := genToken()
+	if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	expire := svc.config.PasswordResetExpire
+	if err := svc.repo.saveToken(ctx, tokenPurposePasswordReset, user.ID, hashToken(token), expire); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+
+	err = svc.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "重置密码",
+		Body: fmt.Sprintf("%s，您好：\n\n请在 %d 分钟内访问以下链接重置密码，链接仅可使用一次：\n%s\n\n如非本人操作，请忽略本邮件。",
+			user.UserName, int(expire.Minutes()), tokenLink(svc.config.PasswordResetURL, token)),
+	})
+	if err != nil {
+		clog.L(ctx).Error("发送密码重置邮件失败", zap.String("user_id", user.ID.String()), zap.Error(err))
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	return nil
+}
+
+// ResetPassword 消费重置令牌并设置新密码，返回用户 ID 供调用方注销该用户的全部会话
+func (svc *Service) ResetPassword(ctx context.Context, token, newPassword string) (uuid.UUID, error) {
+	userID, err := svc.repo.consumeToken(ctx, tokenPurposePasswordReset, hashToken(token))
+	if err != nil {
+		if errors.Is(err, repoErrTokenNotFound) {
+			return uuid.Nil, errno.ErrUserResetTokenInvalid
+		}
+		return uuid.Nil, errno.ErrInternalServer.WithRaw(err)
+	}
+	if err := svc.setPassword(ctx, userID, newPassword); err != nil {
+		if errors.Is(err, errno.ErrNotFoundRecord) {
+			return uuid.Nil, errno.ErrUserResetTokenInvalid
+		}
+		return uuid.Nil, err
+	}
+	return userID, nil
+}
+
+// VerifyResendData 重发验证邮件过于频繁时随错误返回，告知客户端何时可以重试
+type VerifyResendData struct {
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// sendVerificationEmail 生成邮箱验证令牌并发送邮件
+func (svc *Service) sendVerificationEmail(ctx context.Context, user *model.User) error {
+	token, err := genToken()
+	if err != nil {
+		return err
+	}
+	expire := svc.config.EmailVerifyExpire
+	if err := svc.repo.saveToken(ctx, tokenPurposeEmailVerify, user.ID, hashToken(token), expire); err != nil {
+		return err
+	}
+	return svc.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "验证邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n请在 %d 小时内访问以下链接完成邮箱验证：\n%s\n\n如非本人操作，请忽略本邮件。",
+			user.UserName, int(expire.Hours()), tokenLink(svc.config.EmailVerifyURL, token)),
+	})
+}
+
+// VerifyEmail 消费邮箱验证令牌并标记邮箱已验证
+func (svc *Service) VerifyEmail(ctx context.Context, token string) error {
+	userID, err := svc.repo.consumeToken(ctx, tokenPurposeEmailVerify, hashToken(token))
+	if err != nil {
+		if errors.Is(err, repoErrTokenNotFound) {
+			return errno.ErrUserVerifyTokenInvalid
+		}
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if err := svc.repo.MarkEmailVerified(ctx, userID, time.Now()); err != nil {
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	return nil
+}
+
+// ResendVerification 重新发送验证邮件，旧链接随即失效；两次发送间隔不小于 verify_resend_interval
+func (svc *Service) ResendVerification(ctx context.Context, userID uuid.UUID) error {
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrNotFoundRecord
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	if user.EmailVerifiedAt != nil {
+		return errno.ErrUserEmailAlreadyVerified
+	}
+
+	remaining, err := svc.repo.acquireVerifyResend(ctx, userID, svc.config.VerifyResendInterval)
+	if err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	if remaining > 0 {
+		return errno.ErrUserVerifyResendTooFrequent.WithData(VerifyResendData{
+			RetryAfterSeconds: int(math.Ceil(remaining.Seconds())),
+		})
+	}
+
+	if err := svc.sendVerificationEmail(ctx, user); err != nil {
+		return errno.ErrInternalServer.WithRaw(err)
+	}
+	return nil
+}
+
+// CheckEmailVerified 开启 require_email_verified 时要求用户已验证邮箱
+func (svc *Service) CheckEmailVerified(ctx context.Context, userID uuid.UUID) error {
+	if !svc.config.RequireEmailVerified {
+		return nil
+	}
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrNotFoundRecord
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		return errno.ErrUserEmailNotVerified
+	}
+	return nil
+}
+
+// GetProfile 查询个人资料及钱包余额、可用优惠券和未结束订单数量
+func (svc *Service) GetProfile(ctx context.Context, userID uuid.UUID) (*Profile, error) {
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrNotFoundRecord
+		}
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+
+	profile := &Profile{
+		ID:            user.ID,
+		UserName:      user.UserName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Role:          user.Role,
+		CreatedAt:     user.CreatedAt,
+	}
+	w, err := svc.walletRepo.GetWallet(ctx, userID)
+	if err == nil {
+		profile.Balance = w.Balance
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	if profile.UnusedCoupons, err = svc.couponRepo.CountUnusedUserCoupons(ctx, userID); err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	if profile.OpenOrders, err = svc.orderRepo.CountOpenOrders(ctx, userID); err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	return profile, nil
+}
+
+// UpdateProfile 修改用户名或邮箱；修改邮箱需校验密码，新邮箱需重新验证，未使用的密码重置链接随即失效
+func (svc *Service) UpdateProfile(ctx context.Context, userID uuid.UUID, input *UpdateProfileInput) (*Profile, error) {
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrNotFoundRecord
+		}
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+
+	var update ProfileUpdate
+	if input.UserName != nil && *input.UserName != user.UserName {
+		update.UserName = input.UserName
+	}
+	if input.Email != nil && *input.Email != user.Email {
+		if err := svc.verifyPassword(ctx, user, input.Password); err != nil {
+			return nil, err
+		}
+		update.Email = input.Email
+	}
+
+	if err := svc.repo.UpdateProfile(ctx, userID, update); err != nil {
+		if errors.Is(err, repoErrUserNameAlreadyExists) {
+			return nil, errno.ErrUserNameExisted
+		} else if errors.Is(err, repoErrEmailAlreadyExists) {
+			return nil, errno.ErrUserEmailExisted
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errno.ErrNotFoundRecord
+		}
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+
+	if update.Email != nil {
+		if err := svc.repo.deleteToken(ctx, tokenPurposePasswordReset, userID); err != nil {
+			return nil, errno.ErrInternalServer.WithRaw(err)
+		}
+		// 与注册相同，验证邮件发送失败不影响修改结果，用户可重发
+		user.Email = *update.Email
+		if update.UserName != nil {
+			user.UserName = *update.UserName
+		}
+		if err := svc.sendVerificationEmail(ctx, user); err != nil {
+			clog.L(ctx).Error("发送邮箱验证邮件失败", zap.String("user_id", user.ID.String()), zap.Error(err))
+		} else if err := svc.repo.resetVerifyResend(ctx, user.ID, svc.config.VerifyResendInterval); err != nil {
+			clog.L(ctx).Warn("设置验证邮件重发冷却失败", zap.String("user_id", user.ID.String()), zap.Error(err))
+		}
+	}
+	return svc.GetProfile(ctx, userID)
+}
+
+// ExportAccount 汇总用户的资料、订单、钱包流水、优惠券和收货地址
+func (svc *Service) ExportAccount(ctx context.Context, userID uuid.UUID) (*AccountData, error) {
+	profile, err := svc.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	data := &AccountData{Profile: profile}
+	if data.Orders, err = svc.orderRepo.ListAllOrdersByUserID(ctx, userID); err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	if data.WalletLogs, err = svc.walletRepo.ListAllWalletLogs(ctx, userID); err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	if data.Coupons, err = svc.couponRepo.ListAllUserCoupons(ctx, userID); err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	if data.Addresses, err = svc.addressRepo.ListAddresses(ctx, userID); err != nil {
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	return data, nil
+}
+
+// anonymizedIdentity 注销后替换用户名和邮箱的匿名值，由用户 ID 生成以保证唯一
+func anonymizedIdentity(userID uuid.UUID) (string, string) {
+	return "deleted_" + base64.RawURLEncoding.EncodeToString(userID[:]),
+		fmt.Sprintf("deleted-%s@deleted.invalid", userID)
+}
+
+// DeleteAccount 校验密码后注销账号；存在待支付或退款审核中的订单、钱包余额不为零时拒绝。订单、流水等记录保留，
+// 用户名和邮箱替换为匿名值后可被重新注册；注销全部会话由调用方负责
+func (svc *Service) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := svc.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrNotFoundRecord
+		}
+		return errno.ErrDatabase.WithRaw(err)
+	}
+	if err := svc.verifyPassword(ctx, user, password); err != nil {
+		return err
+	}
+
+	if err := database.ExecuteTransaction(ctx, svc.repo.GetDB(ctx), func(txCtx context.Context) error {
+		// 锁定钱包，避免检查余额后又有充值入账
+		w, err := svc.walletRepo.GetWalletForUpdate(txCtx, userID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		if err == nil && w.Balance != 0 {
+			return errno.ErrUserWalletNotEmpty
+		}
+		// 退款审核中的订单通过后会向钱包入账，同样需要等待结束
+		open, err := svc.orderRepo.CountOpenOrders(txCtx, userID)
+		if err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		if open > 0 {
+			return errno.ErrUserHasProcessingOrders
+		}
+
+		userName, email := anonymizedIdentity(userID)
+		if err := svc.repo.DeleteAccount(txCtx, userID, userName, email); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errno.ErrNotFoundRecord
+			}
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		// 订单中保留收货信息快照，地址簿随账号删除
+		if err := svc.addressRepo.DeleteUserAddresses(txCtx, userID); err != nil {
+			return errno.ErrDatabase.WithRaw(err)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, purpose := range []tokenPurpose{tokenPurposePasswordReset, tokenPurposeEmailVerify} {
+		if err := svc.repo.deleteToken(ctx, purpose, userID); err != nil {
+			clog.L(ctx).Warn("清理注销用户的一次性令牌失败", zap.String("user_id", userID.String()), zap.Error(err))
+		}
+	}
 	return nil
 }
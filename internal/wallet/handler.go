@@ -4,6 +4,8 @@ import (
 	"e-commerce/internal/app/identity"
 	"e-commerce/internal/pkg/response"
 	"e-commerce/pkg/errno"
+	"errors"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -18,6 +20,15 @@ type DepositDTO struct {
 	IdempotencyKey string  `json:"idempotency_key" binding:"required"`
 }
 
+type ListLogsDTO struct {
+	Type string `form:"type" binding:"omitempty,oneof=deposit payment refund"`
+	// StartDate/EndDate 按日期筛选（YYYY-MM-DD，含首尾两天，服务端时区）
+	StartDate string `form:"start_date" binding:"omitempty,datetime=2006-01-02"`
+	EndDate   string `form:"end_date" binding:"omitempty,datetime=2006-01-02"`
+	Cursor    string `form:"cursor" binding:"omitempty"`
+	Limit     int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
 func NewHandler(wallSvc *Service) *Handler {
 	return &Handler{wallSvc: wallSvc}
 }
@@ -54,3 +65,78 @@ func (h *Handler) Deposit(c *gin.Context) {
 
 	response.Write(c, nil, nil)
 }
+
+func (h *Handler) GetWallet(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	w, err := h.wallSvc.GetWallet(ctx, accountInfo.AccountId)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	response.Write(c, nil, FormatWallet(w))
+}
+
+// ListLogs 钱包流水，按时间倒序游标分页
+func (h *Handler) ListLogs(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var dto ListLogsDTO
+	if err := c.ShouldBindQuery(&dto); err != nil {
+		response.WriteInvalidParam(c, err)
+		return
+	}
+
+	accountInfo := identity.GetAccountInfo(ctx)
+	if accountInfo == nil {
+		response.Write(c, errno.ErrGetAccountInfo, nil)
+		return
+	}
+
+	input := &ListLogsInput{Type: dto.Type, Limit: dto.Limit}
+	if input.Limit == 0 {
+		input.Limit = 20
+	}
+	if dto.StartDate != "" {
+		input.Since, _ = time.ParseInLocation(time.DateOnly, dto.StartDate, time.Local)
+	}
+	if dto.EndDate != "" {
+		end, _ := time.ParseInLocation(time.DateOnly, dto.EndDate, time.Local)
+		input.Until = end.AddDate(0, 0, 1)
+	}
+	if !input.Since.IsZero() && !input.Until.IsZero() && !input.Until.After(input.Since) {
+		response.WriteInvalidParam(c, errors.New("end_date must not be before start_date"))
+		return
+	}
+	if dto.Cursor != "" {
+		cursor, err := uuid.Parse(dto.Cursor)
+		if err != nil {
+			response.WriteInvalidParam(c, err)
+			return
+		}
+		input.Cursor = cursor
+	}
+
+	logs, nextCursor, err := h.wallSvc.ListLogs(ctx, accountInfo.AccountId, input)
+	if err != nil {
+		response.Write(c, err, nil)
+		return
+	}
+
+	items := make([]WalletLogItem, 0, len(logs))
+	for _, l := range logs {
+		items = append(items, *FormatWalletLog(l))
+	}
+	resp := WalletLogListResponse{Logs: items}
+	if nextCursor != uuid.Nil {
+		resp.NextCursor = nextCursor.String()
+	}
+	response.Write(c, nil, resp)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"e-commerce/internal/model"
 	"e-commerce/internal/pkg/database"
+	"e-commerce/pkg/errno"
 	"errors"
 	"fmt"
 	"time"
@@ -17,11 +18,11 @@ import (
 )
 
 var (
-	repoErrDepositRecordAlreadyExists = errors.New("deposit record already exists")
+	ErrWalletLogAlreadyExists = errors.New("wallet log idempotency key already exists")
 )
 
 var constraintMap = map[string]error{
-	model.ConstraintWalletLogIdempotencyKey: repoErrDepositRecordAlreadyExists,
+	model.ConstraintWalletLogIdempotencyKey: ErrWalletLogAlreadyExists,
 }
 
 type Repository struct {
@@ -42,14 +43,77 @@ func (repo *Repository) CreateDefaultAccount(ctx context.Context, userID uuid.UU
 	return repo.GetDB(ctx).Create(record).Error
 }
 
-func (repo *Repository) Deposit(ctx context.Context, userID uuid.UUID, sessionID string, input *DepositInput) error {
-	log := &model.WalletLog{
-		UserID:         userID,
-		SessionID:      sessionID,
-		Amount:         input.Amount,
-		Type:           "deposit",
-		IdempotencyKey: input.IdempotencyKey,
+// GetWallet 查询用户钱包，不存在时返回 gorm.ErrRecordNotFound
+func (repo *Repository) GetWallet(ctx context.Context, userID uuid.UUID) (*model.UserWallet, error) {
+	var w model.UserWallet
+	result := repo.GetDB(ctx).Where("user_id = ?", userID).First(&w)
+	return &w, result.Error
+}
+
+// GetWalletForUpdate 查询用户钱包（带行锁，事务内使用）
+func (repo *Repository) GetWalletForUpdate(ctx context.Context, userID uuid.UUID) (*model.UserWallet, error) {
+	var w model.UserWallet
+	result := repo.GetDB(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		First(&w)
+	return &w, result.Error
+}
+
+// ListAllWalletLogs 按时间顺序返回用户的全部流水，用于导出个人数据
+func (repo *Repository) ListAllWalletLogs(ctx context.Context, userID uuid.UUID) ([]*model.WalletLog, error) {
+	var logs []*model.WalletLog
+	err := repo.GetDB(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC, id ASC").
+		Find(&logs).Error
+	return logs, err
+}
+
+type WalletLogFilter struct {
+	UserID uuid.UUID
+	Type   string
+	// Since/Until 为左闭右开的时间范围，零值表示不限
+	Since time.Time
+	Until time.Time
+	// Cursor 上一页最后一条流水，为空时从最新一条开始
+	Cursor *model.WalletLog
+	Limit  int
+}
+
+// GetUserWalletLog 查询用户的一条流水，不属于该用户时返回 gorm.ErrRecordNotFound
+func (repo *Repository) GetUserWalletLog(ctx context.Context, id, userID uuid.UUID) (*model.WalletLog, error) {
+	var l model.WalletLog
+	err := repo.GetDB(ctx).Where("id = ? AND user_id = ?", id, userID).First(&l).Error
+	return &l, err
+}
+
+// ListWalletLogs 按时间倒序分页查询流水，以 (created_at, id) 作为游标，翻页期间新增的流水不会导致重复或遗漏
+func (repo *Repository) ListWalletLogs(ctx context.Context, filter WalletLogFilter) ([]*model.WalletLog, error) {
+	db := repo.GetDB(ctx)
+	query := db.Where("user_id = ?", filter.UserID)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
 	}
+	if filter.Cursor != nil {
+		query = query.Where("(created_at, id) < (?, ?)", filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+
+	var logs []*model.WalletLog
+	err := query.
+		Order("created_at DESC, id DESC").
+		Limit(filter.Limit).
+		Find(&logs).Error
+	return logs, err
+}
+
+func (repo *Repository) createWalletLog(ctx context.Context, log *model.WalletLog) error {
 	if err := repo.GetDB(ctx).Create(log).Error; err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) && pgErr.SQLState() == pgerrcode.UniqueViolation {
@@ -59,16 +123,101 @@ func (repo *Repository) Deposit(ctx context.Context, userID uuid.UUID, sessionID
 		}
 		return fmt.Errorf("execute query error %w", err)
 	}
+	return nil
+}
 
-	return repo.GetDB(ctx).Clauses(clause.OnConflict{
+func (repo *Repository) Deposit(ctx context.Context, userID uuid.UUID, sessionID string, input *DepositInput) error {
+	return repo.Credit(ctx, CreditData{
+		UserID:         userID,
+		SessionID:      sessionID,
+		Amount:         input.Amount,
+		Type:           model.WalletLogTypeDeposit,
+		IdempotencyKey: input.IdempotencyKey,
+	})
+}
+
+type CreditData struct {
+	UserID uuid.UUID
+	// SessionID 钱包所有者本人操作时的会话；系统或他人（如卖家同意退款）触发的入账为空
+	SessionID      string
+	Amount         float64
+	Type           string
+	IdempotencyKey string
+}
+
+// Credit 增加余额并记录流水（钱包不存在时自动创建，事务内使用）
+func (repo *Repository) Credit(ctx context.Context, data CreditData) error {
+	log := &model.WalletLog{
+		UserID:         data.UserID,
+		SessionID:      data.SessionID,
+		Amount:         data.Amount,
+		Type:           data.Type,
+		IdempotencyKey: data.IdempotencyKey,
+	}
+	if err := repo.createWalletLog(ctx, log); err != nil {
+		return err
+	}
+
+	err := repo.GetDB(ctx).Clauses(clause.OnConflict{
 		Columns: []clause.Column{{Name: "user_id"}},
 		DoUpdates: clause.Assignments(map[string]interface{}{
-			"balance":    gorm.Expr("user_wallets.balance + ?", input.Amount),
+			"balance":    gorm.Expr("user_wallets.balance + ?", data.Amount),
 			"updated_at": time.Now(),
 		}),
 	}).Create(&model.UserWallet{
-		UserID:    userID,
-		Balance:   input.Amount,
+		UserID:    data.UserID,
+		Balance:   data.Amount,
 		UpdatedAt: time.Now(),
 	}).Error
+	if err != nil {
+		return err
+	}
+	return repo.fillBalanceAfter(ctx, log.ID, data.UserID)
+}
+
+type DebitData struct {
+	UserID         uuid.UUID
+	SessionID      string
+	Amount         float64
+	Type           string
+	IdempotencyKey string
+}
+
+// Debit 扣减余额并记录流水（余额不足时不扣减，事务内使用）
+func (repo *Repository) Debit(ctx context.Context, data DebitData) error {
+	log := &model.WalletLog{
+		UserID:         data.UserID,
+		SessionID:      data.SessionID,
+		Amount:         -data.Amount,
+		Type:           data.Type,
+		IdempotencyKey: data.IdempotencyKey,
+	}
+	if err := repo.createWalletLog(ctx, log); err != nil {
+		return err
+	}
+
+	result := repo.GetDB(ctx).Model(&model.UserWallet{}).
+		Where("user_id = ? AND balance >= ?", data.UserID, data.Amount).
+		Updates(map[string]interface{}{
+			"balance":    gorm.Expr("balance - ?", data.Amount),
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("execute query error %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return errno.ErrWalletInsufficientBalance
+	}
+	return repo.fillBalanceAfter(ctx, log.ID, data.UserID)
+}
+
+// fillBalanceAfter 在余额变更后记录流水的入账后余额；钱包行已被本事务的更新锁定，读到的即本条流水生效后的余额
+func (repo *Repository) fillBalanceAfter(ctx context.Context, logID, userID uuid.UUID) error {
+	err := repo.GetDB(ctx).Model(&model.WalletLog{}).
+		Where("id = ?", logID).
+		Update("balance_after", gorm.Expr("(SELECT balance FROM user_wallets WHERE user_id = ?)", userID)).Error
+	if err != nil {
+		return fmt.Errorf("execute query error %w", err)
+	}
+	return nil
 }
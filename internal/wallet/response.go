@@ -0,0 +1,45 @@
+package wallet
+
+import (
+	"e-commerce/internal/model"
+	"time"
+)
+
+type WalletResponse struct {
+	Balance   float64 `json:"balance"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type WalletLogItem struct {
+	ID     string  `json:"id"`
+	Amount float64 `json:"amount"`
+	// BalanceAfter 本条流水入账后的余额
+	BalanceAfter float64 `json:"balance_after"`
+	Type         string  `json:"type"`
+	CreatedAt    string  `json:"created_at"`
+}
+
+type WalletLogListResponse struct {
+	Logs []WalletLogItem `json:"logs"`
+	// NextCursor 传给下一次请求的 cursor，为空表示没有更多
+	NextCursor string `json:"next_cursor"`
+}
+
+func FormatWalletLog(l *model.WalletLog) *WalletLogItem {
+	return &WalletLogItem{
+		ID:           l.ID.String(),
+		Amount:       l.Amount,
+		BalanceAfter: l.BalanceAfter,
+		Type:         l.Type,
+		CreatedAt:    l.CreatedAt.Format(time.DateTime),
+	}
+}
+
+// FormatWallet 从未入账的钱包 UpdatedAt 为零值，返回空字符串
+func FormatWallet(w *model.UserWallet) *WalletResponse {
+	resp := &WalletResponse{Balance: w.Balance}
+	if !w.UpdatedAt.IsZero() {
+		resp.UpdatedAt = w.UpdatedAt.Format(time.DateTime)
+	}
+	return resp
+}
@@ -2,13 +2,16 @@ package wallet
 
 import (
 	"context"
+	"e-commerce/internal/model"
 	"e-commerce/internal/pkg/database"
 	"e-commerce/pkg/clog"
 	"e-commerce/pkg/errno"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type Service struct {
@@ -37,8 +40,60 @@ func (svc *Service) Deposit(ctx context.Context, UserID uuid.UUID, SessionID str
 	err := database.ExecuteTransaction(ctx, svc.walletRepo.GetDB(ctx), func(txCtx context.Context) error {
 		return svc.walletRepo.Deposit(txCtx, UserID, SessionID, input)
 	})
-	if errors.Is(err, repoErrDepositRecordAlreadyExists) {
+	if errors.Is(err, ErrWalletLogAlreadyExists) {
 		return nil
 	}
 	return err
 }
+
+// GetWallet 查询钱包余额，尚未开户（从未入账）时视为余额为零
+func (svc *Service) GetWallet(ctx context.Context, userID uuid.UUID) (*model.UserWallet, error) {
+	w, err := svc.walletRepo.GetWallet(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &model.UserWallet{UserID: userID}, nil
+		}
+		return nil, errno.ErrDatabase.WithRaw(err)
+	}
+	return w, nil
+}
+
+type ListLogsInput struct {
+	Type   string
+	Since  time.Time
+	Until  time.Time
+	Cursor uuid.UUID
+	Limit  int
+}
+
+// ListLogs 分页查询流水，多取一条判断是否还有下一页；没有下一页时 nextCursor 为 uuid.Nil。
+// cursor 不存在或不属于该用户时返回参数错误，避免客户端误以为已到末页
+func (svc *Service) ListLogs(ctx context.Context, userID uuid.UUID, input *ListLogsInput) (logs []*model.WalletLog, nextCursor uuid.UUID, err error) {
+	filter := WalletLogFilter{
+		UserID: userID,
+		Type:   input.Type,
+		Since:  input.Since,
+		Until:  input.Until,
+		Limit:  input.Limit + 1,
+	}
+	if input.Cursor != uuid.Nil {
+		cursor, err := svc.walletRepo.GetUserWalletLog(ctx, input.Cursor, userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, uuid.Nil, errno.ErrInvalidParam.WithRaw(errors.New("invalid cursor"))
+			}
+			return nil, uuid.Nil, errno.ErrDatabase.WithRaw(err)
+		}
+		filter.Cursor = cursor
+	}
+
+	logs, err = svc.walletRepo.ListWalletLogs(ctx, filter)
+	if err != nil {
+		return nil, uuid.Nil, errno.ErrDatabase.WithRaw(err)
+	}
+	if len(logs) > input.Limit {
+		logs = logs[:input.Limit]
+		nextCursor = logs[len(logs)-1].ID
+	}
+	return logs, nextCursor, nil
+}
@@ -0,0 +1,28 @@
+-- 订单明细：一个订单可包含多个商品
+CREATE TABLE IF NOT EXISTS order_items (
+    id             UUID PRIMARY KEY,
+    order_id       UUID          NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    product_id     UUID          NOT NULL,
+    quantity       BIGINT        NOT NULL CHECK (quantity > 0),
+    snapshot_title VARCHAR(255)  NOT NULL,
+    snapshot_price DECIMAL(16,2) NOT NULL,
+    created_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order_id ON order_items(order_id);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS total_amount DECIMAL(16,2) NOT NULL DEFAULT 0;
+
+-- 历史单商品订单迁移为一条明细
+INSERT INTO order_items (id, order_id, product_id, quantity, snapshot_title, snapshot_price, created_at)
+SELECT gen_random_uuid(), id, product_id, quantity, snapshot_title, snapshot_price, created_at
+FROM orders
+WHERE product_id IS NOT NULL;
+
+UPDATE orders SET total_amount = snapshot_price * quantity WHERE product_id IS NOT NULL;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS product_id,
+    DROP COLUMN IF EXISTS quantity,
+    DROP COLUMN IF EXISTS snapshot_title,
+    DROP COLUMN IF EXISTS snapshot_price;
@@ -0,0 +1,17 @@
+-- 两阶段库存：下单冻结、支付扣减冻结、取消/超时释放冻结
+ALTER TABLE IF EXISTS stock_change_logs ADD COLUMN IF NOT EXISTS frozen_quantity BIGINT NOT NULL DEFAULT 0;
+
+-- 历史待支付订单（status = 0）下单时已直接扣减库存，补齐其冻结库存，之后支付/取消/超时才能扣减或释放
+UPDATE products p
+SET frozen_stock = p.frozen_stock + s.qty
+FROM (
+    SELECT oi.product_id, SUM(oi.quantity) AS qty
+    FROM order_items oi
+    JOIN orders o ON o.id = oi.order_id
+    WHERE o.status = 0
+    GROUP BY oi.product_id
+) s
+WHERE p.id = s.product_id;
+
+COMMENT ON COLUMN products.stock IS '可售库存';
+COMMENT ON COLUMN products.frozen_stock IS '已下单未支付的冻结库存';
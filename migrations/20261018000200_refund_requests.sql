@@ -0,0 +1,25 @@
+-- 退款申请：买家按订单明细发起，商品发布者审核
+CREATE TABLE IF NOT EXISTS refund_requests (
+    id            UUID PRIMARY KEY,
+    order_id      UUID          NOT NULL,
+    order_item_id UUID          NOT NULL,
+    user_id       UUID          NOT NULL,
+    product_id    UUID          NOT NULL,
+    publisher     UUID          NOT NULL,
+    quantity      BIGINT        NOT NULL CHECK (quantity > 0),
+    amount        DECIMAL(16,2) NOT NULL DEFAULT 0,
+    reason        VARCHAR(512)  NOT NULL,
+    status        VARCHAR(16)   NOT NULL DEFAULT 'pending',
+    review_note   VARCHAR(512)  NOT NULL DEFAULT '',
+    reviewed_at   TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ,
+    updated_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_refund_requests_order_id ON refund_requests(order_id);
+CREATE INDEX IF NOT EXISTS idx_refund_requests_order_item_id ON refund_requests(order_item_id);
+CREATE INDEX IF NOT EXISTS idx_refund_requests_user_id ON refund_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_refund_requests_publisher ON refund_requests(publisher);
+
+ALTER TABLE order_items ADD COLUMN IF NOT EXISTS refunded_quantity BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS refunded_amount DECIMAL(16,2) NOT NULL DEFAULT 0;
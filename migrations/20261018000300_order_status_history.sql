@@ -0,0 +1,13 @@
+-- 订单状态流转记录：每次状态变更记录操作者（买家/商品发布者/系统）与备注
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id          UUID PRIMARY KEY,
+    order_id    UUID         NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    from_status SMALLINT,
+    to_status   SMALLINT     NOT NULL,
+    actor_type  VARCHAR(16)  NOT NULL,
+    actor_id    UUID,
+    note        VARCHAR(255) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id);
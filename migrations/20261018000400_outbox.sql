@@ -0,0 +1,15 @@
+-- 事务性发件箱：订单超时消息与订单在同一事务内写入，由 relay 轮询投递到 RabbitMQ
+CREATE TABLE IF NOT EXISTS outbox (
+    id              UUID PRIMARY KEY,
+    exchange        VARCHAR(128) NOT NULL DEFAULT '',
+    routing_key     VARCHAR(128) NOT NULL,
+    body            TEXT         NOT NULL,
+    status          VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    attempts        INTEGER      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ  NOT NULL,
+    last_error      TEXT         NOT NULL DEFAULT '',
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_status_next_attempt ON outbox(status, next_attempt_at);
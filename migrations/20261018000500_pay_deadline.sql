@@ -0,0 +1,9 @@
+-- 支付时限：商品可单独配置，订单记录支付截止时间，超时消息按截止时间经发件箱调度（不再使用整队列 TTL 的延迟队列）
+ALTER TABLE products ADD COLUMN IF NOT EXISTS pay_timeout_seconds INTEGER NOT NULL DEFAULT 0;
+COMMENT ON COLUMN products.pay_timeout_seconds IS '支付时限（秒），0 表示使用全局配置';
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS pay_deadline TIMESTAMPTZ;
+-- 存量订单按原延迟队列 TTL（30 秒）回填，仍在旧延迟队列中的超时消息到达时可正常关单
+UPDATE orders SET pay_deadline = created_at + INTERVAL '30 seconds' WHERE pay_deadline IS NULL;
+ALTER TABLE orders ALTER COLUMN pay_deadline SET NOT NULL;
+COMMENT ON COLUMN orders.pay_deadline IS '支付截止时间，超过后自动关单';
@@ -0,0 +1,3 @@
+-- 用户角色：admin 管理平台，seller 发布和管理商品，buyer 为默认角色
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'buyer';
+COMMENT ON COLUMN users.role IS '用户角色：admin/seller/buyer';
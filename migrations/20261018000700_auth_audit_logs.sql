@@ -0,0 +1,12 @@
+-- 认证审计日志：记录刷新令牌重用等安全事件
+CREATE TABLE IF NOT EXISTS auth_audit_logs (
+    id         UUID PRIMARY KEY,
+    user_id    UUID         NOT NULL,
+    session_id VARCHAR(64)  NOT NULL DEFAULT '',
+    event      VARCHAR(32)  NOT NULL,
+    ip         VARCHAR(64)  NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_audit_user_created ON auth_audit_logs(user_id, created_at);
@@ -0,0 +1,5 @@
+-- 邮箱验证时间，为空表示尚未验证
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+COMMENT ON COLUMN users.email_verified_at IS '邮箱验证时间，为空表示尚未验证';
+-- 功能上线前注册的用户视为已验证，开启 require_email_verified 后不影响存量用户
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
@@ -0,0 +1,20 @@
+-- TOTP 两步验证：enabled_at 为空表示尚未确认绑定，last_used_step 防止验证码重放
+CREATE TABLE IF NOT EXISTS user_totps (
+    user_id        UUID PRIMARY KEY,
+    secret         VARCHAR(64) NOT NULL,
+    enabled_at     TIMESTAMPTZ,
+    last_used_step BIGINT      NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ,
+    updated_at     TIMESTAMPTZ
+);
+
+-- 两步验证恢复码，只保存 SHA-256 摘要
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id         UUID PRIMARY KEY,
+    user_id    UUID        NOT NULL,
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_code_user_hash ON user_recovery_codes(user_id, code_hash);
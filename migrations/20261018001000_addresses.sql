@@ -0,0 +1,27 @@
+-- 收货地址簿，每个用户最多一个默认地址
+CREATE TABLE IF NOT EXISTS addresses (
+    id         UUID PRIMARY KEY,
+    user_id    UUID         NOT NULL,
+    receiver   VARCHAR(64)  NOT NULL DEFAULT '',
+    phone      VARCHAR(32)  NOT NULL DEFAULT '',
+    province   VARCHAR(64)  NOT NULL DEFAULT '',
+    city       VARCHAR(64)  NOT NULL DEFAULT '',
+    district   VARCHAR(64)  NOT NULL DEFAULT '',
+    detail     VARCHAR(255) NOT NULL DEFAULT '',
+    is_default BOOLEAN      NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_addresses_user_id ON addresses(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS uni_address_user_default ON addresses(user_id) WHERE is_default;
+
+-- 订单收货信息快照，历史订单为空
+ALTER TABLE orders
+    ADD COLUMN IF NOT EXISTS address_id        UUID,
+    ADD COLUMN IF NOT EXISTS snapshot_receiver VARCHAR(64)  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS snapshot_phone    VARCHAR(32)  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS snapshot_province VARCHAR(64)  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS snapshot_city     VARCHAR(64)  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS snapshot_district VARCHAR(64)  NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS snapshot_detail   VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,19 @@
+-- 流水记录入账后的余额，用于钱包明细展示
+ALTER TABLE wallet_logs ADD COLUMN IF NOT EXISTS balance_after DECIMAL(16,2) NOT NULL DEFAULT 0;
+
+-- 历史流水以当前余额为基准倒推：每条的余额 = 当前余额 - 之后所有流水金额之和
+UPDATE wallet_logs wl
+SET balance_after = t.balance_after
+FROM (
+    SELECT l.id,
+           w.balance - COALESCE(SUM(l.amount) OVER (
+               PARTITION BY l.user_id
+               ORDER BY l.created_at DESC, l.id DESC
+               ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+           ), 0) AS balance_after
+    FROM wallet_logs l
+    JOIN user_wallets w ON w.user_id = l.user_id
+) t
+WHERE wl.id = t.id;
+
+CREATE INDEX IF NOT EXISTS idx_wallet_logs_user_created ON wallet_logs(user_id, created_at DESC);
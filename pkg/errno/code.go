@@ -9,13 +9,50 @@ var (
 	ErrUserNameExisted  = &Errno{Type: "A", Domain: "01", Code: "102", Message: "用户名已存在"}
 	ErrUserEmailExisted = &Errno{Type: "A", Domain: "01", Code: "102", Message: "邮箱已被注册"}
 	ErrUserNotFound     = &Errno{Type: "A", Domain: "01", Code: "103", Message: "账号或密码错误"}
+	// ErrUserPasswordIncorrect 修改密码时原密码校验失败
+	ErrUserPasswordIncorrect = &Errno{Type: "A", Domain: "01", Code: "104", Message: "原密码错误"}
+	// ErrUserPasswordUnchanged 新密码与原密码相同
+	ErrUserPasswordUnchanged = &Errno{Type: "A", Domain: "01", Code: "105", Message: "新密码不能与原密码相同"}
+	// ErrUserResetTokenInvalid 密码重置令牌不存在、已使用或已过期
+	ErrUserResetTokenInvalid = &Errno{Type: "A", Domain: "01", Code: "106", Message: "重置链接无效或已过期"}
+	// ErrUserVerifyTokenInvalid 邮箱验证令牌不存在、已使用或已过期
+	ErrUserVerifyTokenInvalid = &Errno{Type: "A", Domain: "01", Code: "107", Message: "验证链接无效或已过期"}
+	// ErrUserEmailNotVerified 开启 require_email_verified 后，未验证邮箱的用户下单或充值
+	ErrUserEmailNotVerified = &Errno{Type: "A", Domain: "01", Code: "108", Message: "请先完成邮箱验证"}
+	// ErrUserEmailAlreadyVerified 邮箱已验证，无需重发验证邮件
+	ErrUserEmailAlreadyVerified = &Errno{Type: "A", Domain: "01", Code: "109", Message: "邮箱已验证"}
+	// ErrUserVerifyResendTooFrequent 重发验证邮件过于频繁，data 中返回可重试时间
+	ErrUserVerifyResendTooFrequent = &Errno{Type: "A", Domain: "01", Code: "110", Message: "发送过于频繁，请稍后重试"}
+	// ErrUserHasProcessingOrders 注销账号时仍有待支付或退款审核中的订单
+	ErrUserHasProcessingOrders = &Errno{Type: "A", Domain: "01", Code: "111", Message: "存在未完成的订单，无法注销账号"}
+	// ErrUserWalletNotEmpty 注销账号时钱包余额不为零
+	ErrUserWalletNotEmpty = &Errno{Type: "A", Domain: "01", Code: "112", Message: "钱包余额不为零，无法注销账号"}
 
 	ErrAuthNotPermission  = &Errno{Type: "A", Domain: "02", Code: "100", Message: "无权限访问"}
 	ErrAuthTokenExpired   = &Errno{Type: "A", Domain: "02", Code: "101", Message: "令牌已过期"}
 	ErrAuthSessionRevoked = &Errno{Type: "A", Domain: "02", Code: "102", Message: "账号已在别处登录"}
 	ErrAuthInvalidToken   = &Errno{Type: "A", Domain: "02", Code: "103", Message: "非法访问"}
+	// ErrAuthSessionNotFound 会话不存在或不属于当前用户
+	ErrAuthSessionNotFound = &Errno{Type: "A", Domain: "02", Code: "104", Message: "登录设备不存在"}
+	// ErrAuthSessionLimit 同时在线设备数已达上限（session_limit_policy=reject）
+	ErrAuthSessionLimit = &Errno{Type: "A", Domain: "02", Code: "105", Message: "登录设备数已达上限，请先退出其他设备"}
+	// ErrAuthRefreshTokenReused 已轮换的刷新令牌被再次使用，会话已被注销
+	ErrAuthRefreshTokenReused = &Errno{Type: "A", Domain: "02", Code: "106", Message: "登录凭证已失效，请重新登录"}
+	// ErrAuthLoginLocked 登录失败次数过多被临时锁定，data 中返回可重试时间
+	ErrAuthLoginLocked = &Errno{Type: "A", Domain: "02", Code: "107", Message: "登录失败次数过多，请稍后重试"}
+	// ErrAuthMFACodeInvalid 两步验证码或恢复码错误
+	ErrAuthMFACodeInvalid = &Errno{Type: "A", Domain: "02", Code: "108", Message: "验证码错误"}
+	// ErrAuthMFATokenInvalid mfa_token 已过期、已使用或错误次数过多
+	ErrAuthMFATokenInvalid = &Errno{Type: "A", Domain: "02", Code: "109", Message: "验证已失效，请重新登录"}
+	// ErrAuthMFAAlreadyEnabled 已开启两步验证，需先关闭才能重新绑定
+	ErrAuthMFAAlreadyEnabled = &Errno{Type: "A", Domain: "02", Code: "110", Message: "已开启两步验证"}
+	// ErrAuthMFANotEnrolled 未绑定验证器或尚未开启两步验证
+	ErrAuthMFANotEnrolled = &Errno{Type: "A", Domain: "02", Code: "111", Message: "尚未开启两步验证"}
+	// ErrAuthMFARequired 当前角色须通过两步验证登录才能执行该操作
+	ErrAuthMFARequired = &Errno{Type: "A", Domain: "02", Code: "112", Message: "该操作需要开启两步验证并重新登录"}
 
 	ErrWalletInvalidDepositAmount = &Errno{Type: "A", Domain: "03", Code: "101", Message: "充值金额非法"}
+	ErrWalletInsufficientBalance  = &Errno{Type: "A", Domain: "03", Code: "102", Message: "钱包余额不足"}
 
 	ErrProductStockInsufficient = &Errno{Type: "A", Domain: "04", Code: "101", Message: "库存不足"}
 	ErrProductNotFound          = &Errno{Type: "A", Domain: "04", Code: "102", Message: "商品不存在"}
@@ -23,10 +60,31 @@ var (
 
 	// ErrOrderProductIdNotFound 下单时输入的商品 ID 在系统中无法找到
 	ErrOrderProductIdNotFound = &Errno{Type: "A", Domain: "05", Code: "100", Message: "商品ID不存在"}
+	// ErrOrderIdempotencyKeyConflict 幂等键已被其他用户的订单占用
+	ErrOrderIdempotencyKeyConflict = &Errno{Type: "A", Domain: "05", Code: "101", Message: "幂等键冲突"}
+	ErrOrderNotFound               = &Errno{Type: "A", Domain: "05", Code: "102", Message: "订单不存在"}
+	// ErrOrderStatusInvalid 订单当前状态不允许该操作（如已超时的订单发起支付）
+	ErrOrderStatusInvalid = &Errno{Type: "A", Domain: "05", Code: "103", Message: "订单状态不允许该操作"}
+	// ErrOrderPayExpired 订单已超过支付期限（超时关单处理前）
+	ErrOrderPayExpired = &Errno{Type: "A", Domain: "05", Code: "104", Message: "订单已超过支付期限"}
+
+	ErrCartEmpty              = &Errno{Type: "A", Domain: "06", Code: "101", Message: "购物车中没有选中的商品"}
+	ErrCartItemNotFound       = &Errno{Type: "A", Domain: "06", Code: "102", Message: "购物车中不存在该商品"}
+	ErrCartProductUnavailable = &Errno{Type: "A", Domain: "06", Code: "103", Message: "商品已下架或库存不足"}
+	ErrCartFull               = &Errno{Type: "A", Domain: "06", Code: "104", Message: "购物车商品种类已达上限"}
+
+	ErrRefundNotFound          = &Errno{Type: "A", Domain: "07", Code: "101", Message: "退款申请不存在"}
+	ErrRefundQuantityExceeded  = &Errno{Type: "A", Domain: "07", Code: "102", Message: "退款数量超过可退数量"}
+	ErrRefundStatusInvalid     = &Errno{Type: "A", Domain: "07", Code: "103", Message: "退款申请已处理"}
+	ErrRefundOrderItemNotFound = &Errno{Type: "A", Domain: "07", Code: "104", Message: "订单中不存在该商品"}
+
+	ErrAddressNotFound = &Errno{Type: "A", Domain: "08", Code: "101", Message: "收货地址不存在"}
+	ErrAddressFull     = &Errno{Type: "A", Domain: "08", Code: "102", Message: "收货地址数量已达上限"}
 
 	ErrInternalServer = &Errno{Type: "B", Domain: "01", Code: "001", Message: "系统繁忙，请稍后重试"}
 	ErrDatabase       = &Errno{Type: "B", Domain: "01", Code: "002", Message: "数据库操作异常"}
 	ErrGetAccountInfo = &Errno{Type: "B", Domain: "01", Code: "003", Message: "无法获取accountInfo信息"}
 
-	ErrRedisDown = &Errno{Type: "C", Domain: "03", Code: "001", Message: "缓存服务暂时不可用"}
+	ErrRedisDown     = &Errno{Type: "C", Domain: "03", Code: "001", Message: "缓存服务暂时不可用"}
+	ErrMQUnavailable = &Errno{Type: "C", Domain: "04", Code: "001", Message: "消息服务暂时不可用"}
 )
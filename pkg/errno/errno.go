@@ -8,6 +8,8 @@ type Errno struct {
 	Code    string
 	Message string
 	RawErr  error
+	// Data 随错误一起返回给客户端的附加信息（如可重试时间），为空时响应 data 为 null
+	Data interface{}
 }
 
 func (e *Errno) FullCode() string {
@@ -28,5 +30,17 @@ func (e *Errno) WithRaw(err error) *Errno {
 		Code:    e.Code,
 		Message: e.Message,
 		RawErr:  err,
+		Data:    e.Data,
+	}
+}
+
+func (e *Errno) WithData(data interface{}) *Errno {
+	return &Errno{
+		Type:    e.Type,
+		Domain:  e.Domain,
+		Code:    e.Code,
+		Message: e.Message,
+		RawErr:  e.RawErr,
+		Data:    data,
 	}
 }
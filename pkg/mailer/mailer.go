@@ -0,0 +1,105 @@
+package mailer
+
+import (
+	"context"
+	"e-commerce/pkg/clog"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// DriverLog 邮件内容只写入日志，用于本地开发
+	DriverLog = "log"
+	// DriverFile 邮件以 JSON Lines 追加写入文件，便于测试读取
+	DriverFile = "file"
+)
+
+type Config struct {
+	Driver   string
+	From     string
+	FilePath string
+}
+
+type Message struct {
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Mailer 邮件发送接口，接入真实的邮件服务时实现该接口即可
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+func New(config Config) (Mailer, error) {
+	switch config.Driver {
+	case DriverLog, "":
+		return &LogMailer{from: config.From}, nil
+	case DriverFile:
+		if config.FilePath == "" {
+			return nil, fmt.Errorf("mailer file_path required for driver %q", DriverFile)
+		}
+		return &FileMailer{from: config.From, path: config.FilePath}, nil
+	default:
+		return nil, fmt.Errorf("unsupported mailer driver %q", config.Driver)
+	}
+}
+
+// LogMailer 将邮件写入日志，不实际发送
+type LogMailer struct {
+	from string
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	clog.L(ctx).Info("发送邮件",
+		zap.String("from", msg.From),
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	return nil
+}
+
+// FileMailer 每封邮件以一行 JSON 追加写入文件
+type FileMailer struct {
+	from string
+	path string
+	mu   sync.Mutex
+}
+
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	msg.SentAt = time.Now()
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal mail error: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("create mail dir error: %w", err)
+	}
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open mail file error: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write mail file error: %w", err)
+	}
+	return nil
+}
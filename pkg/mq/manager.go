@@ -0,0 +1,225 @@
+package mq
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.uber.org/zap"
+)
+
+const (
+	// 重连退避：reconnectBaseDelay 起步，每次失败翻倍，最多 reconnectMaxDelay
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = 30 * time.Second
+)
+
+// SetupFunc 在新开启的 Channel 上声明拓扑、注册消费者或绑定发布者；首次注册及每次重连后都会被调用
+type SetupFunc func(ch *amqp.Channel) error
+
+type registration struct {
+	name  string
+	setup SetupFunc
+	ch    *amqp.Channel
+}
+
+type channelClosed struct {
+	reg *registration
+	ch  *amqp.Channel
+}
+
+// Manager RabbitMQ 连接管理器：监听连接/通道关闭，按退避重连，并按注册顺序重新执行各 SetupFunc
+type Manager struct {
+	url    string
+	logger Logger
+
+	mu   sync.RWMutex
+	conn *amqp.Connection
+	regs []*registration
+
+	chClosed  chan channelClosed
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newManager(url string, logger Logger) *Manager {
+	return &Manager{
+		url:      url,
+		logger:   logger,
+		chClosed: make(chan channelClosed),
+		done:     make(chan struct{}),
+	}
+}
+
+// Register 为 setup 开启独立 Channel 并立即执行；连接或通道断开恢复后会自动重新执行。
+// 注册顺序即重连后的执行顺序，拓扑声明应先于消费者/发布者注册。
+func (m *Manager) Register(name string, setup SetupFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reg := &registration{name: name, setup: setup}
+	if m.conn != nil && !m.conn.IsClosed() {
+		if err := m.openLocked(m.conn, reg); err != nil {
+			return err
+		}
+	}
+	m.regs = append(m.regs, reg)
+	return nil
+}
+
+// IsReady 连接及所有已注册的 Channel 均可用
+func (m *Manager) IsReady() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.conn == nil || m.conn.IsClosed() {
+		return false
+	}
+	for _, reg := range m.regs {
+		if reg.ch == nil || reg.ch.IsClosed() {
+			return false
+		}
+	}
+	return true
+}
+
+// Close 停止重连并关闭连接
+func (m *Manager) Close() {
+	m.closeOnce.Do(func() {
+		close(m.done)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, reg := range m.regs {
+			if reg.ch != nil && !reg.ch.IsClosed() {
+				_ = reg.ch.Close()
+			}
+		}
+		if m.conn != nil && !m.conn.IsClosed() {
+			_ = m.conn.Close()
+		}
+	})
+}
+
+// connect 建立新连接，并为所有已注册的 setup 重新开启 Channel
+func (m *Manager) connect() error {
+	conn, err := amqp.Dial(m.url)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, reg := range m.regs {
+		if err := m.openLocked(conn, reg); err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+	m.conn = conn
+	return nil
+}
+
+// openLocked 开启 Channel 并执行 setup，随后监听该 Channel 的异常关闭（需持有 m.mu）
+func (m *Manager) openLocked(conn *amqp.Connection, reg *registration) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("开启 Channel 失败 %s: %w", reg.name, err)
+	}
+	if err := reg.setup(ch); err != nil {
+		_ = ch.Close()
+		return fmt.Errorf("初始化 %s 失败: %w", reg.name, err)
+	}
+	reg.ch = ch
+
+	notify := ch.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		// 主动关闭时 notify 直接被关闭，不触发恢复
+		if amqpErr, ok := <-notify; ok && amqpErr != nil {
+			select {
+			case m.chClosed <- channelClosed{reg: reg, ch: ch}:
+			case <-m.done:
+			}
+		}
+	}()
+	return nil
+}
+
+// supervise 每个连接只注册一次 NotifyClose，连接断开并重连成功后再为新连接注册
+func (m *Manager) supervise() {
+	for {
+		m.mu.RLock()
+		conn := m.conn
+		m.mu.RUnlock()
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+
+		if !m.watch(conn, connClosed) {
+			return
+		}
+		if !m.reconnect() {
+			return
+		}
+	}
+}
+
+// watch 处理 conn 上的 Channel 关闭事件，直到连接断开（返回 true，需重连）或 Close（返回 false）
+func (m *Manager) watch(conn *amqp.Connection, connClosed <-chan *amqp.Error) bool {
+	for {
+		select {
+		case <-m.done:
+			return false
+		case amqpErr := <-connClosed:
+			select {
+			case <-m.done:
+				return false
+			default:
+			}
+			m.logger.Warn("RabbitMQ 连接断开，开始重连", zap.Any("reason", amqpErr))
+			return true
+		case ev := <-m.chClosed:
+			m.reopen(conn, ev)
+		}
+	}
+}
+
+// reconnect 按指数退避重连直到成功，Close 后返回 false
+func (m *Manager) reconnect() bool {
+	delay := reconnectBaseDelay
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-m.done:
+			return false
+		case <-time.After(delay):
+		}
+
+		err := m.connect()
+		if err == nil {
+			m.logger.Info("RabbitMQ 重连成功", zap.Int("attempt", attempt))
+			return true
+		}
+		m.logger.Error("RabbitMQ 重连失败", zap.Int("attempt", attempt), zap.Duration("next_delay", delay), zap.Error(err))
+
+		delay *= 2
+		if delay > reconnectMaxDelay {
+			delay = reconnectMaxDelay
+		}
+	}
+}
+
+// reopen 连接仍可用而单个 Channel 异常关闭时，仅重建该 Channel；失败则关闭连接触发整体重连
+func (m *Manager) reopen(conn *amqp.Connection, ev channelClosed) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 连接已断开由整体重连处理；Channel 已被替换说明是过期事件
+	if conn.IsClosed() || ev.reg.ch != ev.ch {
+		return
+	}
+	m.logger.Warn("RabbitMQ Channel 异常关闭，重新开启", zap.String("name", ev.reg.name))
+	if err := m.openLocked(conn, ev.reg); err != nil {
+		m.logger.Error("重新开启 Channel 失败，关闭连接触发重连", zap.String("name", ev.reg.name), zap.Error(err))
+		_ = conn.Close()
+	}
+}
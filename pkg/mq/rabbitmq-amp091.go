@@ -4,12 +4,12 @@ import (
 	"context"
 	"fmt"
 
-	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
 )
 
 type Logger interface {
 	Info(msg string, fields ...zap.Field)
+	Warn(msg string, fields ...zap.Field)
 	Error(msg string, fields ...zap.Field)
 }
 
@@ -20,32 +20,21 @@ type Config struct {
 	Port     int
 }
 
+// InitMq 建立 RabbitMQ 连接并启动断线重连监控，返回连接管理器与清理函数
 func InitMq(
 	ctx context.Context,
 	logger Logger,
 	config Config,
-) (*amqp.Channel, func(), error) {
-	conn, err := amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:%d", config.User, config.Password, config.Host, config.Port))
-	if err != nil {
+) (*Manager, func(), error) {
+	m := newManager(fmt.Sprintf("amqp://%s:%s@%s:%d", config.User, config.Password, config.Host, config.Port), logger)
+	if err := m.connect(); err != nil {
 		return nil, nil, fmt.Errorf("连接 RabbitMQ 失败: %w", err)
 	}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, nil, fmt.Errorf("开启 Channel 失败: %w", err)
-	}
-
 	logger.Info("连接rabbit-mq成功")
 
-	cleanup := func() {
-		if !ch.IsClosed() {
-			_ = ch.Close()
-		}
-		if !conn.IsClosed() {
-			_ = conn.Close()
-		}
-	}
+	// 监控不绑定 ctx 生命周期，由 cleanup 显式关闭
+	go m.supervise()
 
-	return ch, cleanup, nil
+	return m, m.Close, nil
 }
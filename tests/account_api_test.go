@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("AccountApi", Ordered, func() {
+	const (
+		email    = "account-flow@test.com"
+		userName = "account-flow"
+		// 独立 IP，避免与其他用例共享登录限流
+		clientIP = "198.51.100.22"
+	)
+	var (
+		userID, newUserID uuid.UUID
+		orderID           uuid.UUID
+		accessToken       string
+	)
+
+	deleteAccount := func(password string) Response {
+		_, resp := doJSONRequest(clientIP, http.MethodDelete, "/api/v1/user/me", accessToken, map[string]string{"password": password})
+		return resp
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, email_verified_at, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW(), NOW())`,
+			userID, userName, email, string(pwHash))
+		testDB.Exec(`INSERT INTO user_wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())`, userID, 20.0)
+		Expect(testDB.Create(&model.WalletLog{
+			UserID: userID, SessionID: "seed", Amount: 20, Type: model.WalletLogTypeDeposit, IdempotencyKey: uuid.NewString(),
+		}).Error).ToNot(HaveOccurred())
+		o := &model.Order{
+			UserID:         userID,
+			TotalAmount:    10,
+			Status:         model.OrderStatusProcessing,
+			IdempotencyKey: uuid.NewString(),
+			PayDeadline:    time.Now().Add(time.Hour),
+		}
+		Expect(testDB.Create(o).Error).ToNot(HaveOccurred())
+		orderID = o.ID
+
+		accessToken = loginAs(clientIP, email, "password123").AccessToken
+	})
+
+	AfterAll(func() {
+		ctx := context.Background()
+		for _, id := range []uuid.UUID{userID, newUserID} {
+			testRedis.Del(ctx,
+				fmt.Sprintf("ident:u_sessions:%s", id),
+				fmt.Sprintf("user:email_verify_user:%s", id),
+				fmt.Sprintf("user:email_verify_cooldown:%s", id),
+			)
+			testDB.Exec("DELETE FROM orders WHERE user_id = ?", id)
+			testDB.Exec("DELETE FROM wallet_logs WHERE user_id = ?", id)
+			testDB.Exec("DELETE FROM user_wallets WHERE user_id = ?", id)
+			testDB.Exec("DELETE FROM users WHERE id = ?", id)
+		}
+	})
+
+	It("导出个人数据", func() {
+		w, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/me/export", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(w.Header().Get("Content-Disposition")).To(ContainSubstring("attachment"))
+
+		var export struct {
+			Profile struct {
+				Email string `json:"email"`
+			} `json:"profile"`
+			Orders []struct {
+				ID string `json:"id"`
+			} `json:"orders"`
+			WalletLogs []struct {
+				Amount float64 `json:"amount"`
+			} `json:"wallet_logs"`
+			Coupons  []json.RawMessage `json:"coupons"`
+			Sessions []struct {
+				Current bool `json:"current"`
+			} `json:"sessions"`
+		}
+		Expect(json.Unmarshal(resp.Data, &export)).To(Succeed())
+		Expect(export.Profile.Email).To(Equal(email))
+		Expect(export.Orders).To(HaveLen(1))
+		Expect(export.Orders[0].ID).To(Equal(orderID.String()))
+		Expect(export.WalletLogs).To(HaveLen(1))
+		Expect(export.WalletLogs[0].Amount).To(Equal(20.0))
+		Expect(export.Coupons).To(BeEmpty())
+		Expect(export.Sessions).To(HaveLen(1))
+		Expect(export.Sessions[0].Current).To(BeTrue())
+	})
+
+	It("存在待支付或退款中的订单、余额不为零时拒绝注销", func() {
+		Expect(deleteAccount("wrong-password").Code).To(Equal(errno.ErrUserPasswordIncorrect.FullCode()))
+		Expect(deleteAccount("password123").Code).To(Equal(errno.ErrUserWalletNotEmpty.FullCode()))
+
+		testDB.Exec("UPDATE user_wallets SET balance = 0 WHERE user_id = ?", userID)
+		Expect(deleteAccount("password123").Code).To(Equal(errno.ErrUserHasProcessingOrders.FullCode()))
+
+		// 退款审核通过后会向钱包入账，同样拒绝
+		testDB.Exec("UPDATE orders SET status = ? WHERE id = ?", model.OrderStatusRefunding, orderID)
+		Expect(deleteAccount("password123").Code).To(Equal(errno.ErrUserHasProcessingOrders.FullCode()))
+	})
+
+	It("注销后会话失效、资料匿名化，用户名和邮箱可重新注册", func() {
+		testDB.Exec("UPDATE orders SET status = ? WHERE id = ?", model.OrderStatusCancelled, orderID)
+		Expect(deleteAccount("password123").Code).To(Equal(errno.OK.FullCode()))
+
+		_, resp := doJSONRequest(clientIP, http.MethodGet, "/api/v1/user/me", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAuthInvalidToken.FullCode()))
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+			"email":    email,
+			"password": "password123",
+		})
+		Expect(resp.Code).To(Equal(errno.ErrUserNotFound.FullCode()))
+
+		var u model.User
+		Expect(testDB.Unscoped().First(&u, "id = ?", userID).Error).ToNot(HaveOccurred())
+		Expect(u.DeletedAt.Valid).To(BeTrue())
+		Expect(u.Email).NotTo(Equal(email))
+		Expect(u.UserName).NotTo(Equal(userName))
+
+		// 订单记录保留
+		var count int64
+		testDB.Model(&model.Order{}).Where("user_id = ?", userID).Count(&count)
+		Expect(count).To(Equal(int64(1)))
+
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/register", "", map[string]string{
+			"user_name": userName,
+			"email":     email,
+			"password":  "password123",
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var nu model.User
+		Expect(testDB.First(&nu, "email = ?", email).Error).ToNot(HaveOccurred())
+		newUserID = nu.ID
+		Expect(newUserID).NotTo(Equal(userID))
+	})
+})
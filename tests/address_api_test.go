@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"context"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("AddressApi", Ordered, func() {
+	// 独立 IP，避免与其他用例共享登录限流
+	const clientIP = "198.51.100.23"
+	type shippingInfo struct {
+		Receiver string `json:"receiver"`
+		Phone    string `json:"phone"`
+		City     string `json:"city"`
+		Detail   string `json:"detail"`
+	}
+	type addressData struct {
+		ID string `json:"id"`
+		shippingInfo
+		IsDefault bool `json:"is_default"`
+	}
+	var (
+		userID, otherID, publisherID, productID uuid.UUID
+		accessToken, otherToken                 string
+		homeID, officeID                        string
+	)
+
+	createAddress := func(receiver, city string, isDefault bool) addressData {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/address/create", accessToken, map[string]interface{}{
+			"receiver":   receiver,
+			"phone":      "13800000000",
+			"province":   "浙江省",
+			"city":       city,
+			"district":   "西湖区",
+			"detail":     "文三路 1 号",
+			"is_default": isDefault,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data addressData
+		_ = json.Unmarshal(resp.Data, &data)
+		return data
+	}
+
+	listAddresses := func() []addressData {
+		_, resp := doJSONRequest(clientIP, http.MethodGet, "/api/v1/address/list", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			Addresses []addressData `json:"addresses"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		return data.Addresses
+	}
+
+	createOrder := func(addressID string) Response {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/order/create", accessToken, map[string]interface{}{
+			"items":           []map[string]interface{}{{"product_id": productID.String(), "quantity": 1}},
+			"address_id":      addressID,
+			"idempotency_key": uuid.NewString(),
+		})
+		return resp
+	}
+
+	orderAddress := func(orderID string) *shippingInfo {
+		_, resp := doJSONRequest(clientIP, http.MethodGet, "/api/v1/order/"+orderID, accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			ShippingAddress *shippingInfo `json:"shipping_address"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		return data.ShippingAddress
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID, otherID, publisherID = uuid.New(), uuid.New(), uuid.New()
+		for i, id := range []uuid.UUID{userID, otherID, publisherID} {
+			testDB.Exec(`INSERT INTO users (id, user_name, email, password, email_verified_at, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW(), NOW())`,
+				id, fmt.Sprintf("address-user-%d", i), fmt.Sprintf("address-user-%d@test.com", i), string(pwHash))
+		}
+		productID = uuid.New()
+		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			productID, publisherID, "Address Item", "desc", 10, 10, 0, "active", 1)
+
+		accessToken = loginAs(clientIP, "address-user-0@test.com", "password123").AccessToken
+		otherToken = loginAs(clientIP, "address-user-1@test.com", "password123").AccessToken
+	})
+
+	AfterAll(func() {
+		for _, id := range []uuid.UUID{userID, otherID} {
+			testRedis.Del(context.Background(), fmt.Sprintf("ident:u_sessions:%s", id))
+		}
+		testDB.Exec("DELETE FROM outbox WHERE body IN (SELECT id::text FROM orders WHERE user_id = ?)", userID)
+		testDB.Exec("DELETE FROM orders WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM stock_change_logs WHERE product_id = ?", productID)
+		testDB.Exec("DELETE FROM products WHERE id = ?", productID)
+		testDB.Exec("DELETE FROM addresses WHERE user_id IN ?", []uuid.UUID{userID, otherID})
+		testDB.Exec("DELETE FROM users WHERE id IN ?", []uuid.UUID{userID, otherID, publisherID})
+	})
+
+	It("没有地址时下单不带收货信息", func() {
+		resp := createOrder("")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			OrderID string `json:"order_id"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(orderAddress(data.OrderID)).To(BeNil())
+	})
+
+	It("第一个地址自动设为默认，新增默认地址后原默认地址取消", func() {
+		home := createAddress("张三", "杭州市", false)
+		Expect(home.IsDefault).To(BeTrue())
+		homeID = home.ID
+
+		office := createAddress("张三（公司）", "宁波市", true)
+		Expect(office.IsDefault).To(BeTrue())
+		officeID = office.ID
+
+		addresses := listAddresses()
+		Expect(addresses).To(HaveLen(2))
+		Expect(addresses[0].ID).To(Equal(officeID))
+		Expect(addresses[1].IsDefault).To(BeFalse())
+
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/address/"+homeID+"/default", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(listAddresses()[0].ID).To(Equal(homeID))
+	})
+
+	It("不能访问他人的地址", func() {
+		_, resp := doJSONRequest(clientIP, http.MethodGet, "/api/v1/address/"+homeID, otherToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAddressNotFound.FullCode()))
+		_, resp = doJSONRequest(clientIP, http.MethodPatch, "/api/v1/address/"+homeID, otherToken, map[string]string{"city": "上海市"})
+		Expect(resp.Code).To(Equal(errno.ErrAddressNotFound.FullCode()))
+		_, resp = doJSONRequest(clientIP, http.MethodDelete, "/api/v1/address/"+homeID, otherToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAddressNotFound.FullCode()))
+	})
+
+	It("订单保存下单时的地址快照，之后修改地址不影响订单", func() {
+		resp := createOrder(officeID)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			OrderID string `json:"order_id"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+
+		_, resp = doJSONRequest(clientIP, http.MethodPatch, "/api/v1/address/"+officeID, accessToken, map[string]string{"city": "上海市"})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		snapshot := orderAddress(data.OrderID)
+		Expect(snapshot).NotTo(BeNil())
+		Expect(snapshot.Receiver).To(Equal("张三（公司）"))
+		Expect(snapshot.City).To(Equal("宁波市"))
+
+		// 未指定地址时使用默认地址
+		resp = createOrder("")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(orderAddress(data.OrderID).City).To(Equal("杭州市"))
+
+		// 他人的地址不能用于下单
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/order/create", otherToken, map[string]interface{}{
+			"items":           []map[string]interface{}{{"product_id": productID.String(), "quantity": 1}},
+			"address_id":      homeID,
+			"idempotency_key": uuid.NewString(),
+		})
+		Expect(resp.Code).To(Equal(errno.ErrAddressNotFound.FullCode()))
+	})
+
+	It("删除默认地址后其余地址成为默认", func() {
+		_, resp := doJSONRequest(clientIP, http.MethodDelete, "/api/v1/address/"+homeID, accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		addresses := listAddresses()
+		Expect(addresses).To(HaveLen(1))
+		Expect(addresses[0].ID).To(Equal(officeID))
+		Expect(addresses[0].IsDefault).To(BeTrue())
+	})
+})
@@ -49,11 +49,11 @@ var _ = Describe("AuthApi", Ordered, func() {
 	var cleanup = func() {
 		ctx := context.Background()
 		testDB.Exec("delete from users where email = ? or user_name = ?", "test@example.com", "test")
-		sessions := testRedis.SMembers(ctx, fmt.Sprintf("ident:u_sess:%d", insertUserId))
+		sessions := testRedis.ZRange(ctx, fmt.Sprintf("ident:u_sessions:%s", insertUserId), 0, -1)
 		for _, session := range sessions.Val() {
 			testRedis.Del(ctx, fmt.Sprintf("ident:sess:%s", session))
 		}
-		testRedis.Del(ctx, fmt.Sprintf("ident:u_sess:%s", insertUserId))
+		testRedis.Del(ctx, fmt.Sprintf("ident:u_sessions:%s", insertUserId))
 	}
 
 	BeforeAll(func() {
@@ -72,7 +72,7 @@ var _ = Describe("AuthApi", Ordered, func() {
 	})
 
 	It("登陆成功", func() {
-		testRedis.Del(context.Background(), fmt.Sprintf("ident:u_sess:%s", insertUserId))
+		testRedis.Del(context.Background(), fmt.Sprintf("ident:u_sessions:%s", insertUserId))
 
 		w, resp := doLogin("test@example.com", "123456789")
 		var loginData LoginData
@@ -84,7 +84,7 @@ var _ = Describe("AuthApi", Ordered, func() {
 		Expect(refreshToken).NotTo(BeEmpty())
 
 		ctx := context.Background()
-		result, err := testRedis.SMembers(ctx, fmt.Sprintf("ident:u_sess:%s", insertUserId)).Result()
+		result, err := testRedis.ZRange(ctx, fmt.Sprintf("ident:u_sessions:%s", insertUserId), 0, -1).Result()
 		Expect(err).ToNot(HaveOccurred())
 		Expect(len(result)).To(Equal(1))
 		getRedisField := func(field string) string {
@@ -106,7 +106,7 @@ var _ = Describe("AuthApi", Ordered, func() {
 		Entry("用户名或密码错误2", "test2@example.com", "123456789", errno.ErrUserNotFound),
 	)
 
-	It("POST /api/v1/auth/fetch-access-token - 刷新 AccessToken 成功", func() {
+	It("POST /api/v1/auth/fetch-access-token - 刷新令牌成功并轮换 RefreshToken", func() {
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/fetch-access-token", nil)
 		req.Header.Set("Authorization", refreshToken)
 		req.Header.Set("Content-Type", "application/json")
@@ -124,46 +124,33 @@ var _ = Describe("AuthApi", Ordered, func() {
 
 		Expect(data["access_token"]).NotTo(BeEmpty())
 		Expect(data["access_token"]).NotTo(Equal(accessToken))
+		Expect(data["refresh_token"]).NotTo(BeEmpty())
+		Expect(data["refresh_token"]).NotTo(Equal(refreshToken))
 
 		ctx := context.Background()
-		sessions := testRedis.SMembers(ctx, fmt.Sprintf("ident:u_sess:%s", insertUserId)).Val()
+		sessions := testRedis.ZRange(ctx, fmt.Sprintf("ident:u_sessions:%s", insertUserId), 0, -1).Val()
 
 		Expect(len(sessions)).To(Equal(1))
 
-		currentAt := testRedis.HGet(ctx, fmt.Sprintf("ident:sess:%s", sessions[0]), "at").Val()
-		Expect(currentAt).To(Equal(data["access_token"]))
+		getRedisField := func(field string) string {
+			return testRedis.HGet(ctx, fmt.Sprintf("ident:sess:%s", sessions[0]), field).Val()
+		}
+		Expect(getRedisField("at")).To(Equal(data["access_token"]))
+		Expect(getRedisField("rt")).To(Equal(data["refresh_token"]))
 
 		accessToken = data["access_token"]
+		refreshToken = data["refresh_token"]
 	})
 
-	It("POST /api/v1/auth/fetch-refresh-token - 刷新 RefreshToken 成功", func() {
-		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/fetch-refresh-token", nil)
+	It("POST /api/v1/auth/fetch-access-token - AccessToken 不能用于刷新", func() {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/fetch-access-token", nil)
 		req.Header.Set("Authorization", accessToken)
-		req.Header.Set("Content-Type", "application/json")
 		w := httptest.NewRecorder()
 		testRouter.ServeHTTP(w, req)
 
-		Expect(w.Code).To(Equal(http.StatusOK))
-
 		var resp Response
-		var data map[string]string
 		_ = json.Unmarshal(w.Body.Bytes(), &resp)
-		_ = json.Unmarshal(resp.Data, &data)
-
-		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
-
-		Expect(data["refresh_token"]).NotTo(BeEmpty())
-		Expect(data["refresh_token"]).NotTo(Equal(refreshToken))
-
-		ctx := context.Background()
-		sessions := testRedis.SMembers(ctx, fmt.Sprintf("ident:u_sess:%s", insertUserId)).Val()
-
-		Expect(len(sessions)).To(Equal(1))
-
-		currentAt := testRedis.HGet(ctx, fmt.Sprintf("ident:sess:%s", sessions[0]), "rt").Val()
-		Expect(currentAt).To(Equal(data["refresh_token"]))
-
-		refreshToken = data["refresh_token"]
+		Expect(resp.Code).To(Equal(errno.ErrAuthInvalidToken.FullCode()))
 	})
 
 	It("POST /api/v1/auth/logout - 登出并清理 Redis 会话", func() {
@@ -179,9 +166,9 @@ var _ = Describe("AuthApi", Ordered, func() {
 		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
 
 		ctx := context.Background()
-		userSessKey := fmt.Sprintf("ident:u_sess:%s", insertUserId)
+		userSessKey := fmt.Sprintf("ident:u_sessions:%s", insertUserId)
 
-		sessions := testRedis.SMembers(ctx, userSessKey).Val()
+		sessions := testRedis.ZRange(ctx, userSessKey, 0, -1).Val()
 		Expect(len(sessions)).To(Equal(0), "登出后 Redis 集合应为空")
 
 		exists := testRedis.Exists(ctx, fmt.Sprintf("ident:sess:%s", accessToken)).Val()
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type cartLineItem struct {
+	ProductID string  `json:"product_id"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Quantity  int     `json:"quantity"`
+	Selected  bool    `json:"selected"`
+	Status    string  `json:"status"`
+}
+
+type cartResponse struct {
+	Items          []cartLineItem `json:"items"`
+	SelectedAmount float64        `json:"selected_amount"`
+}
+
+var _ = Describe("CartApi", Ordered, func() {
+	var (
+		publisherID       uuid.UUID
+		buyerID           uuid.UUID
+		productID         uuid.UUID
+		secondProductID   uuid.UUID
+		inactiveProductID uuid.UUID
+		cartToken         string
+	)
+
+	var doCartRequest = func(method, path string, bodyMap map[string]interface{}) Response {
+		var body *bytes.Buffer
+		if bodyMap != nil {
+			raw, _ := json.Marshal(bodyMap)
+			body = bytes.NewBuffer(raw)
+		} else {
+			body = bytes.NewBuffer(nil)
+		}
+		req, _ := http.NewRequest(method, path, body)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", cartToken)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	var getCart = func() cartResponse {
+		resp := doCartRequest(http.MethodGet, "/api/v1/cart", nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var cart cartResponse
+		_ = json.Unmarshal(resp.Data, &cart)
+		return cart
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+
+		publisherID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			publisherID, "cart-publisher", "cart-pub@test.com", string(pwHash))
+
+		buyerID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			buyerID, "cart-buyer", "cart-buyer@test.com", string(pwHash))
+
+		productID = uuid.New()
+		secondProductID = uuid.New()
+		inactiveProductID = uuid.New()
+		insertProduct := `INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`
+		testDB.Exec(insertProduct, productID, publisherID, "Cart Item", "desc", 20.0, 10, 0, "active", 1)
+		testDB.Exec(insertProduct, secondProductID, publisherID, "Cart Item 2", "desc", 5.0, 1, 0, "active", 1)
+		testDB.Exec(insertProduct, inactiveProductID, publisherID, "Inactive", "desc", 1.0, 10, 0, "inactive", 1)
+
+		_, resp := doLogin("cart-buyer@test.com", "password123")
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		cartToken = data.AccessToken
+		Expect(cartToken).NotTo(BeEmpty())
+	})
+
+	AfterAll(func() {
+		testRedis.Del(context.Background(), fmt.Sprintf("cart:%s", buyerID))
+		testDB.Exec("DELETE FROM orders WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM products WHERE id IN (?, ?, ?)", productID, secondProductID, inactiveProductID)
+		testDB.Exec("DELETE FROM users WHERE id IN (?, ?)", publisherID, buyerID)
+	})
+
+	It("加购并累加数量", func() {
+		resp := doCartRequest(http.MethodPost, "/api/v1/cart/items", map[string]interface{}{
+			"product_id": productID.String(), "quantity": 1,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		resp = doCartRequest(http.MethodPost, "/api/v1/cart/items", map[string]interface{}{
+			"product_id": productID.String(), "quantity": 2,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		cart := getCart()
+		Expect(cart.Items).To(HaveLen(1))
+		Expect(cart.Items[0].Quantity).To(Equal(3))
+		Expect(cart.Items[0].Selected).To(BeTrue())
+		Expect(cart.SelectedAmount).To(BeNumerically("~", 60.0, 0.001))
+	})
+
+	It("下架商品不能加购", func() {
+		resp := doCartRequest(http.MethodPost, "/api/v1/cart/items", map[string]interface{}{
+			"product_id": inactiveProductID.String(), "quantity": 1,
+		})
+		Expect(resp.Code).To(Equal(errno.ErrCartProductUnavailable.FullCode()))
+	})
+
+	It("读取时按实时价格与库存校验", func() {
+		resp := doCartRequest(http.MethodPost, "/api/v1/cart/items", map[string]interface{}{
+			"product_id": secondProductID.String(), "quantity": 2,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		testDB.Exec("UPDATE products SET price = ? WHERE id = ?", 25.0, productID)
+
+		cart := getCart()
+		Expect(cart.Items).To(HaveLen(2))
+		for _, item := range cart.Items {
+			switch item.ProductID {
+			case productID.String():
+				Expect(item.Price).To(Equal(25.0))
+				Expect(item.Status).To(Equal("available"))
+			case secondProductID.String():
+				Expect(item.Status).To(Equal("insufficient_stock"))
+			}
+		}
+	})
+
+	It("修改与删除不存在的商品", func() {
+		resp := doCartRequest(http.MethodPatch, "/api/v1/cart/items/"+uuid.New().String(), map[string]interface{}{
+			"quantity": 1,
+		})
+		Expect(resp.Code).To(Equal(errno.ErrCartItemNotFound.FullCode()))
+		resp = doCartRequest(http.MethodDelete, "/api/v1/cart/items/"+uuid.New().String(), nil)
+		Expect(resp.Code).To(Equal(errno.ErrCartItemNotFound.FullCode()))
+	})
+
+	It("结算选中的商品并从购物车移除", func() {
+		resp := doCartRequest(http.MethodPatch, "/api/v1/cart/items/"+secondProductID.String(), map[string]interface{}{
+			"selected": false,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		key := "cart-checkout-" + uuid.New().String()
+		resp = doCartRequest(http.MethodPost, "/api/v1/cart/checkout", map[string]interface{}{
+			"idempotency_key": key,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var checkout struct {
+			OrderID string `json:"order_id"`
+		}
+		_ = json.Unmarshal(resp.Data, &checkout)
+		Expect(checkout.OrderID).NotTo(BeEmpty())
+
+		var order model.Order
+		Expect(testDB.Preload("Items").Where("id = ?", checkout.OrderID).First(&order).Error).ToNot(HaveOccurred())
+		Expect(order.Items).To(HaveLen(1))
+		Expect(order.Items[0].ProductID).To(Equal(productID))
+		Expect(order.Items[0].Quantity).To(Equal(3))
+
+		cart := getCart()
+		Expect(cart.Items).To(HaveLen(1))
+		Expect(cart.Items[0].ProductID).To(Equal(secondProductID.String()))
+
+		By("相同幂等键重复结算返回同一订单")
+		resp = doCartRequest(http.MethodPost, "/api/v1/cart/checkout", map[string]interface{}{
+			"idempotency_key": key,
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var replay struct {
+			OrderID string `json:"order_id"`
+		}
+		_ = json.Unmarshal(resp.Data, &replay)
+		Expect(replay.OrderID).To(Equal(checkout.OrderID))
+	})
+
+	It("没有选中商品时结算失败", func() {
+		resp := doCartRequest(http.MethodPost, "/api/v1/cart/checkout", map[string]interface{}{
+			"idempotency_key": "cart-empty-" + uuid.New().String(),
+		})
+		Expect(resp.Code).To(Equal(errno.ErrCartEmpty.FullCode()))
+	})
+})
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EmailVerifyApi", Ordered, func() {
+	const (
+		email = "verify-flow@test.com"
+		// 独立 IP，避免与其他用例共享登录限流
+		clientIP = "198.51.100.20"
+	)
+	var (
+		userID      uuid.UUID
+		accessToken string
+	)
+
+	verify := func(token string) Response {
+		_, resp := doJSONRequest(clientIP, http.MethodGet, "/api/v1/user/verify?token="+url.QueryEscape(token), "", nil)
+		return resp
+	}
+
+	deposit := func() Response {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/wallet/deposit", accessToken, map[string]interface{}{
+			"amount":          10,
+			"idempotency_key": uuid.NewString(),
+		})
+		return resp
+	}
+
+	BeforeAll(func() {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/register", "", map[string]string{
+			"user_name": "verify-flow",
+			"email":     email,
+			"password":  "password123",
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		var u model.User
+		Expect(testDB.First(&u, "email = ?", email).Error).ToNot(HaveOccurred())
+		userID = u.ID
+
+		accessToken = loginAs(clientIP, email, "password123").AccessToken
+
+		testUserConfig.RequireEmailVerified = true
+	})
+
+	AfterAll(func() {
+		testUserConfig.RequireEmailVerified = false
+		ctx := context.Background()
+		testRedis.Del(ctx,
+			fmt.Sprintf("ident:u_sessions:%s", userID),
+			fmt.Sprintf("user:email_verify_user:%s", userID),
+			fmt.Sprintf("user:email_verify_cooldown:%s", userID),
+		)
+		testDB.Exec("DELETE FROM wallet_logs WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM user_wallets WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("注册后发送验证邮件，未验证前不能充值和下单", func() {
+		Expect(lastMailToken(email, "验证邮箱")).NotTo(BeEmpty())
+
+		var u model.User
+		Expect(testDB.First(&u, "id = ?", userID).Error).ToNot(HaveOccurred())
+		Expect(u.EmailVerifiedAt).To(BeNil())
+
+		Expect(deposit().Code).To(Equal(errno.ErrUserEmailNotVerified.FullCode()))
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/order/create", accessToken, map[string]interface{}{})
+		Expect(resp.Code).To(Equal(errno.ErrUserEmailNotVerified.FullCode()))
+	})
+
+	It("冷却期内重发被拒绝并返回可重试时间", func() {
+		w, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/verify/resend", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrUserVerifyResendTooFrequent.FullCode()))
+		Expect(w.Header().Get("Retry-After")).NotTo(BeEmpty())
+
+		var data map[string]int
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(data["retry_after_seconds"]).To(BeNumerically(">", 0))
+	})
+
+	It("重发后旧链接失效，新链接验证成功且只能使用一次", func() {
+		first := lastMailToken(email, "验证邮箱")
+		testRedis.Del(context.Background(), fmt.Sprintf("user:email_verify_cooldown:%s", userID))
+
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/verify/resend", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		second := lastMailToken(email, "验证邮箱")
+		Expect(second).NotTo(Equal(first))
+
+		Expect(verify(first).Code).To(Equal(errno.ErrUserVerifyTokenInvalid.FullCode()))
+		Expect(verify(second).Code).To(Equal(errno.OK.FullCode()))
+		Expect(verify(second).Code).To(Equal(errno.ErrUserVerifyTokenInvalid.FullCode()))
+
+		var u model.User
+		Expect(testDB.First(&u, "id = ?", userID).Error).ToNot(HaveOccurred())
+		Expect(u.EmailVerifiedAt).NotTo(BeNil())
+	})
+
+	It("验证后可以充值，不能再重发", func() {
+		Expect(deposit().Code).To(Equal(errno.OK.FullCode()))
+
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/verify/resend", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrUserEmailAlreadyVerified.FullCode()))
+	})
+})
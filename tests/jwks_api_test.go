@@ -0,0 +1,172 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"e-commerce/internal/app/identity"
+	"e-commerce/internal/auth"
+	appconfig "e-commerce/internal/config"
+	"e-commerce/pkg/errno"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testSigningKid 测试环境签发令牌使用的密钥 ID
+const testSigningKid = "test-ed25519"
+
+// generateSigningKeyPEM 生成 PKCS#8 PEM 格式的私钥
+func generateSigningKeyPEM(alg string) string {
+	var priv interface{}
+	switch alg {
+	case auth.AlgEdDSA:
+		_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			panic(err)
+		}
+		priv = edPriv
+	case auth.AlgRS256:
+		rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			panic(err)
+		}
+		priv = rsaPriv
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		panic(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+var _ = Describe("JwksApi", Ordered, func() {
+	type jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Crv string `json:"crv"`
+		X   string `json:"x"`
+	}
+
+	var userID uuid.UUID
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "jwks-user", "jwks-user@test.com", string(pwHash))
+	})
+
+	AfterAll(func() {
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("GET /.well-known/jwks.json 返回公钥，可离线验证 AccessToken", func() {
+		req, _ := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+		Expect(w.Code).To(Equal(http.StatusOK))
+
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		Expect(json.Unmarshal(w.Body.Bytes(), &set)).To(Succeed())
+		// HS256 共享密钥不能出现在 JWKS 中
+		Expect(set.Keys).To(HaveLen(1))
+		key := set.Keys[0]
+		Expect(key.Kid).To(Equal(testSigningKid))
+		Expect(key.Kty).To(Equal("OKP"))
+		Expect(key.Crv).To(Equal("Ed25519"))
+		Expect(key.Alg).To(Equal(auth.AlgEdDSA))
+		pub, err := base64.RawURLEncoding.DecodeString(key.X)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, resp := doLogin("jwks-user@test.com", "password123")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+
+		claims := &auth.TokenPayload{}
+		token, err := jwt.ParseWithClaims(data.AccessToken, claims, func(t *jwt.Token) (interface{}, error) {
+			Expect(t.Header["kid"]).To(Equal(testSigningKid))
+			return ed25519.PublicKey(pub), nil
+		}, jwt.WithValidMethods([]string{auth.AlgEdDSA}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token.Valid).To(BeTrue())
+		Expect(claims.AccountId).To(Equal(userID))
+	})
+
+	It("密钥轮换：新密钥签发，旧密钥签发的令牌在移除前仍可验证", func() {
+		oldKey := appconfig.SigningKeyConfig{Kid: "k1", Alg: auth.AlgRS256, PrivateKey: generateSigningKeyPEM(auth.AlgRS256)}
+		newKey := appconfig.SigningKeyConfig{Kid: "k2", Alg: auth.AlgEdDSA, PrivateKey: generateSigningKeyPEM(auth.AlgEdDSA)}
+		claims := auth.TokenPayload{
+			AccountInfo: identity.AccountInfo{AccountId: userID},
+			TokenType:   auth.AccessToken,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+		verify := func(ks *auth.KeySet, token string) error {
+			_, err := jwt.ParseWithClaims(token, &auth.TokenPayload{}, ks.Keyfunc)
+			return err
+		}
+
+		legacySet, err := auth.NewKeySet(&appconfig.AuthSection{TokenSecret: "legacy-secret"})
+		Expect(err).ToNot(HaveOccurred())
+		legacyToken, err := legacySet.Sign(claims)
+		Expect(err).ToNot(HaveOccurred())
+
+		oldSet, err := auth.NewKeySet(&appconfig.AuthSection{TokenSecret: "legacy-secret", SigningKeys: []appconfig.SigningKeyConfig{oldKey}, ActiveKid: "k1"})
+		Expect(err).ToNot(HaveOccurred())
+		oldToken, err := oldSet.Sign(claims)
+		Expect(err).ToNot(HaveOccurred())
+		// 从 HS256 迁移：无 kid 的旧令牌仍按 token_secret 验证
+		Expect(verify(oldSet, legacyToken)).To(Succeed())
+
+		rotatedSet, err := auth.NewKeySet(&appconfig.AuthSection{SigningKeys: []appconfig.SigningKeyConfig{oldKey, newKey}, ActiveKid: "k2"})
+		Expect(err).ToNot(HaveOccurred())
+		newToken, err := rotatedSet.Sign(claims)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verify(rotatedSet, oldToken)).To(Succeed())
+		Expect(verify(rotatedSet, newToken)).To(Succeed())
+		Expect(rotatedSet.JWKS().Keys).To(HaveLen(2))
+		// 移除 token_secret 后无 kid 的旧令牌不再有效
+		Expect(verify(rotatedSet, legacyToken)).To(HaveOccurred())
+
+		retiredSet, err := auth.NewKeySet(&appconfig.AuthSection{SigningKeys: []appconfig.SigningKeyConfig{newKey}, ActiveKid: "k2"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(verify(retiredSet, newToken)).To(Succeed())
+		Expect(verify(retiredSet, oldToken)).To(HaveOccurred())
+	})
+
+	It("拒绝伪造算法的令牌", func() {
+		// 使用公钥作为 HS256 密钥伪造的令牌（算法混淆）不能通过验证
+		ks, err := auth.NewKeySet(&appconfig.AuthSection{
+			SigningKeys: []appconfig.SigningKeyConfig{{Kid: "k1", Alg: auth.AlgEdDSA, PrivateKey: generateSigningKeyPEM(auth.AlgEdDSA)}},
+			ActiveKid:   "k1",
+		})
+		Expect(err).ToNot(HaveOccurred())
+		x, _ := base64.RawURLEncoding.DecodeString(ks.JWKS().Keys[0].X)
+
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		})
+		forged.Header["kid"] = "k1"
+		tokenStr, err := forged.SignedString(x)
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, ks.Keyfunc)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	appconfig "e-commerce/internal/config"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("LoginGuard", Ordered, func() {
+	const (
+		email    = "login-guard@test.com"
+		password = "password123"
+		// 每个用例使用独立的客户端 IP，避免与其他用例共享 IP 维度的计数
+		accountIP = "198.51.100.1"
+		backoffIP = "198.51.100.2"
+		attackIP  = "198.51.100.3"
+		otherIP   = "198.51.100.4"
+	)
+
+	type lockedData struct {
+		RetryAfterSeconds int    `json:"retry_after_seconds"`
+		RetryAt           string `json:"retry_at"`
+	}
+
+	var (
+		userID    uuid.UUID
+		origGuard appconfig.LoginGuardSection
+	)
+
+	loginFrom := func(ip, e, p string) (*httptest.ResponseRecorder, Response) {
+		return doJSONRequest(ip, http.MethodPost, "/api/v1/auth/login", "", map[string]string{"email": e, "password": p})
+	}
+
+	clearGuardKeys := func() {
+		ctx := context.Background()
+		for _, prefix := range []string{"ident:login_fail", "ident:login_lock", "ident:login_lockouts"} {
+			testRedis.Del(ctx, fmt.Sprintf("%s:email:%s", prefix, email))
+			for _, ip := range []string{accountIP, backoffIP, attackIP, otherIP} {
+				testRedis.Del(ctx, fmt.Sprintf("%s:ip:%s", prefix, ip))
+			}
+		}
+	}
+
+	BeforeAll(func() {
+		origGuard = testAuthConfig.LoginGuard
+		testAuthConfig.LoginGuard = appconfig.LoginGuardSection{
+			MaxFailures:      3,
+			MaxFailuresPerIP: 5,
+			FailureWindow:    time.Minute,
+			LockoutBase:      time.Second,
+			LockoutMax:       4 * time.Second,
+		}
+
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "login-guard", email, string(pwHash))
+		clearGuardKeys()
+	})
+
+	AfterAll(func() {
+		testAuthConfig.LoginGuard = origGuard
+		clearGuardKeys()
+		ctx := context.Background()
+		key := fmt.Sprintf("ident:u_sessions:%s", userID)
+		for _, sid := range testRedis.ZRange(ctx, key, 0, -1).Val() {
+			testRedis.Del(ctx, fmt.Sprintf("ident:sess:%s", sid))
+		}
+		testRedis.Del(ctx, key)
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("账号连续失败达到阈值后锁定，锁定期内正确密码也被拒绝，解锁后登录成功并清零计数", func() {
+		for i := 0; i < 2; i++ {
+			_, resp := loginFrom(accountIP, email, "wrong-password")
+			Expect(resp.Code).To(Equal(errno.ErrUserNotFound.FullCode()))
+		}
+
+		w, resp := loginFrom(accountIP, email, "wrong-password")
+		Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+		var data lockedData
+		Expect(json.Unmarshal(resp.Data, &data)).To(Succeed())
+		Expect(data.RetryAfterSeconds).To(Equal(1))
+		Expect(data.RetryAt).NotTo(BeEmpty())
+		Expect(w.Header().Get("Retry-After")).To(Equal("1"))
+
+		_, resp = loginFrom(otherIP, email, password)
+		Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+
+		Eventually(func() string {
+			_, resp := loginFrom(accountIP, email, password)
+			return resp.Code
+		}, 3*time.Second, 200*time.Millisecond).Should(Equal(errno.OK.FullCode()))
+
+		ctx := context.Background()
+		Expect(testRedis.Exists(ctx, "ident:login_fail:email:"+email, "ident:login_lockouts:email:"+email).Val()).To(Equal(int64(0)))
+	})
+
+	It("连续锁定时长按指数增长", func() {
+		// 仅验证账号维度，避免 IP 维度先触发锁定
+		testAuthConfig.LoginGuard.MaxFailuresPerIP = 0
+		defer func() { testAuthConfig.LoginGuard.MaxFailuresPerIP = 5 }()
+
+		lock := func() int {
+			var resp Response
+			for i := 0; i < 3; i++ {
+				_, resp = loginFrom(backoffIP, email, "wrong-password")
+			}
+			Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+			var data lockedData
+			_ = json.Unmarshal(resp.Data, &data)
+			return data.RetryAfterSeconds
+		}
+
+		Expect(lock()).To(Equal(1))
+		time.Sleep(1100 * time.Millisecond)
+		Expect(lock()).To(Equal(2))
+		time.Sleep(2100 * time.Millisecond)
+		Expect(lock()).To(Equal(4))
+		time.Sleep(4100 * time.Millisecond)
+		// 不超过 lockout_max
+		Expect(lock()).To(Equal(4))
+		time.Sleep(4100 * time.Millisecond)
+
+		_, resp := loginFrom(backoffIP, email, password)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+	})
+
+	It("同一 IP 尝试多个账号失败达到阈值后锁定该 IP", func() {
+		for i := 0; i < 4; i++ {
+			_, resp := loginFrom(attackIP, fmt.Sprintf("nobody-%d@test.com", i), "wrong-password")
+			Expect(resp.Code).To(Equal(errno.ErrUserNotFound.FullCode()))
+		}
+		_, resp := loginFrom(attackIP, "nobody-4@test.com", "wrong-password")
+		Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+
+		// 被锁定的 IP 上正确的账号密码同样被拒绝，其他 IP 不受影响
+		_, resp = loginFrom(attackIP, email, password)
+		Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+		_, resp = loginFrom(otherIP, email, password)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		ctx := context.Background()
+		for i := 0; i < 5; i++ {
+			for _, prefix := range []string{"ident:login_fail", "ident:login_lock", "ident:login_lockouts"} {
+				testRedis.Del(ctx, fmt.Sprintf("%s:email:nobody-%d@test.com", prefix, i))
+			}
+		}
+	})
+})
@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// totpAt 按 RFC 6238（SHA1、6 位、30 秒）计算 now 之后第 offset 个时间步的验证码
+func totpAt(secret string, offset int64) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	Expect(err).ToNot(HaveOccurred())
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30+offset))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	o := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[o:o+4])&0x7fffffff)%1000000)
+}
+
+var _ = Describe("MfaApi", Ordered, func() {
+	const email = "mfa-seller@test.com"
+	type mfaLoginData struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		MFARequired  bool   `json:"mfa_required"`
+		MFAToken     string `json:"mfa_token"`
+	}
+	var (
+		userID        uuid.UUID
+		plainToken    string
+		mfaToken      string
+		secret        string
+		confirmCode   string
+		recoveryCodes []string
+		// clientIP 每个用例使用独立 IP，避免登录限流（5 req/s）影响连续的登录请求
+		clientIP = "198.51.100.120"
+		ipSeq    int
+	)
+
+	BeforeEach(func() {
+		ipSeq++
+		clientIP = fmt.Sprintf("198.51.100.%d", 120+ipSeq)
+	})
+
+	// login 开启两步验证后返回 mfa_token 而非令牌对，因此不使用 loginAs
+	login := func() mfaLoginData {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+			"email":    email,
+			"password": "password123",
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data mfaLoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		return data
+	}
+
+	loginMFA := func(token, code string) (Response, mfaLoginData) {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/login/mfa", "", map[string]string{
+			"mfa_token": token,
+			"code":      code,
+		})
+		var data mfaLoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		return resp, data
+	}
+
+	createProduct := func(token string) Response {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/product/create", token, map[string]interface{}{
+			"name":        "MFA 商品",
+			"description": "MFA 测试",
+			"price":       10,
+			"status":      "active",
+			"stock":       1,
+		})
+		return resp
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW())`,
+			userID, "mfa-seller", email, string(pwHash), model.UserRoleSeller)
+		plainToken = login().AccessToken
+		Expect(plainToken).NotTo(BeEmpty())
+	})
+
+	AfterAll(func() {
+		testRedis.Del(context.Background(), fmt.Sprintf("ident:u_sessions:%s", userID))
+		testDB.Exec("DELETE FROM products WHERE publisher = ?", userID)
+		testDB.Exec("DELETE FROM user_recovery_codes WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM user_totps WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("绑定验证器并确认后开启两步验证", func() {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/confirm", plainToken, map[string]string{"code": "123456"})
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFANotEnrolled.FullCode()))
+
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/enroll", plainToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var enrollment struct {
+			Secret        string   `json:"secret"`
+			OTPAuthURI    string   `json:"otpauth_uri"`
+			RecoveryCodes []string `json:"recovery_codes"`
+		}
+		_ = json.Unmarshal(resp.Data, &enrollment)
+		Expect(enrollment.OTPAuthURI).To(HavePrefix("otpauth://totp/"))
+		Expect(enrollment.OTPAuthURI).To(ContainSubstring("secret=" + enrollment.Secret))
+		Expect(enrollment.RecoveryCodes).To(HaveLen(10))
+		secret, recoveryCodes = enrollment.Secret, enrollment.RecoveryCodes
+
+		// 确认前登录不需要两步验证
+		preMFA := login()
+		Expect(preMFA.MFARequired).To(BeFalse())
+
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/confirm", plainToken, map[string]string{"code": totpAt(secret, -10)})
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFACodeInvalid.FullCode()))
+		confirmCode = totpAt(secret, 0)
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/confirm", plainToken, map[string]string{"code": confirmCode})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		// 开启后其他仅凭密码登录的会话被注销，当前会话保留
+		_, resp = doJSONRequest(clientIP, http.MethodGet, "/api/v1/auth/sessions", preMFA.AccessToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAuthInvalidToken.FullCode()))
+
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/enroll", plainToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFAAlreadyEnabled.FullCode()))
+	})
+
+	It("开启后登录只返回 mfa_token，验证码不能重放", func() {
+		data := login()
+		Expect(data.MFARequired).To(BeTrue())
+		Expect(data.MFAToken).NotTo(BeEmpty())
+		Expect(data.AccessToken).To(BeEmpty())
+
+		// mfa_token 不能当作访问令牌使用
+		_, resp := doJSONRequest(clientIP, http.MethodGet, "/api/v1/auth/sessions", data.MFAToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAuthInvalidToken.FullCode()))
+
+		// 确认绑定时使用过的验证码不能再次使用
+		resp, _ = loginMFA(data.MFAToken, confirmCode)
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFACodeInvalid.FullCode()))
+
+		resp, tokens := loginMFA(data.MFAToken, totpAt(secret, 1))
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(tokens.AccessToken).NotTo(BeEmpty())
+		mfaToken = tokens.AccessToken
+
+		resp, _ = loginMFA(data.MFAToken, totpAt(secret, 1))
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFATokenInvalid.FullCode()))
+	})
+
+	It("要求两步验证的角色未通过两步验证时不能管理商品", func() {
+		testAuthConfig.MFA.RequiredRoles = []string{string(model.UserRoleSeller)}
+		defer func() { testAuthConfig.MFA.RequiredRoles = nil }()
+
+		Expect(createProduct(plainToken).Code).To(Equal(errno.ErrAuthMFARequired.FullCode()))
+		Expect(createProduct(mfaToken).Code).To(Equal(errno.OK.FullCode()))
+	})
+
+	It("恢复码可以代替验证码登录且只能使用一次", func() {
+		resp, _ := loginMFA(login().MFAToken, recoveryCodes[0])
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		resp, _ = loginMFA(login().MFAToken, recoveryCodes[0])
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFACodeInvalid.FullCode()))
+	})
+
+	It("验证码错误次数过多后需重新登录", func() {
+		testAuthConfig.MFA.MaxAttempts = 3
+		defer func() { testAuthConfig.MFA.MaxAttempts = 5 }()
+
+		token := login().MFAToken
+		for i := 0; i < 3; i++ {
+			resp, _ := loginMFA(token, "wrong-code")
+			Expect(resp.Code).To(Equal(errno.ErrAuthMFACodeInvalid.FullCode()))
+		}
+		resp, _ := loginMFA(token, recoveryCodes[1])
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFATokenInvalid.FullCode()))
+
+		// 成功登录后清零失败计数，避免影响后续用例
+		resp, _ = loginMFA(login().MFAToken, recoveryCodes[1])
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+	})
+
+	It("关闭两步验证需要通过两步验证登录的会话，验证码错误计入账号锁定", func() {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/disable", plainToken, map[string]string{"code": recoveryCodes[2]})
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFARequired.FullCode()))
+
+		origGuard := testAuthConfig.LoginGuard
+		testAuthConfig.LoginGuard.MaxFailures = 2
+		defer func() {
+			testAuthConfig.LoginGuard = origGuard
+			ctx := context.Background()
+			for _, prefix := range []string{"ident:login_fail", "ident:login_lock", "ident:login_lockouts"} {
+				testRedis.Del(ctx, fmt.Sprintf("%s:email:%s", prefix, email))
+			}
+		}()
+
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/disable", mfaToken, map[string]string{"code": "wrong-code"})
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFACodeInvalid.FullCode()))
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/disable", mfaToken, map[string]string{"code": "wrong-code"})
+		Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+		// 锁定期内正确的恢复码同样被拒绝
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/disable", mfaToken, map[string]string{"code": recoveryCodes[2]})
+		Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+	})
+
+	It("关闭两步验证后直接返回令牌对", func() {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/disable", mfaToken, map[string]string{"code": "wrong-code"})
+		Expect(resp.Code).To(Equal(errno.ErrAuthMFACodeInvalid.FullCode()))
+		_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/mfa/totp/disable", mfaToken, map[string]string{"code": recoveryCodes[2]})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		data := login()
+		Expect(data.MFARequired).To(BeFalse())
+		Expect(data.AccessToken).NotTo(BeEmpty())
+	})
+})
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var _ = Describe("RabbitMQ 断线重连", Ordered, func() {
+	// 排他队列随连接关闭而删除，重连后能再次出现说明 SetupFunc 已重新执行
+	const probeQueue = "test.mq.reconnect.probe"
+
+	var (
+		mu      sync.Mutex
+		probeCh *amqp.Channel
+		setups  int
+		msgIDs  []uuid.UUID
+	)
+
+	var probeMessages = func() int {
+		mu.Lock()
+		ch := probeCh
+		mu.Unlock()
+		q, err := ch.QueueDeclarePassive(probeQueue, false, false, true, false, nil)
+		if err != nil {
+			return -1
+		}
+		return q.Messages
+	}
+
+	var enqueueProbe = func() {
+		msg := &model.OutboxMessage{RoutingKey: probeQueue, Body: "probe", Status: model.OutboxStatusPending}
+		Expect(testDB.Create(msg).Error).ToNot(HaveOccurred())
+		msgIDs = append(msgIDs, msg.ID)
+	}
+
+	BeforeAll(func() {
+		err := mqManager.Register("test-reconnect-probe", func(ch *amqp.Channel) error {
+			if _, err := ch.QueueDeclare(probeQueue, false, false, true, false, nil); err != nil {
+				return err
+			}
+			mu.Lock()
+			probeCh = ch
+			setups++
+			mu.Unlock()
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mqManager.IsReady()).To(BeTrue())
+	})
+
+	AfterAll(func() {
+		testDB.Where("id IN ?", msgIDs).Delete(&model.OutboxMessage{})
+	})
+
+	It("outbox 消息经 relay 投递到队列", func() {
+		enqueueProbe()
+		Eventually(probeMessages, 5*time.Second, 100*time.Millisecond).Should(Equal(1))
+	})
+
+	It("broker 断开所有连接后自动重连，重新声明拓扑并恢复投递", func() {
+		code, _, err := rmqContainer.Exec(context.Background(), []string{"rabbitmqctl", "close_all_connections", "test"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(code).To(Equal(0))
+
+		Eventually(mqManager.IsReady, 2*time.Second, 50*time.Millisecond).Should(BeFalse())
+		Eventually(mqManager.IsReady, 30*time.Second, 200*time.Millisecond).Should(BeTrue())
+		mu.Lock()
+		Expect(setups).To(Equal(2))
+		mu.Unlock()
+
+		// 排他队列已随旧连接删除，新队列只收到重连后投递的消息
+		enqueueProbe()
+		Eventually(probeMessages, 10*time.Second, 100*time.Millisecond).Should(Equal(1))
+
+		var msg model.OutboxMessage
+		Expect(testDB.Where("id = ?", msgIDs[len(msgIDs)-1]).First(&msg).Error).ToNot(HaveOccurred())
+		Expect(msg.Status).To(Equal(model.OutboxStatusSent))
+	})
+})
@@ -2,32 +2,43 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"e-commerce/internal/model"
+	"e-commerce/internal/order"
 	"e-commerce/pkg/errno"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"time"
 
 	"github.com/google/uuid"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type loginData struct {
 	AccessToken string `json:"access_token"`
 }
 
-type orderItem struct {
-	ID            string  `json:"id"`
+type orderLineItem struct {
 	ProductID     string  `json:"product_id"`
 	Quantity      int     `json:"quantity"`
 	SnapshotTitle string  `json:"snapshot_title"`
 	SnapshotPrice float64 `json:"snapshot_price"`
-	Status        int     `json:"status"`
-	CreatedAt     string  `json:"created_at"`
+	Subtotal      float64 `json:"subtotal"`
+}
+
+type orderItem struct {
+	ID          string          `json:"id"`
+	Items       []orderLineItem `json:"items"`
+	TotalAmount float64         `json:"total_amount"`
+	Status      int             `json:"status"`
+	CreatedAt   string          `json:"created_at"`
 }
 
 type listOrdersResponse struct {
@@ -37,11 +48,14 @@ type listOrdersResponse struct {
 
 var _ = Describe("OrderApi", Ordered, func() {
 	var (
-		publisherID      uuid.UUID
-		buyerID          uuid.UUID
-		productID        uuid.UUID
-		lowStockProduct  = uuid.New()
-		accessToken      string
+		publisherID     uuid.UUID
+		buyerID         uuid.UUID
+		productID       uuid.UUID
+		secondProductID uuid.UUID
+		lowStockProduct = uuid.New()
+		flashProductID  = uuid.New()
+		legacyProductID = uuid.New()
+		accessToken     string
 	)
 
 	var doLogin = func(email, password string) string {
@@ -75,6 +89,41 @@ var _ = Describe("OrderApi", Ordered, func() {
 		return w, resp
 	}
 
+	var doPayOrder = func(token, orderID, key string) Response {
+		body, _ := json.Marshal(map[string]interface{}{"idempotency_key": key})
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/order/"+orderID+"/pay", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	var doCancelOrder = func(token, orderID string) Response {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/order/"+orderID+"/cancel", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	var lines = func(productID uuid.UUID, quantity int) []map[string]interface{} {
+		return []map[string]interface{}{
+			{"product_id": productID.String(), "quantity": quantity},
+		}
+	}
+
+	// expireOrder 将订单支付期限置为已过期，模拟超时消息在到期后到达
+	var expireOrder = func(orderID string) {
+		testDB.Exec("UPDATE orders SET pay_deadline = ? WHERE id = ?", time.Now().Add(-time.Second), orderID)
+	}
+
 	var doListOrders = func(token string, params map[string]string) (*httptest.ResponseRecorder, Response) {
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/order/list", nil)
 		req.Header.Set("Authorization", token)
@@ -106,16 +155,31 @@ var _ = Describe("OrderApi", Ordered, func() {
 		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
 			productID, publisherID, "Test Item", "desc", 99.99, 10, 0, "active", 1)
 
+		secondProductID = uuid.New()
+		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			secondProductID, publisherID, "Second Item", "desc", 10.5, 5, 0, "active", 1)
+
 		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
 			lowStockProduct, publisherID, "Low Stock", "desc", 9.99, 0, 0, "active", 1)
 
+		// 秒杀商品：5 分钟支付时限
+		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, pay_timeout_seconds, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			flashProductID, publisherID, "Flash Item", "desc", 5.0, 10, 0, "active", 300, 1)
+
+		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			legacyProductID, publisherID, "Legacy Item", "desc", 20.0, 10, 0, "active", 1)
+
 		accessToken = doLogin("order-buyer@test.com", "password123")
 		Expect(accessToken).NotTo(BeEmpty())
 	})
 
 	AfterAll(func() {
+		testDB.Exec("DELETE FROM wallet_logs WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM user_wallets WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM outbox WHERE body IN (SELECT id::text FROM orders WHERE user_id = ?)", buyerID)
 		testDB.Exec("DELETE FROM orders WHERE user_id = ?", buyerID)
-		testDB.Exec("DELETE FROM products WHERE id IN (?, ?)", productID, lowStockProduct)
+		testDB.Exec("DELETE FROM stock_change_logs WHERE product_id IN (?, ?, ?, ?, ?)", productID, secondProductID, lowStockProduct, flashProductID, legacyProductID)
+		testDB.Exec("DELETE FROM products WHERE id IN (?, ?, ?, ?, ?)", productID, secondProductID, lowStockProduct, flashProductID, legacyProductID)
 		testDB.Exec("DELETE FROM users WHERE id IN (?, ?)", publisherID, buyerID)
 	})
 
@@ -123,23 +187,74 @@ var _ = Describe("OrderApi", Ordered, func() {
 		It("正常创建订单并扣减库存", func() {
 			key := fmt.Sprintf("create-happy-%d", time.Now().UnixNano())
 			_, resp := doCreateOrder(accessToken, map[string]interface{}{
-				"product_id":      productID.String(),
-				"quantity":        2,
+				"items":           lines(productID, 2),
 				"idempotency_key": key,
 			})
 			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
 
 			var order model.Order
-			err := testDB.Where("idempotency_key = ?", key).First(&order).Error
+			err := testDB.Preload("Items").Where("idempotency_key = ?", key).First(&order).Error
 			Expect(err).ToNot(HaveOccurred())
-			Expect(order.SnapshotTitle).To(Equal("Test Item"))
-			Expect(order.SnapshotPrice).To(Equal(99.99))
-			Expect(order.Quantity).To(Equal(2))
+			Expect(order.Items).To(HaveLen(1))
+			Expect(order.Items[0].SnapshotTitle).To(Equal("Test Item"))
+			Expect(order.Items[0].SnapshotPrice).To(Equal(99.99))
+			Expect(order.Items[0].Quantity).To(Equal(2))
 			Expect(order.Status).To(Equal(model.OrderStatusProcessing))
 
 			var p model.Product
 			testDB.Where("id = ?", productID).First(&p)
 			Expect(p.Stock).To(Equal(8))
+			Expect(p.FrozenStock).To(Equal(2))
+
+			// 普通商品使用全局支付时限
+			Expect(order.PayDeadline).To(BeTemporally("~", order.CreatedAt.Add(30*time.Minute), 5*time.Second))
+
+			// 超时消息与订单同事务写入发件箱，到支付期限才由 relay 投递
+			var msg model.OutboxMessage
+			Expect(testDB.Where("body = ?", order.ID.String()).First(&msg).Error).ToNot(HaveOccurred())
+			Expect(msg.Status).To(Equal(model.OutboxStatusPending))
+			Expect(msg.NextAttemptAt).To(BeTemporally("~", order.PayDeadline, time.Millisecond))
+		})
+
+		It("多商品下单，合并重复商品并逐一扣减库存", func() {
+			key := fmt.Sprintf("create-multi-%d", time.Now().UnixNano())
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items": []map[string]interface{}{
+					{"product_id": secondProductID.String(), "quantity": 1},
+					{"product_id": productID.String(), "quantity": 1},
+					{"product_id": secondProductID.String(), "quantity": 2},
+				},
+				"idempotency_key": key,
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+			var order model.Order
+			err := testDB.Preload("Items").Where("idempotency_key = ?", key).First(&order).Error
+			Expect(err).ToNot(HaveOccurred())
+			Expect(order.Items).To(HaveLen(2))
+			Expect(order.TotalAmount).To(BeNumerically("~", 99.99+10.5*3, 0.001))
+
+			var second model.Product
+			testDB.Where("id = ?", secondProductID).First(&second)
+			Expect(second.Stock).To(Equal(2))
+		})
+
+		It("任一商品库存不足时整单回滚", func() {
+			var before model.Product
+			testDB.Where("id = ?", productID).First(&before)
+
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items": []map[string]interface{}{
+					{"product_id": productID.String(), "quantity": 1},
+					{"product_id": lowStockProduct.String(), "quantity": 1},
+				},
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.ErrProductStockInsufficient.FullCode()))
+
+			var after model.Product
+			testDB.Where("id = ?", productID).First(&after)
+			Expect(after.Stock).To(Equal(before.Stock))
 		})
 
 		DescribeTable("冲突路径",
@@ -149,31 +264,46 @@ var _ = Describe("OrderApi", Ordered, func() {
 				Expect(resp.UserMsg).To(Equal(expectedErr.Message))
 			},
 			Entry("库存不足", map[string]interface{}{
-				"product_id": lowStockProduct.String(), "quantity": 1, "idempotency_key": uuid.New().String(),
+				"items": lines(lowStockProduct, 1), "idempotency_key": uuid.New().String(),
 			}, errno.ErrProductStockInsufficient),
 			Entry("商品不存在", map[string]interface{}{
-				"product_id": uuid.New().String(), "quantity": 1, "idempotency_key": uuid.New().String(),
+				"items": lines(uuid.New(), 1), "idempotency_key": uuid.New().String(),
 			}, errno.ErrOrderProductIdNotFound),
+			Entry("缺少items", map[string]interface{}{
+				"idempotency_key": uuid.New().String(),
+			}, errno.ErrInvalidParam),
 			Entry("缺少product_id", map[string]interface{}{
-				"quantity": 1, "idempotency_key": uuid.New().String(),
+				"items": []map[string]interface{}{{"quantity": 1}}, "idempotency_key": uuid.New().String(),
 			}, errno.ErrInvalidParam),
 			Entry("数量为0", map[string]interface{}{
-				"product_id": productID.String(), "quantity": 0, "idempotency_key": uuid.New().String(),
+				"items": lines(productID, 0), "idempotency_key": uuid.New().String(),
 			}, errno.ErrInvalidParam),
 		)
 
+		It("超过商品种类上限时结算同样被拒绝", func() {
+			items := make([]order.CreateOrderItemParam, 0, order.MaxOrderItems+1)
+			for i := 0; i <= order.MaxOrderItems; i++ {
+				items = append(items, order.CreateOrderItemParam{ProductID: uuid.New(), Quantity: 1})
+			}
+			_, err := testOrderSvc.CreateOrder(context.Background(), buyerID, order.CreateOrderParam{
+				Items:          items,
+				IdempotencyKey: uuid.New().String(),
+			})
+			var e *errno.Errno
+			Expect(errors.As(err, &e)).To(BeTrue())
+			Expect(e.FullCode()).To(Equal(errno.ErrInvalidParam.FullCode()))
+		})
+
 		It("幂等键重复返回冲突", func() {
 			key := fmt.Sprintf("dup-key-%d", time.Now().UnixNano())
 			_, resp1 := doCreateOrder(accessToken, map[string]interface{}{
-				"product_id":      productID.String(),
-				"quantity":        1,
+				"items":           lines(productID, 1),
 				"idempotency_key": key,
 			})
 			Expect(resp1.Code).To(Equal(errno.OK.FullCode()))
 
 			_, resp2 := doCreateOrder(accessToken, map[string]interface{}{
-				"product_id":      productID.String(),
-				"quantity":        1,
+				"items":           lines(productID, 1),
 				"idempotency_key": key,
 			})
 			Expect(resp2.Code).To(Equal(errno.OK.FullCode()))
@@ -182,18 +312,17 @@ var _ = Describe("OrderApi", Ordered, func() {
 
 	Describe("GET /api/v1/order/list", func() {
 		It("返回用户订单列表", func() {
-				testDB.Exec("DELETE FROM orders WHERE user_id = ?", buyerID)
+			testDB.Exec("DELETE FROM orders WHERE user_id = ?", buyerID)
 			for i := 0; i < 3; i++ {
 				key := fmt.Sprintf("list-data-%d-%d", i, time.Now().UnixNano())
 				doCreateOrder(accessToken, map[string]interface{}{
-					"product_id":      productID.String(),
-					"quantity":        1,
+					"items":           lines(productID, 1),
 					"idempotency_key": key,
 				})
 			}
 
 			_, resp := doListOrders(accessToken, map[string]string{
-				"page_num": "1",
+				"page_num":  "1",
 				"page_size": "2",
 			})
 			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
@@ -202,10 +331,11 @@ var _ = Describe("OrderApi", Ordered, func() {
 			_ = json.Unmarshal(resp.Data, &listResp)
 			Expect(len(listResp.Orders)).To(Equal(2))
 			Expect(listResp.Total).To(Equal(int64(3)))
-			Expect(listResp.Orders[0].SnapshotTitle).To(Equal("Test Item"))
+			Expect(listResp.Orders[0].Items).To(HaveLen(1))
+			Expect(listResp.Orders[0].Items[0].SnapshotTitle).To(Equal("Test Item"))
 
 			_, resp2 := doListOrders(accessToken, map[string]string{
-				"page_num": "2",
+				"page_num":  "2",
 				"page_size": "2",
 			})
 			Expect(resp2.Code).To(Equal(errno.OK.FullCode()))
@@ -215,4 +345,413 @@ var _ = Describe("OrderApi", Ordered, func() {
 			Expect(len(listResp2.Orders)).To(Equal(1))
 		})
 	})
-})
\ No newline at end of file
+
+	Describe("POST /api/v1/order/:id/pay", func() {
+		var createOrderForPay = func() string {
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(productID, 1),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var data struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &data)
+			return data.OrderID
+		}
+
+		var setBalance = func(balance float64) {
+			testDB.Exec(`INSERT INTO user_wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())
+				ON CONFLICT (user_id) DO UPDATE SET balance = EXCLUDED.balance`, buyerID, balance)
+		}
+
+		It("余额不足时支付失败且订单保持处理中", func() {
+			setBalance(50)
+			orderID := createOrderForPay()
+
+			resp := doPayOrder(accessToken, orderID, uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.ErrWalletInsufficientBalance.FullCode()))
+
+			var order model.Order
+			testDB.Where("id = ?", orderID).First(&order)
+			Expect(order.Status).To(Equal(model.OrderStatusProcessing))
+
+			var wallet model.UserWallet
+			testDB.Where("user_id = ?", buyerID).First(&wallet)
+			Expect(wallet.Balance).To(Equal(50.0))
+		})
+
+		It("支付成功扣减余额、记录流水并完成订单，重复支付幂等", func() {
+			setBalance(500)
+			orderID := createOrderForPay()
+			key := "pay-" + uuid.New().String()
+			var before model.Product
+			testDB.Where("id = ?", productID).First(&before)
+
+			resp := doPayOrder(accessToken, orderID, key)
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+			By("支付后扣减冻结库存，可售库存不变")
+			var after model.Product
+			testDB.Where("id = ?", productID).First(&after)
+			Expect(after.Stock).To(Equal(before.Stock))
+			Expect(after.FrozenStock).To(Equal(before.FrozenStock - 1))
+
+			var order model.Order
+			testDB.Where("id = ?", orderID).First(&order)
+			Expect(order.Status).To(Equal(model.OrderStatusCompleted))
+
+			var wallet model.UserWallet
+			testDB.Where("user_id = ?", buyerID).First(&wallet)
+			Expect(wallet.Balance).To(BeNumerically("~", 500-99.99, 0.001))
+
+			var log model.WalletLog
+			Expect(testDB.Where("idempotency_key = ?", key).First(&log).Error).ToNot(HaveOccurred())
+			Expect(log.Type).To(Equal(model.WalletLogTypePayment))
+			Expect(log.Amount).To(BeNumerically("~", -99.99, 0.001))
+
+			resp = doPayOrder(accessToken, orderID, key)
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			testDB.Where("user_id = ?", buyerID).First(&wallet)
+			Expect(wallet.Balance).To(BeNumerically("~", 500-99.99, 0.001))
+		})
+
+		It("超时订单不能支付", func() {
+			setBalance(500)
+			orderID := createOrderForPay()
+			testDB.Exec("UPDATE orders SET status = ? WHERE id = ?", model.OrderStatusTimeout, orderID)
+
+			resp := doPayOrder(accessToken, orderID, uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.ErrOrderStatusInvalid.FullCode()))
+		})
+
+		It("不能支付他人或不存在的订单", func() {
+			resp := doPayOrder(accessToken, uuid.New().String(), uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.ErrOrderNotFound.FullCode()))
+		})
+	})
+	Describe("POST /api/v1/order/:id/cancel", func() {
+		var getStock = func(id uuid.UUID) int {
+			var p model.Product
+			testDB.Where("id = ?", id).First(&p)
+			return p.Stock
+		}
+
+		It("取消订单归还库存并记录流水，重复取消幂等", func() {
+			before := getStock(secondProductID)
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(secondProductID, 2),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var data struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &data)
+			Expect(getStock(secondProductID)).To(Equal(before - 2))
+
+			resp = doCancelOrder(accessToken, data.OrderID)
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+			var order model.Order
+			testDB.Where("id = ?", data.OrderID).First(&order)
+			Expect(order.Status).To(Equal(model.OrderStatusCancelled))
+			Expect(getStock(secondProductID)).To(Equal(before))
+
+			var log model.StockChangeLog
+			Expect(testDB.Where("product_id = ? AND reason = ?", secondProductID, model.StockChangeRelease).
+				Order("created_at DESC").First(&log).Error).ToNot(HaveOccurred())
+			Expect(log.Quantity).To(Equal(2))
+			Expect(log.FrozenQuantity).To(Equal(-2))
+
+			resp = doCancelOrder(accessToken, data.OrderID)
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			Expect(getStock(secondProductID)).To(Equal(before))
+		})
+
+		It("已完成的订单不能取消", func() {
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(secondProductID, 1),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var data struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &data)
+			testDB.Exec("UPDATE orders SET status = ? WHERE id = ?", model.OrderStatusCompleted, data.OrderID)
+
+			resp = doCancelOrder(accessToken, data.OrderID)
+			Expect(resp.Code).To(Equal(errno.ErrOrderStatusInvalid.FullCode()))
+		})
+
+		It("不能取消不存在的订单", func() {
+			resp := doCancelOrder(accessToken, uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.ErrOrderNotFound.FullCode()))
+		})
+	})
+	Describe("订单超时关单", func() {
+		It("超时关单在同一事务内归还库存并记录流水，重复消息幂等", func() {
+			var p model.Product
+			testDB.Where("id = ?", secondProductID).First(&p)
+			before := p.Stock
+
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(secondProductID, 1),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var data struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &data)
+			orderID := uuid.MustParse(data.OrderID)
+
+			expireOrder(data.OrderID)
+			Expect(testOrderSvc.HandleOrderTimeout(context.Background(), orderID)).To(Succeed())
+
+			var order model.Order
+			testDB.Where("id = ?", orderID).First(&order)
+			Expect(order.Status).To(Equal(model.OrderStatusTimeout))
+			testDB.Where("id = ?", secondProductID).First(&p)
+			Expect(p.Stock).To(Equal(before))
+
+			var count int64
+			testDB.Model(&model.StockChangeLog{}).
+				Where("product_id = ? AND reason = ?", secondProductID, model.StockChangeTimeout).
+				Count(&count)
+			Expect(count).To(Equal(int64(1)))
+
+			Expect(testOrderSvc.HandleOrderTimeout(context.Background(), orderID)).To(Succeed())
+			testDB.Where("id = ?", secondProductID).First(&p)
+			Expect(p.Stock).To(Equal(before))
+		})
+	})
+	Describe("两阶段库存迁移", func() {
+		var getProduct = func() model.Product {
+			var p model.Product
+			testDB.Where("id = ?", legacyProductID).First(&p)
+			return p
+		}
+
+		// migrate 执行迁移脚本；脚本作用于全部待支付订单，执行后恢复其他商品的冻结库存，避免影响其他用例
+		var migrate = func() {
+			script, err := os.ReadFile("../migrations/20261018000100_stock_reservation.sql")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(testDB.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec("CREATE TEMP TABLE frozen_before ON COMMIT DROP AS SELECT id, frozen_stock FROM products WHERE id <> ?", legacyProductID).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(string(script)).Error; err != nil {
+					return err
+				}
+				return tx.Exec("UPDATE products p SET frozen_stock = b.frozen_stock FROM frozen_before b WHERE p.id = b.id").Error
+			})).To(Succeed())
+		}
+
+		// createLegacyOrder 创建待支付订单并清零冻结库存，模拟迁移前直接扣减库存的订单
+		var createLegacyOrder = func(quantity int) uuid.UUID {
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(legacyProductID, quantity),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var data struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &data)
+			return uuid.MustParse(data.OrderID)
+		}
+
+		It("迁移为历史待支付订单补齐冻结库存，之后可以取消或超时关单", func() {
+			cancelID := createLegacyOrder(2)
+			timeoutID := createLegacyOrder(3)
+			testDB.Exec("UPDATE products SET frozen_stock = 0 WHERE id = ?", legacyProductID)
+			Expect(getProduct().Stock).To(Equal(5))
+
+			migrate()
+			Expect(getProduct().FrozenStock).To(Equal(5))
+
+			resp := doCancelOrder(accessToken, cancelID.String())
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+			expireOrder(timeoutID.String())
+			Expect(testOrderSvc.HandleOrderTimeout(context.Background(), timeoutID)).To(Succeed())
+
+			p := getProduct()
+			Expect(p.Stock).To(Equal(10))
+			Expect(p.FrozenStock).To(Equal(0))
+		})
+	})
+	Describe("支付期限", func() {
+		type createdOrder struct {
+			OrderID     string `json:"order_id"`
+			PayDeadline string `json:"pay_deadline"`
+		}
+
+		var createFlashOrder = func(items []map[string]interface{}) createdOrder {
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           items,
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var created createdOrder
+			_ = json.Unmarshal(resp.Data, &created)
+			return created
+		}
+
+		var orderStatus = func(orderID string) model.OrderStatus {
+			var o model.Order
+			testDB.Where("id = ?", orderID).First(&o)
+			return o.Status
+		}
+
+		var mixedOrder createdOrder
+
+		It("包含秒杀商品时按最短支付时限，并在响应中返回截止时间", func() {
+			mixedOrder = createFlashOrder([]map[string]interface{}{
+				{"product_id": flashProductID.String(), "quantity": 1},
+				{"product_id": secondProductID.String(), "quantity": 1},
+			})
+
+			deadline, err := time.Parse(time.RFC3339, mixedOrder.PayDeadline)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(5*time.Minute), 5*time.Second))
+
+			var msg model.OutboxMessage
+			Expect(testDB.Where("body = ?", mixedOrder.OrderID).First(&msg).Error).ToNot(HaveOccurred())
+			Expect(msg.NextAttemptAt).To(BeTemporally("~", deadline, time.Second))
+		})
+
+		It("到达支付期限后超时消息经发件箱投递，消费者关单并释放库存", func() {
+			var before model.Product
+			testDB.Where("id = ?", flashProductID).First(&before)
+
+			expireOrder(mixedOrder.OrderID)
+			testDB.Exec("UPDATE outbox SET next_attempt_at = NOW() WHERE body = ?", mixedOrder.OrderID)
+
+			Eventually(func() model.OrderStatus {
+				return orderStatus(mixedOrder.OrderID)
+			}, 10*time.Second, 200*time.Millisecond).Should(Equal(model.OrderStatusTimeout))
+
+			var after model.Product
+			testDB.Where("id = ?", flashProductID).First(&after)
+			Expect(after.Stock).To(Equal(before.Stock + 1))
+			Expect(after.FrozenStock).To(Equal(before.FrozenStock - 1))
+		})
+
+		It("已过支付期限但尚未关单的订单不能支付", func() {
+			testDB.Exec(`INSERT INTO user_wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())
+				ON CONFLICT (user_id) DO UPDATE SET balance = EXCLUDED.balance`, buyerID, 500.0)
+			created := createFlashOrder(lines(flashProductID, 1))
+			expireOrder(created.OrderID)
+
+			resp := doPayOrder(accessToken, created.OrderID, uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.ErrOrderPayExpired.FullCode()))
+			Expect(orderStatus(created.OrderID)).To(Equal(model.OrderStatusProcessing))
+
+			Expect(doCancelOrder(accessToken, created.OrderID).Code).To(Equal(errno.OK.FullCode()))
+		})
+
+		It("超时消息提前到达时不关单，按支付期限重新调度", func() {
+			created := createFlashOrder(lines(flashProductID, 1))
+
+			Expect(testOrderSvc.HandleOrderTimeout(context.Background(), uuid.MustParse(created.OrderID))).To(Succeed())
+			Expect(orderStatus(created.OrderID)).To(Equal(model.OrderStatusProcessing))
+
+			var msgs []model.OutboxMessage
+			testDB.Where("body = ? AND status = ?", created.OrderID, model.OutboxStatusPending).Find(&msgs)
+			Expect(msgs).To(HaveLen(2))
+
+			Expect(doCancelOrder(accessToken, created.OrderID).Code).To(Equal(errno.OK.FullCode()))
+		})
+	})
+
+	Describe("GET /api/v1/order/:id", func() {
+		var doGetOrder = func(token, orderID string) Response {
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/order/"+orderID, nil)
+			req.Header.Set("Authorization", token)
+			w := httptest.NewRecorder()
+			testRouter.ServeHTTP(w, req)
+
+			var resp Response
+			_ = json.Unmarshal(w.Body.Bytes(), &resp)
+			return resp
+		}
+
+		type historyItem struct {
+			FromStatus *int   `json:"from_status"`
+			ToStatus   int    `json:"to_status"`
+			ActorType  string `json:"actor_type"`
+			ActorID    string `json:"actor_id"`
+		}
+
+		It("返回订单明细、金额与状态流转记录", func() {
+			testDB.Exec(`INSERT INTO user_wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())
+				ON CONFLICT (user_id) DO UPDATE SET balance = EXCLUDED.balance`, buyerID, 500.0)
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(productID, 1),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var created struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &created)
+
+			resp = doPayOrder(accessToken, created.OrderID, uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+			resp = doGetOrder(accessToken, created.OrderID)
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var detail struct {
+				ID            string        `json:"id"`
+				Status        int           `json:"status"`
+				GoodsAmount   float64       `json:"goods_amount"`
+				PayableAmount float64       `json:"payable_amount"`
+				Coupon        interface{}   `json:"coupon"`
+				History       []historyItem `json:"history"`
+			}
+			_ = json.Unmarshal(resp.Data, &detail)
+			Expect(detail.ID).To(Equal(created.OrderID))
+			Expect(detail.Status).To(Equal(int(model.OrderStatusCompleted)))
+			Expect(detail.GoodsAmount).To(BeNumerically("~", 99.99, 0.001))
+			Expect(detail.PayableAmount).To(BeNumerically("~", 99.99, 0.001))
+			Expect(detail.Coupon).To(BeNil())
+
+			Expect(detail.History).To(HaveLen(2))
+			Expect(detail.History[0].FromStatus).To(BeNil())
+			Expect(detail.History[0].ToStatus).To(Equal(int(model.OrderStatusProcessing)))
+			Expect(detail.History[0].ActorType).To(Equal(string(model.OrderActorBuyer)))
+			Expect(detail.History[0].ActorID).To(Equal(buyerID.String()))
+			Expect(*detail.History[1].FromStatus).To(Equal(int(model.OrderStatusProcessing)))
+			Expect(detail.History[1].ToStatus).To(Equal(int(model.OrderStatusCompleted)))
+		})
+
+		It("超时关单记录为系统操作", func() {
+			_, resp := doCreateOrder(accessToken, map[string]interface{}{
+				"items":           lines(secondProductID, 1),
+				"idempotency_key": uuid.New().String(),
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var created struct {
+				OrderID string `json:"order_id"`
+			}
+			_ = json.Unmarshal(resp.Data, &created)
+			expireOrder(created.OrderID)
+			Expect(testOrderSvc.HandleOrderTimeout(context.Background(), uuid.MustParse(created.OrderID))).To(Succeed())
+
+			var history []model.OrderStatusHistory
+			testDB.Where("order_id = ?", created.OrderID).Order("created_at ASC").Find(&history)
+			Expect(history).To(HaveLen(2))
+			Expect(history[1].ToStatus).To(Equal(model.OrderStatusTimeout))
+			Expect(history[1].ActorType).To(Equal(model.OrderActorSystem))
+			Expect(history[1].ActorID).To(BeNil())
+		})
+
+		It("不能查看他人或不存在的订单", func() {
+			resp := doGetOrder(accessToken, uuid.New().String())
+			Expect(resp.Code).To(Equal(errno.ErrOrderNotFound.FullCode()))
+		})
+	})
+})
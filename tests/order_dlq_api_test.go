@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("OrderDeadLetterApi", Ordered, func() {
+	type deadLetter struct {
+		MessageID  string `json:"message_id"`
+		Body       string `json:"body"`
+		RetryCount int    `json:"retry_count"`
+		Reason     string `json:"reason"`
+	}
+
+	var (
+		adminID      uuid.UUID
+		token        string
+		malformedMsg uuid.UUID
+		missingMsg   uuid.UUID
+		missingOrder uuid.UUID
+	)
+
+	var doDLQRequestAs = func(authToken, method, path string, bodyMap map[string]interface{}) Response {
+		raw, _ := json.Marshal(bodyMap)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", authToken)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	var doDLQRequest = func(method, path string, bodyMap map[string]interface{}) Response {
+		return doDLQRequestAs(token, method, path, bodyMap)
+	}
+
+	// listOurs 只返回本用例投递的死信，按 message_id 索引
+	var listOurs = func() map[string]deadLetter {
+		resp := doDLQRequest(http.MethodGet, "/api/v1/admin/order/dead-letters?limit=100", nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			Messages []deadLetter `json:"messages"`
+			Total    int          `json:"total"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+
+		out := map[string]deadLetter{}
+		for _, m := range data.Messages {
+			if m.MessageID == malformedMsg.String() || m.MessageID == missingMsg.String() {
+				out[m.MessageID] = m
+			}
+		}
+		return out
+	}
+
+	var enqueue = func(body string) uuid.UUID {
+		msg := &model.OutboxMessage{
+			Exchange:   testOrderMQ.Exchange,
+			RoutingKey: testOrderMQ.RoutingKey,
+			Body:       body,
+			Status:     model.OutboxStatusPending,
+		}
+		Expect(testDB.Create(msg).Error).ToNot(HaveOccurred())
+		return msg.ID
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		adminID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW())`,
+			adminID, "dlq-admin", "dlq-admin@test.com", string(pwHash), model.UserRoleAdmin)
+
+		_, resp := doLogin("dlq-admin@test.com", "password123")
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		token = data.AccessToken
+		Expect(token).NotTo(BeEmpty())
+
+		missingOrder = uuid.New()
+		malformedMsg = enqueue("not-an-order-id")
+		missingMsg = enqueue(missingOrder.String())
+	})
+
+	AfterAll(func() {
+		testDB.Where("id IN ?", []uuid.UUID{malformedMsg, missingMsg}).Delete(&model.OutboxMessage{})
+		testDB.Exec("DELETE FROM users WHERE id = ?", adminID)
+	})
+
+	It("非 admin 角色的用户无权访问", func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID := uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "dlq-buyer", "dlq-buyer@test.com", string(pwHash))
+		defer testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+
+		_, resp := doLogin("dlq-buyer@test.com", "password123")
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+
+		resp = doDLQRequestAs(data.AccessToken, http.MethodGet, "/api/v1/admin/order/dead-letters", nil)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+		resp = doDLQRequestAs(data.AccessToken, http.MethodPost, "/api/v1/admin/order/dead-letters/replay", map[string]interface{}{})
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+	})
+
+	It("无法解析的消息直接进入死信队列，处理失败的消息按重试队列重试后进入死信队列", func() {
+		var letters map[string]deadLetter
+		Eventually(func() int {
+			letters = listOurs()
+			return len(letters)
+		}, 15*time.Second, 200*time.Millisecond).Should(Equal(2))
+
+		malformed := letters[malformedMsg.String()]
+		Expect(malformed.Body).To(Equal("not-an-order-id"))
+		Expect(malformed.RetryCount).To(Equal(0))
+		Expect(malformed.Reason).To(ContainSubstring("invalid order id"))
+
+		missing := letters[missingMsg.String()]
+		Expect(missing.Body).To(Equal(missingOrder.String()))
+		Expect(missing.RetryCount).To(Equal(testOrderMQ.MaxRetries))
+		Expect(missing.Reason).NotTo(BeEmpty())
+
+		// 查看不会消费死信
+		Expect(listOurs()).To(HaveLen(2))
+	})
+
+	It("按 message_id 重放死信，重试次数清零后重新走完重试流程", func() {
+		resp := doDLQRequest(http.MethodPost, "/api/v1/admin/order/dead-letters/replay", map[string]interface{}{
+			"message_ids": []string{missingMsg.String()},
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			Replayed int `json:"replayed"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(data.Replayed).To(Equal(1))
+
+		// 未命中的死信保留在队列中
+		Expect(listOurs()).To(HaveKey(malformedMsg.String()))
+
+		// 订单仍不存在，重放后再次重试耗尽回到死信队列
+		Eventually(func() map[string]deadLetter {
+			return listOurs()
+		}, 15*time.Second, 200*time.Millisecond).Should(HaveKey(missingMsg.String()))
+		Expect(listOurs()[missingMsg.String()].RetryCount).To(Equal(testOrderMQ.MaxRetries))
+	})
+})
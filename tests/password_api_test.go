@@ -0,0 +1,232 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"e-commerce/pkg/errno"
+	"e-commerce/pkg/mailer"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// lastMailToken 从文件邮件中读取最近一封发往 to、主题为 subject 的邮件链接中的 token 参数
+func lastMailToken(to, subject string) string {
+	f, err := os.Open(testMailPath)
+	if os.IsNotExist(err) {
+		return ""
+	}
+	Expect(err).ToNot(HaveOccurred())
+	defer f.Close()
+
+	var token string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var msg mailer.Message
+		Expect(json.Unmarshal(scanner.Bytes(), &msg)).To(Succeed())
+		if msg.To != to || msg.Subject != subject {
+			continue
+		}
+		link, err := url.Parse(mailLinkPattern.FindString(msg.Body))
+		Expect(err).ToNot(HaveOccurred())
+		token = link.Query().Get("token")
+	}
+	return token
+}
+
+var mailLinkPattern = regexp.MustCompile(`https?://\S+`)
+
+var _ = Describe("PasswordApi", Ordered, func() {
+	const (
+		email = "password-flow@test.com"
+		// 独立 IP，避免与其他用例共享登录限流
+		clientIP = "198.51.100.19"
+	)
+	var (
+		userID   uuid.UUID
+		password = "password123"
+	)
+
+	// login 仅用于断言登录失败，成功登录使用 loginAs
+	login := func(pw string) Response {
+		_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+			"email":    email,
+			"password": pw,
+		})
+		return resp
+	}
+
+	userSessions := func() int64 {
+		return testRedis.ZCard(context.Background(), fmt.Sprintf("ident:u_sessions:%s", userID)).Val()
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "password-flow", email, string(pwHash))
+	})
+
+	AfterAll(func() {
+		ctx := context.Background()
+		testRedis.Del(ctx, fmt.Sprintf("ident:u_sessions:%s", userID), fmt.Sprintf("user:pwd_reset_user:%s", userID))
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	Describe("POST /api/v1/user/password/change", func() {
+		var current, other LoginData
+
+		BeforeAll(func() {
+			current = loginAs(clientIP, email, password)
+			other = loginAs(clientIP, email, password)
+		})
+
+		It("原密码错误或新旧密码相同时拒绝修改", func() {
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, map[string]string{
+				"old_password": "wrong-password",
+				"new_password": "new-password-1",
+			})
+			Expect(resp.Code).To(Equal(errno.ErrUserPasswordIncorrect.FullCode()))
+
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, map[string]string{
+				"old_password": password,
+				"new_password": password,
+			})
+			Expect(resp.Code).To(Equal(errno.ErrUserPasswordUnchanged.FullCode()))
+
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, map[string]string{
+				"old_password": password,
+				"new_password": "short",
+			})
+			Expect(resp.Code).To(Equal(errno.ErrInvalidParam.FullCode()))
+		})
+
+		It("修改成功后注销其他会话，当前会话保留", func() {
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, map[string]string{
+				"old_password": password,
+				"new_password": "new-password-1",
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			var data map[string]int
+			_ = json.Unmarshal(resp.Data, &data)
+			Expect(data["revoked_sessions"]).To(Equal(1))
+			password = "new-password-1"
+
+			_, resp = doJSONRequest(clientIP, http.MethodGet, "/api/v1/auth/sessions", current.AccessToken, nil)
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			_, resp = doJSONRequest(clientIP, http.MethodGet, "/api/v1/auth/sessions", other.AccessToken, nil)
+			Expect(resp.Code).To(Equal(errno.ErrAuthInvalidToken.FullCode()))
+
+			resp = login("password123")
+			Expect(resp.Code).To(Equal(errno.ErrUserNotFound.FullCode()))
+			loginAs(clientIP, email, password)
+		})
+
+		It("原密码错误计入账号登录锁定，锁定期内正确的原密码同样被拒绝", func() {
+			origGuard := testAuthConfig.LoginGuard
+			testAuthConfig.LoginGuard.MaxFailures = 2
+			clearLock := func() {
+				ctx := context.Background()
+				for _, prefix := range []string{"ident:login_fail", "ident:login_lock", "ident:login_lockouts"} {
+					testRedis.Del(ctx, fmt.Sprintf("%s:email:%s", prefix, email))
+				}
+			}
+			defer func() {
+				testAuthConfig.LoginGuard = origGuard
+				clearLock()
+			}()
+			clearLock()
+
+			body := map[string]string{"old_password": "wrong-password", "new_password": "new-password-9"}
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, body)
+			Expect(resp.Code).To(Equal(errno.ErrUserPasswordIncorrect.FullCode()))
+			w, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, body)
+			Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+			Expect(w.Header().Get("Retry-After")).NotTo(BeEmpty())
+
+			body["old_password"] = password
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, body)
+			Expect(resp.Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+			Expect(login(password).Code).To(Equal(errno.ErrAuthLoginLocked.FullCode()))
+		})
+	})
+
+	Describe("POST /api/v1/user/password/reset", func() {
+		It("未注册的邮箱同样返回成功且不发送邮件", func() {
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/forgot", "", map[string]string{
+				"email": "nobody-reset@test.com",
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			Expect(lastMailToken("nobody-reset@test.com", "重置密码")).To(BeEmpty())
+		})
+
+		It("重新申请后旧令牌失效", func() {
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/forgot", "", map[string]string{"email": email})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			first := lastMailToken(email, "重置密码")
+			Expect(first).NotTo(BeEmpty())
+
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/forgot", "", map[string]string{"email": email})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			Expect(lastMailToken(email, "重置密码")).NotTo(Equal(first))
+
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/reset", "", map[string]string{
+				"token":        first,
+				"new_password": "reset-password-1",
+			})
+			Expect(resp.Code).To(Equal(errno.ErrUserResetTokenInvalid.FullCode()))
+		})
+
+		It("重置成功后注销全部会话，令牌不可重复使用", func() {
+			loginAs(clientIP, email, password)
+			Expect(userSessions()).To(BeNumerically(">", 0))
+
+			token := lastMailToken(email, "重置密码")
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/reset", "", map[string]string{
+				"token":        token,
+				"new_password": "reset-password-1",
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			Expect(userSessions()).To(Equal(int64(0)))
+
+			resp = login(password)
+			Expect(resp.Code).To(Equal(errno.ErrUserNotFound.FullCode()))
+			password = "reset-password-1"
+			loginAs(clientIP, email, password)
+
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/reset", "", map[string]string{
+				"token":        token,
+				"new_password": "reset-password-2",
+			})
+			Expect(resp.Code).To(Equal(errno.ErrUserResetTokenInvalid.FullCode()))
+		})
+
+		It("修改密码后未使用的重置令牌失效", func() {
+			_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/forgot", "", map[string]string{"email": email})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			token := lastMailToken(email, "重置密码")
+
+			current := loginAs(clientIP, email, password)
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/change", current.AccessToken, map[string]string{
+				"old_password": password,
+				"new_password": "new-password-2",
+			})
+			Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+			password = "new-password-2"
+
+			_, resp = doJSONRequest(clientIP, http.MethodPost, "/api/v1/user/password/reset", "", map[string]string{
+				"token":        token,
+				"new_password": "reset-password-3",
+			})
+			Expect(resp.Code).To(Equal(errno.ErrUserResetTokenInvalid.FullCode()))
+		})
+	})
+})
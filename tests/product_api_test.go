@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"e-commerce/internal/model"
 	"e-commerce/pkg/errno"
 	"net/http"
 	"net/http/httptest"
@@ -26,6 +27,7 @@ var _ = Describe("ProductApi", Ordered, func() {
 		regReq, _ := http.NewRequest(http.MethodPost, "/api/v1/user/register", bytes.NewBuffer(regBody))
 		regReq.Header.Set("Content-Type", "application/json")
 		testRouter.ServeHTTP(httptest.NewRecorder(), regReq)
+		testDB.Exec("UPDATE users SET role = ? WHERE email = ?", model.UserRoleSeller, prodUser+"@test.com")
 		_, resp := doLogin(prodUser+"@test.com", "test123456")
 		var loginData struct {
 			AccessToken string `json:"access_token"`
@@ -87,6 +89,8 @@ var _ = Describe("ProductApi", Ordered, func() {
 			"price":       199.0,
 			"status":      "active",
 			"stock":       50,
+			// 秒杀商品：5 分钟支付时限
+			"pay_timeout_seconds": 300,
 		})
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/product/create", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
@@ -127,18 +131,26 @@ var _ = Describe("ProductApi", Ordered, func() {
 		var detailResp struct {
 			Code string `json:"code"`
 			Data struct {
-				Name string `json:"name"`
+				Name           string `json:"name"`
+				AvailableStock int    `json:"available_stock"`
+				ReservedStock  int    `json:"reserved_stock"`
+				PayTimeout     int    `json:"pay_timeout_seconds"`
 			} `json:"data"`
 		}
 		json.Unmarshal(dw.Body.Bytes(), &detailResp)
 		Expect(detailResp.Code).To(Equal(errno.OK.FullCode()))
 		Expect(detailResp.Data.Name).To(Equal("详情测试商品"))
+		Expect(detailResp.Data.AvailableStock).To(Equal(50))
+		Expect(detailResp.Data.ReservedStock).To(Equal(0))
+		Expect(detailResp.Data.PayTimeout).To(Equal(300))
 	})
 
 	It("更新商品属性成功", func() {
 		body, _ := json.Marshal(map[string]interface{}{
 			"name":  "更新后的商品名",
 			"price": 299.0,
+			// 0 表示恢复使用全局支付时限
+			"pay_timeout_seconds": 0,
 		})
 		req, _ := http.NewRequest(http.MethodPatch, "/api/v1/product/"+productID, bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
@@ -149,6 +161,11 @@ var _ = Describe("ProductApi", Ordered, func() {
 		var resp Response
 		json.Unmarshal(w.Body.Bytes(), &resp)
 		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		var p model.Product
+		testDB.Where("id = ?", productID).First(&p)
+		Expect(p.Name).To(Equal("更新后的商品名"))
+		Expect(p.PayTimeoutSeconds).To(Equal(0))
 	})
 
 	It("更新商品状态成功", func() {
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("ProfileApi", Ordered, func() {
+	const (
+		email = "profile-flow@test.com"
+		// 独立 IP，避免与其他用例共享登录限流
+		clientIP = "198.51.100.21"
+	)
+	type profileData struct {
+		UserName      string  `json:"user_name"`
+		Email         string  `json:"email"`
+		EmailVerified bool    `json:"email_verified"`
+		Balance       float64 `json:"balance"`
+		UnusedCoupons int64   `json:"unused_coupons"`
+		OpenOrders    int64   `json:"open_orders"`
+	}
+	var (
+		userID, otherID uuid.UUID
+		templateID      uuid.UUID
+		accessToken     string
+	)
+
+	// doRequest 在 doJSONRequest 基础上解析资料页数据
+	doRequest := func(method, path, token string, body interface{}) (Response, profileData) {
+		_, resp := doJSONRequest(clientIP, method, path, token, body)
+		var data profileData
+		_ = json.Unmarshal(resp.Data, &data)
+		return resp, data
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID, otherID = uuid.New(), uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, email_verified_at, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW(), NOW())`,
+			userID, "profile-flow", email, string(pwHash))
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			otherID, "profile-other", "profile-other@test.com", string(pwHash))
+		testDB.Exec(`INSERT INTO user_wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())`, userID, 88.5)
+
+		// 未使用、已使用、已过期各一张，只有第一张计入
+		tpl := &model.CouponTemplate{
+			Name: "资料页测试券", Type: model.CouponTypeFixed, DiscountValue: 5, TotalQty: 10,
+			PerUserLimit: 10, StartTime: time.Now().Add(-time.Hour), EndTime: time.Now().Add(time.Hour),
+		}
+		Expect(testDB.Create(tpl).Error).ToNot(HaveOccurred())
+		templateID = tpl.ID
+		for _, uc := range []*model.UserCoupon{
+			{UserID: userID, TemplateID: templateID, Status: model.UserCouponStatusUnused, ExpireTime: time.Now().Add(time.Hour)},
+			{UserID: userID, TemplateID: templateID, Status: model.UserCouponStatusUsed, ExpireTime: time.Now().Add(time.Hour)},
+			{UserID: userID, TemplateID: templateID, Status: model.UserCouponStatusUnused, ExpireTime: time.Now().Add(-time.Hour)},
+		} {
+			Expect(testDB.Create(uc).Error).ToNot(HaveOccurred())
+		}
+
+		// 待支付、退款中计入未结束订单，已完成不计入
+		for _, status := range []model.OrderStatus{model.OrderStatusProcessing, model.OrderStatusRefunding, model.OrderStatusCompleted} {
+			Expect(testDB.Create(&model.Order{
+				UserID:         userID,
+				TotalAmount:    10,
+				Status:         status,
+				IdempotencyKey: uuid.NewString(),
+				PayDeadline:    time.Now().Add(time.Hour),
+			}).Error).ToNot(HaveOccurred())
+		}
+
+		accessToken = loginAs(clientIP, email, "password123").AccessToken
+	})
+
+	AfterAll(func() {
+		ctx := context.Background()
+		testRedis.Del(ctx,
+			fmt.Sprintf("ident:u_sessions:%s", userID),
+			fmt.Sprintf("user:email_verify_user:%s", userID),
+			fmt.Sprintf("user:email_verify_cooldown:%s", userID),
+		)
+		testDB.Exec("DELETE FROM orders WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM user_coupons WHERE user_id = ?", userID)
+		testDB.Unscoped().Delete(&model.CouponTemplate{}, "id = ?", templateID)
+		testDB.Exec("DELETE FROM user_wallets WHERE user_id = ?", userID)
+		testDB.Exec("DELETE FROM users WHERE id IN ?", []uuid.UUID{userID, otherID})
+	})
+
+	It("返回账号信息、余额、可用优惠券和未结束订单数量", func() {
+		resp, data := doRequest(http.MethodGet, "/api/v1/user/me", accessToken, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(data.UserName).To(Equal("profile-flow"))
+		Expect(data.Email).To(Equal(email))
+		Expect(data.EmailVerified).To(BeTrue())
+		Expect(data.Balance).To(Equal(88.5))
+		Expect(data.UnusedCoupons).To(Equal(int64(1)))
+		Expect(data.OpenOrders).To(Equal(int64(2)))
+	})
+
+	It("用户名或邮箱已被占用时拒绝修改", func() {
+		resp, _ := doRequest(http.MethodPatch, "/api/v1/user/me", accessToken, map[string]string{"user_name": "profile-other"})
+		Expect(resp.Code).To(Equal(errno.ErrUserNameExisted.FullCode()))
+
+		resp, _ = doRequest(http.MethodPatch, "/api/v1/user/me", accessToken, map[string]string{
+			"email":    "profile-other@test.com",
+			"password": "password123",
+		})
+		Expect(resp.Code).To(Equal(errno.ErrUserEmailExisted.FullCode()))
+	})
+
+	It("修改用户名", func() {
+		resp, data := doRequest(http.MethodPatch, "/api/v1/user/me", accessToken, map[string]string{"user_name": "profile-renamed"})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(data.UserName).To(Equal("profile-renamed"))
+		Expect(data.EmailVerified).To(BeTrue())
+	})
+
+	It("修改邮箱需要密码，修改后需重新验证", func() {
+		const newEmail = "profile-new@test.com"
+		resp, _ := doRequest(http.MethodPatch, "/api/v1/user/me", accessToken, map[string]string{"email": newEmail})
+		Expect(resp.Code).To(Equal(errno.ErrInvalidParam.FullCode()))
+		resp, _ = doRequest(http.MethodPatch, "/api/v1/user/me", accessToken, map[string]string{
+			"email":    newEmail,
+			"password": "wrong-password",
+		})
+		Expect(resp.Code).To(Equal(errno.ErrUserPasswordIncorrect.FullCode()))
+
+		resp, data := doRequest(http.MethodPatch, "/api/v1/user/me", accessToken, map[string]string{
+			"email":    newEmail,
+			"password": "password123",
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(data.Email).To(Equal(newEmail))
+		Expect(data.EmailVerified).To(BeFalse())
+
+		token := lastMailToken(newEmail, "验证邮箱")
+		Expect(token).NotTo(BeEmpty())
+		resp, _ = doRequest(http.MethodGet, "/api/v1/user/verify?token="+token, "", nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		_, data = doRequest(http.MethodGet, "/api/v1/user/me", accessToken, nil)
+		Expect(data.EmailVerified).To(BeTrue())
+	})
+})
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("RbacApi", Ordered, func() {
+	var (
+		adminID, buyerID                     uuid.UUID
+		adminToken, buyerToken, buyerRefresh string
+		tplBody                              map[string]interface{}
+	)
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		adminID = uuid.New()
+		buyerID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, role, created_at, updated_at) VALUES (?, ?, ?, ?, ?, NOW(), NOW())`,
+			adminID, "rbac-admin", "rbac-admin@test.com", string(pwHash), model.UserRoleAdmin)
+		// 不指定 role，验证默认角色为 buyer
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			buyerID, "rbac-buyer", "rbac-buyer@test.com", string(pwHash))
+
+		adminToken = loginAs("", "rbac-admin@test.com", "password123").AccessToken
+		buyerLogin := loginAs("", "rbac-buyer@test.com", "password123")
+		buyerToken = buyerLogin.AccessToken
+		buyerRefresh = buyerLogin.RefreshToken
+
+		tplBody = map[string]interface{}{
+			"name":           "RBAC 测试券",
+			"type":           "fixed_amount",
+			"discount_value": 5,
+			"total_qty":      10,
+			"start_time":     time.Now().Add(-time.Hour).Format(time.RFC3339),
+			"end_time":       time.Now().Add(24 * time.Hour).Format(time.RFC3339),
+		}
+	})
+
+	AfterAll(func() {
+		testDB.Exec("DELETE FROM products WHERE publisher = ?", buyerID)
+		testDB.Where("name = ?", "RBAC 测试券").Delete(&model.CouponTemplate{})
+		testDB.Exec("DELETE FROM users WHERE id IN ?", []uuid.UUID{adminID, buyerID})
+	})
+
+	It("buyer 无法创建优惠券模板和发券", func() {
+		_, resp := doJSONRequest("", http.MethodPost, "/api/v1/coupon/template", buyerToken, tplBody)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+
+		grantBody := map[string]string{
+			"template_id": uuid.New().String(),
+		}
+		_, resp = doJSONRequest("", http.MethodPost, "/api/v1/coupon/grant", buyerToken, grantBody)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+	})
+
+	It("admin 可以创建优惠券模板", func() {
+		_, resp := doJSONRequest("", http.MethodPost, "/api/v1/coupon/template", adminToken, tplBody)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+	})
+
+	It("buyer 无法创建商品和访问管理接口", func() {
+		body := map[string]interface{}{
+			"name":        "RBAC 商品",
+			"description": "RBAC 测试",
+			"price":       10,
+			"status":      "active",
+			"stock":       1,
+		}
+		_, resp := doJSONRequest("", http.MethodPost, "/api/v1/product/create", buyerToken, body)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+
+		_, resp = doJSONRequest("", http.MethodGet, "/api/v1/admin/order/dead-letters", buyerToken, nil)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+
+		roleBody := map[string]string{"role": "admin"}
+		_, resp = doJSONRequest("", http.MethodPatch, "/api/v1/admin/users/"+buyerID.String()+"/role", buyerToken, roleBody)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+	})
+
+	It("修改角色参数校验", func() {
+		roleBody := map[string]string{"role": "root"}
+		_, resp := doJSONRequest("", http.MethodPatch, "/api/v1/admin/users/"+buyerID.String()+"/role", adminToken, roleBody)
+		Expect(resp.Code).To(Equal(errno.ErrInvalidParam.FullCode()))
+
+		roleBody = map[string]string{"role": "seller"}
+		_, resp = doJSONRequest("", http.MethodPatch, "/api/v1/admin/users/"+uuid.New().String()+"/role", adminToken, roleBody)
+		Expect(resp.Code).To(Equal(errno.ErrNotFoundRecord.FullCode()))
+	})
+
+	It("admin 将 buyer 升级为 seller，刷新令牌后可创建商品", func() {
+		roleBody := map[string]string{"role": "seller"}
+		_, resp := doJSONRequest("", http.MethodPatch, "/api/v1/admin/users/"+buyerID.String()+"/role", adminToken, roleBody)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		var u model.User
+		Expect(testDB.First(&u, "id = ?", buyerID).Error).ToNot(HaveOccurred())
+		Expect(u.Role).To(Equal(model.UserRoleSeller))
+
+		// 已签发的 AccessToken 仍携带旧角色
+		body := map[string]interface{}{
+			"name":        "RBAC 商品",
+			"description": "RBAC 测试",
+			"price":       10,
+			"status":      "active",
+			"stock":       1,
+		}
+		_, resp = doJSONRequest("", http.MethodPost, "/api/v1/product/create", buyerToken, body)
+		Expect(resp.Code).To(Equal(errno.ErrAuthNotPermission.FullCode()))
+
+		_, resp = doJSONRequest("", http.MethodPost, "/api/v1/auth/fetch-access-token", buyerRefresh, nil)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data map[string]string
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(data["access_token"]).NotTo(BeEmpty())
+
+		_, resp = doJSONRequest("", http.MethodPost, "/api/v1/product/create", data["access_token"], body)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+	})
+})
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"bytes"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("RefundApi", Ordered, func() {
+	var (
+		publisherID    uuid.UUID
+		buyerID        uuid.UUID
+		productID      uuid.UUID
+		orderID        string
+		buyerToken     string
+		publisherToken string
+	)
+
+	var doRefundRequest = func(token, method, path string, bodyMap map[string]interface{}) Response {
+		raw, _ := json.Marshal(bodyMap)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	var createRefund = func(quantity int) Response {
+		return doRefundRequest(buyerToken, http.MethodPost, "/api/v1/refund/create", map[string]interface{}{
+			"order_id":   orderID,
+			"product_id": productID.String(),
+			"quantity":   quantity,
+			"reason":     "不想要了",
+		})
+	}
+
+	var refundIDOf = func(resp Response) string {
+		var data struct {
+			RefundID string `json:"refund_id"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		return data.RefundID
+	}
+
+	var login = func(email string) string {
+		_, resp := doLogin(email, "password123")
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		return data.AccessToken
+	}
+
+	var orderStatus = func() model.OrderStatus {
+		var o model.Order
+		testDB.Where("id = ?", orderID).First(&o)
+		return o.Status
+	}
+
+	var balance = func() float64 {
+		var w model.UserWallet
+		testDB.Where("user_id = ?", buyerID).First(&w)
+		return w.Balance
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+
+		publisherID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			publisherID, "refund-publisher", "refund-pub@test.com", string(pwHash))
+
+		buyerID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			buyerID, "refund-buyer", "refund-buyer@test.com", string(pwHash))
+
+		productID = uuid.New()
+		testDB.Exec(`INSERT INTO products (id, publisher, name, description, price, stock, frozen_stock, status, version, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW(), NOW())`,
+			productID, publisherID, "Refund Item", "desc", 10.0, 10, 0, "active", 1)
+
+		testDB.Exec(`INSERT INTO user_wallets (user_id, balance, created_at, updated_at) VALUES (?, ?, NOW(), NOW())`, buyerID, 100.0)
+
+		buyerToken = login("refund-buyer@test.com")
+		publisherToken = login("refund-pub@test.com")
+		Expect(buyerToken).NotTo(BeEmpty())
+		Expect(publisherToken).NotTo(BeEmpty())
+
+		resp := doRefundRequest(buyerToken, http.MethodPost, "/api/v1/order/create", map[string]interface{}{
+			"items":           []map[string]interface{}{{"product_id": productID.String(), "quantity": 3}},
+			"idempotency_key": uuid.New().String(),
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var created struct {
+			OrderID string `json:"order_id"`
+		}
+		_ = json.Unmarshal(resp.Data, &created)
+		orderID = created.OrderID
+	})
+
+	AfterAll(func() {
+		testDB.Exec("DELETE FROM refund_requests WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM wallet_logs WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM user_wallets WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM orders WHERE user_id = ?", buyerID)
+		testDB.Exec("DELETE FROM stock_change_logs WHERE product_id = ?", productID)
+		testDB.Exec("DELETE FROM products WHERE id = ?", productID)
+		testDB.Exec("DELETE FROM users WHERE id IN (?, ?)", publisherID, buyerID)
+	})
+
+	It("未支付的订单不能申请退款", func() {
+		resp := createRefund(1)
+		Expect(resp.Code).To(Equal(errno.ErrOrderStatusInvalid.FullCode()))
+
+		resp = doRefundRequest(buyerToken, http.MethodPost, "/api/v1/order/"+orderID+"/pay", map[string]interface{}{
+			"idempotency_key": uuid.New().String(),
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(balance()).To(BeNumerically("~", 70.0, 0.001))
+	})
+
+	It("部分退款审核通过：退款入账、商品入库、订单回到已完成", func() {
+		resp := createRefund(1)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		refundID := refundIDOf(resp)
+		Expect(orderStatus()).To(Equal(model.OrderStatusRefunding))
+
+		By("待审核数量计入占用，不能超额申请")
+		resp = createRefund(3)
+		Expect(resp.Code).To(Equal(errno.ErrRefundQuantityExceeded.FullCode()))
+
+		By("非商品发布者不能审核")
+		resp = doRefundRequest(buyerToken, http.MethodPost, "/api/v1/refund/"+refundID+"/approve", map[string]interface{}{})
+		Expect(resp.Code).To(Equal(errno.ErrRefundNotFound.FullCode()))
+
+		var before model.Product
+		testDB.Where("id = ?", productID).First(&before)
+
+		resp = doRefundRequest(publisherToken, http.MethodPost, "/api/v1/refund/"+refundID+"/approve", map[string]interface{}{})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		Expect(balance()).To(BeNumerically("~", 80.0, 0.001))
+		Expect(orderStatus()).To(Equal(model.OrderStatusCompleted))
+
+		var after model.Product
+		testDB.Where("id = ?", productID).First(&after)
+		Expect(after.Stock).To(Equal(before.Stock + 1))
+
+		var log model.WalletLog
+		Expect(testDB.Where("idempotency_key = ?", "refund:"+refundID).First(&log).Error).ToNot(HaveOccurred())
+		Expect(log.Type).To(Equal(model.WalletLogTypeRefund))
+
+		var count int64
+		testDB.Model(&model.StockChangeLog{}).
+			Where("product_id = ? AND reason = ?", productID, model.StockChangeRefund).
+			Count(&count)
+		Expect(count).To(Equal(int64(1)))
+
+		By("已处理的申请不能重复审核")
+		resp = doRefundRequest(publisherToken, http.MethodPost, "/api/v1/refund/"+refundID+"/approve", map[string]interface{}{})
+		Expect(resp.Code).To(Equal(errno.ErrRefundStatusInvalid.FullCode()))
+	})
+
+	It("拒绝退款后订单回到已完成，余额不变", func() {
+		resp := createRefund(2)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		refundID := refundIDOf(resp)
+
+		resp = doRefundRequest(publisherToken, http.MethodPost, "/api/v1/refund/"+refundID+"/reject", map[string]interface{}{
+			"note": "商品已拆封",
+		})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(orderStatus()).To(Equal(model.OrderStatusCompleted))
+		Expect(balance()).To(BeNumerically("~", 80.0, 0.001))
+	})
+
+	It("剩余商品全部退款后订单变为已退款", func() {
+		resp := createRefund(2)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		refundID := refundIDOf(resp)
+
+		resp = doRefundRequest(publisherToken, http.MethodPost, "/api/v1/refund/"+refundID+"/approve", map[string]interface{}{})
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(orderStatus()).To(Equal(model.OrderStatusRefunded))
+		Expect(balance()).To(BeNumerically("~", 100.0, 0.001))
+
+		resp = createRefund(1)
+		Expect(resp.Code).To(Equal(errno.ErrOrderStatusInvalid.FullCode()))
+	})
+
+	It("发布者可以查看待自己审核的申请", func() {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/refund/list?role=seller&page_num=1&page_size=10", nil)
+		req.Header.Set("Authorization", publisherToken)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			Total int64 `json:"total"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(data.Total).To(Equal(int64(3)))
+	})
+})
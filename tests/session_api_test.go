@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	appconfig "e-commerce/internal/config"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("SessionApi", Ordered, func() {
+	type sessionItem struct {
+		SessionID       string `json:"session_id"`
+		CreatedAt       string `json:"created_at"`
+		LastRefreshedAt string `json:"last_refreshed_at"`
+		IP              string `json:"ip"`
+		UserAgent       string `json:"user_agent"`
+		Current         bool   `json:"current"`
+	}
+
+	var (
+		userID uuid.UUID
+		// 三台设备的登录令牌，phone 为发起管理操作的当前设备
+		phone, laptop, tablet LoginData
+	)
+
+	loginWithUA := func(ua string) LoginData {
+		body, _ := json.Marshal(map[string]string{
+			"email":    "session-user@test.com",
+			"password": "password123",
+		})
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		return data
+	}
+
+	doRequest := func(method, path, token string) Response {
+		req, _ := http.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp
+	}
+
+	listSessions := func(token string) []sessionItem {
+		resp := doRequest(http.MethodGet, "/api/v1/auth/sessions", token)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			Sessions []sessionItem `json:"sessions"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		return data.Sessions
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "session-user", "session-user@test.com", string(pwHash))
+
+		phone = loginWithUA("phone-agent")
+		laptop = loginWithUA("laptop-agent")
+		tablet = loginWithUA("tablet-agent")
+	})
+
+	AfterAll(func() {
+		ctx := context.Background()
+		for _, sid := range testRedis.ZRange(ctx, fmt.Sprintf("ident:u_sessions:%s", userID), 0, -1).Val() {
+			testRedis.Del(ctx, fmt.Sprintf("ident:sess:%s", sid))
+		}
+		testRedis.Del(ctx, fmt.Sprintf("ident:u_sessions:%s", userID))
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("列出所有登录设备及元数据", func() {
+		sessions := listSessions(phone.AccessToken)
+		Expect(sessions).To(HaveLen(3))
+
+		agents := make([]string, 0, len(sessions))
+		currentCount := 0
+		for _, s := range sessions {
+			agents = append(agents, s.UserAgent)
+			Expect(s.CreatedAt).NotTo(BeEmpty())
+			Expect(s.LastRefreshedAt).NotTo(BeEmpty())
+			Expect(s.IP).NotTo(BeEmpty())
+			if s.Current {
+				currentCount++
+				Expect(s.UserAgent).To(Equal("phone-agent"))
+			}
+		}
+		Expect(agents).To(ConsistOf("phone-agent", "laptop-agent", "tablet-agent"))
+		Expect(currentCount).To(Equal(1))
+	})
+
+	It("注销指定设备后该设备令牌失效", func() {
+		var laptopSID string
+		for _, s := range listSessions(phone.AccessToken) {
+			if s.UserAgent == "laptop-agent" {
+				laptopSID = s.SessionID
+			}
+		}
+		Expect(laptopSID).NotTo(BeEmpty())
+
+		resp := doRequest(http.MethodDelete, "/api/v1/auth/sessions/"+laptopSID, phone.AccessToken)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		resp = doRequest(http.MethodGet, "/api/v1/auth/sessions", laptop.AccessToken)
+		Expect(resp.Code).NotTo(Equal(errno.OK.FullCode()))
+		Expect(listSessions(phone.AccessToken)).To(HaveLen(2))
+	})
+
+	It("无法注销不存在或不属于自己的会话", func() {
+		resp := doRequest(http.MethodDelete, "/api/v1/auth/sessions/not-a-session", phone.AccessToken)
+		Expect(resp.Code).To(Equal(errno.ErrAuthSessionNotFound.FullCode()))
+	})
+
+	It("退出其他所有设备，当前设备保持登录", func() {
+		resp := doRequest(http.MethodDelete, "/api/v1/auth/sessions", phone.AccessToken)
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var data struct {
+			Revoked int `json:"revoked"`
+		}
+		_ = json.Unmarshal(resp.Data, &data)
+		Expect(data.Revoked).To(Equal(1))
+
+		resp = doRequest(http.MethodGet, "/api/v1/auth/sessions", tablet.AccessToken)
+		Expect(resp.Code).NotTo(Equal(errno.OK.FullCode()))
+
+		sessions := listSessions(phone.AccessToken)
+		Expect(sessions).To(HaveLen(1))
+		Expect(sessions[0].Current).To(BeTrue())
+	})
+})
+
+var _ = Describe("SessionLimit", Ordered, func() {
+	var (
+		userID     uuid.UUID
+		origMax    int
+		origPolicy string
+	)
+
+	login := func() (LoginData, Response) {
+		_, resp := doLogin("session-limit@test.com", "password123")
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		return data, resp
+	}
+
+	checkToken := func(token string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/auth/sessions", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Code
+	}
+
+	clearSessions := func() {
+		ctx := context.Background()
+		key := fmt.Sprintf("ident:u_sessions:%s", userID)
+		for _, sid := range testRedis.ZRange(ctx, key, 0, -1).Val() {
+			testRedis.Del(ctx, fmt.Sprintf("ident:sess:%s", sid))
+		}
+		testRedis.Del(ctx, key)
+	}
+
+	BeforeAll(func() {
+		origMax, origPolicy = testAuthConfig.MaxSessions, testAuthConfig.SessionLimitPolicy
+
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "session-limit", "session-limit@test.com", string(pwHash))
+	})
+
+	AfterAll(func() {
+		testAuthConfig.MaxSessions, testAuthConfig.SessionLimitPolicy = origMax, origPolicy
+		clearSessions()
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("evict_oldest：超出上限时踢下最早登录的会话", func() {
+		testAuthConfig.MaxSessions, testAuthConfig.SessionLimitPolicy = 2, appconfig.SessionPolicyEvictOldest
+		clearSessions()
+
+		first, _ := login()
+		second, _ := login()
+		third, resp := login()
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+
+		Expect(testRedis.ZCard(context.Background(), fmt.Sprintf("ident:u_sessions:%s", userID)).Val()).To(Equal(int64(2)))
+		Expect(checkToken(first.AccessToken)).To(Equal(errno.ErrAuthSessionRevoked.FullCode()))
+		Expect(checkToken(second.AccessToken)).To(Equal(errno.OK.FullCode()))
+		Expect(checkToken(third.AccessToken)).To(Equal(errno.OK.FullCode()))
+
+		// 被踢下的会话也无法用刷新令牌续期
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/fetch-access-token", nil)
+		req.Header.Set("Authorization", first.RefreshToken)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+		var refreshResp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &refreshResp)
+		Expect(refreshResp.Code).To(Equal(errno.ErrAuthSessionRevoked.FullCode()))
+	})
+
+	It("reject：达到上限时拒绝新的登录，退出后可再次登录", func() {
+		testAuthConfig.MaxSessions, testAuthConfig.SessionLimitPolicy = 2, appconfig.SessionPolicyReject
+		clearSessions()
+
+		first, _ := login()
+		second, _ := login()
+		_, resp := login()
+		Expect(resp.Code).To(Equal(errno.ErrAuthSessionLimit.FullCode()))
+		Expect(checkToken(first.AccessToken)).To(Equal(errno.OK.FullCode()))
+		Expect(checkToken(second.AccessToken)).To(Equal(errno.OK.FullCode()))
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+		req.Header.Set("Authorization", first.AccessToken)
+		testRouter.ServeHTTP(httptest.NewRecorder(), req)
+
+		_, resp = login()
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+	})
+
+	It("登录时将旧版本的会话集合迁移到有序集合", func() {
+		testAuthConfig.MaxSessions, testAuthConfig.SessionLimitPolicy = 0, appconfig.SessionPolicyEvictOldest
+		clearSessions()
+
+		legacy, _ := login()
+		ctx := context.Background()
+		key := fmt.Sprintf("ident:u_sessions:%s", userID)
+		legacySID := testRedis.ZRange(ctx, key, 0, -1).Val()[0]
+		testRedis.Del(ctx, key)
+		legacyKey := fmt.Sprintf("ident:u_sess:%s", userID)
+		testRedis.SAdd(ctx, legacyKey, legacySID)
+
+		_, resp := login()
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(testRedis.Exists(ctx, legacyKey).Val()).To(Equal(int64(0)))
+		Expect(testRedis.ZScore(ctx, key, legacySID).Err()).ToNot(HaveOccurred())
+		Expect(testRedis.ZCard(ctx, key).Val()).To(Equal(int64(2)))
+		Expect(checkToken(legacy.AccessToken)).To(Equal(errno.OK.FullCode()))
+	})
+})
@@ -1,28 +1,39 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"e-commerce/internal/address"
 	"e-commerce/internal/app"
 	"e-commerce/internal/auth"
+	"e-commerce/internal/cart"
+	appconfig "e-commerce/internal/config"
 	"e-commerce/internal/coupon"
 	"e-commerce/internal/model"
 	"e-commerce/internal/order"
+	"e-commerce/internal/outbox"
 	"e-commerce/internal/product"
+	"e-commerce/internal/refund"
 	"e-commerce/internal/user"
 	"e-commerce/internal/wallet"
 	"e-commerce/pkg/clog"
 	"e-commerce/pkg/dbconn"
+	"e-commerce/pkg/errno"
+	"e-commerce/pkg/mailer"
 	"e-commerce/pkg/mq"
 	"e-commerce/pkg/redis"
 	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	goredis "github.com/go-redis/redis/v8"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 	redis2 "github.com/testcontainers/testcontainers-go/modules/redis"
@@ -30,6 +41,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	amqp "github.com/rabbitmq/amqp091-go"
 	"go.opentelemetry.io/otel"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -47,15 +59,54 @@ type Response struct {
 	Data    json.RawMessage `json:"data"`
 }
 
+// doJSONRequest 以 JSON 请求体调用接口；clientIP 非空时作为请求来源，用例使用独立 IP 避免共享登录限流
+func doJSONRequest(clientIP, method, path, token string, body interface{}) (*httptest.ResponseRecorder, Response) {
+	raw, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, path, bytes.NewBuffer(raw))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+	if clientIP != "" {
+		req.RemoteAddr = clientIP + ":40000"
+	}
+	w := httptest.NewRecorder()
+	testRouter.ServeHTTP(w, req)
+
+	var resp Response
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	return w, resp
+}
+
+// loginAs 登录并返回令牌对，登录失败时用例直接失败
+func loginAs(clientIP, email, password string) LoginData {
+	_, resp := doJSONRequest(clientIP, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"email":    email,
+		"password": password,
+	})
+	Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+	var data LoginData
+	_ = json.Unmarshal(resp.Data, &data)
+	return data
+}
+
 var (
 	testDB     *gorm.DB
 	testRedis  *goredis.Client
 	testRouter *gin.Engine
+	// testOrderSvc 用于直接驱动超时关单等 MQ 触发的流程
+	testOrderSvc *order.Service
+	// testOrderMQ 订单超时 MQ 配置，死信队列用例按此投递消息
+	testOrderMQ *appconfig.OrderMQConfig
+	// testAuthConfig 认证配置，会话上限用例临时调整策略
+	testAuthConfig *appconfig.AuthSection
+	// testUserConfig 用户配置，邮箱验证用例临时开启 require_email_verified
+	testUserConfig *appconfig.UserSection
+	// testMailPath 测试使用文件邮件，用例从中读取发出的邮件
+	testMailPath string
 
 	pgContainer    testcontainers.Container
 	redisContainer testcontainers.Container
 	rmqContainer   testcontainers.Container
-	mqCh           *amqp.Channel
+	mqManager      *mq.Manager
 	mqCleanup      func()
 	appStopFunc    func()
 )
@@ -175,7 +226,17 @@ var _ = BeforeSuite(func() {
 		&model.WalletLog{},
 		&model.Product{},
 		&model.Order{},
+		&model.OrderItem{},
+		&model.OrderStatusHistory{},
+		&model.OutboxMessage{},
 		&model.StockChangeLog{},
+		&model.CouponTemplate{},
+		&model.UserCoupon{},
+		&model.RefundRequest{},
+		&model.AuthAuditLog{},
+		&model.UserTOTP{},
+		&model.UserRecoveryCode{},
+		&model.Address{},
 	); err != nil {
 		logger.Fatal("数据库AutoMigrate失败")
 	}
@@ -187,7 +248,7 @@ var _ = BeforeSuite(func() {
 		PoolSize: config.Redis.PoolSize,
 	})
 
-	mqCh, mqCleanup, err = mq.InitMq(ctx, logger, mq.Config{
+	mqManager, mqCleanup, err = mq.InitMq(ctx, logger, mq.Config{
 		User:     config.RabbitMQ.User,
 		Password: config.RabbitMQ.Password,
 		Host:     config.RabbitMQ.Host,
@@ -195,8 +256,18 @@ var _ = BeforeSuite(func() {
 	})
 
 	// --- Service 初始化 ---
+	// 使用运行时生成的 Ed25519 密钥签发令牌，token_secret 保留用于验证无 kid 的旧令牌
+	config.Auth.SigningKeys = []appconfig.SigningKeyConfig{
+		{Kid: testSigningKid, Alg: auth.AlgEdDSA, PrivateKey: generateSigningKeyPEM(auth.AlgEdDSA)},
+	}
+	config.Auth.ActiveKid = testSigningKid
+	testAuthConfig = &config.Auth
+	authKeys, err := auth.NewKeySet(&config.Auth)
+	if err != nil {
+		logger.Fatal("加载令牌签名密钥失败", zap.Error(err))
+	}
 	authRepo := auth.NewRepository(testDB, testRedis, &config.Auth)
-	authSvc := auth.NewService(authRepo, &config.Auth)
+	authSvc := auth.NewService(authRepo, &config.Auth, authKeys)
 
 	walletRepo := wallet.NewRepository(testDB, testRedis)
 	walletSvc := wallet.NewService(walletRepo)
@@ -206,21 +277,62 @@ var _ = BeforeSuite(func() {
 	if err != nil {
 		logger.Fatal("metrics初始化失败", zap.Error(err))
 	}
-	userRepo := user.NewRepository(testDB)
-	userSvc := user.NewService(userRepo, walletRepo, userMetrics)
+	testMailPath = filepath.Join(os.TempDir(), fmt.Sprintf("e-commerce-mail-%d.jsonl", time.Now().UnixNano()))
+	userMailer, err := mailer.New(mailer.Config{
+		Driver:   mailer.DriverFile,
+		From:     config.Mail.From,
+		FilePath: testMailPath,
+	})
+	if err != nil {
+		logger.Fatal("邮件发送初始化失败", zap.Error(err))
+	}
+	testUserConfig = &config.User
 
 	productRepo := product.NewRepository(testDB)
 	productSvc := product.NewService(testDB, productRepo)
 
-	orderRepo := order.NewRepository(testDB, mqCh, &config.OrderMQ)
-	if err := orderRepo.SetupMQ(&config.OrderMQ); err != nil {
+	// 重试延迟缩短以便验证死信流程
+	config.OrderMQ.RetryBaseTTLMs = 100
+	config.OrderMQ.MaxRetries = 2
+	testOrderMQ = &config.OrderMQ
+
+	orderRepo := order.NewRepository(testDB, &config.OrderMQ)
+	if err := mqManager.Register("order-topology", orderRepo.SetupMQ); err != nil {
 		logger.Fatal("初始化order mq失败", zap.Error(err))
 	}
 	couponRepo := coupon.NewRepository(testDB)
 	couponH := coupon.NewHandler(coupon.NewService(testDB, couponRepo))
-	orderSvc := order.NewService(testDB, orderRepo, productRepo, couponRepo)
+	addressRepo := address.NewRepository(testDB)
+	addressH := address.NewHandler(address.NewService(testDB, addressRepo))
+	orderSvc := order.NewService(testDB, orderRepo, productRepo, couponRepo, walletRepo, addressRepo, &config.Order)
+	testOrderSvc = orderSvc
+	userRepo := user.NewRepository(testDB, testRedis)
+	userSvc := user.NewService(userRepo, walletRepo, couponRepo, orderRepo, addressRepo, authSvc, userMetrics, userMailer, &config.User)
+	cartH := cart.NewHandler(cart.NewService(cart.NewRepository(testRedis), productRepo, orderSvc))
+	refundH := refund.NewHandler(refund.NewService(testDB, refund.NewRepository(testDB), orderRepo, productRepo, walletRepo))
+
+	// BeforeSuite 结束时 ctx 会被取消，后台协程使用独立 ctx，AfterSuite 中停止
+	bgCtx, bgCancel := context.WithCancel(clog.WithLogger(context.Background(), logger))
+	appStopFunc = bgCancel
+	outboxRelay := outbox.NewRelay(outbox.NewRepository(testDB))
+	if err := mqManager.Register("outbox-publisher", outboxRelay.Attach); err != nil {
+		logger.Fatal("启动 outbox relay 失败", zap.Error(err))
+	}
+	outboxRelay.Start(bgCtx)
+
+	orderMqHandler := order.NewMqHandler(orderSvc, &config.OrderMQ)
+	if err := mqManager.Register("order-timeout-consumer", func(ch *amqp.Channel) error {
+		return orderMqHandler.ListenTimeout(bgCtx, ch, config.OrderMQ.ConsumerQueue)
+	}); err != nil {
+		logger.Fatal("启动订单消费者失败", zap.Error(err))
+	}
+
+	dlq := order.NewDeadLetterQueue(&config.OrderMQ)
+	if err := mqManager.Register("order-dead-letter", dlq.Attach); err != nil {
+		logger.Fatal("初始化订单死信队列失败", zap.Error(err))
+	}
 
-	testRouter, err = app.SetupRouter(config, authSvc, userSvc, walletSvc, productSvc, orderSvc, couponH, logger, &mp)
+	testRouter, err = app.SetupRouter(config, authSvc, userSvc, walletSvc, productSvc, orderSvc, couponH, cartH, refundH, addressH, order.NewDeadLetterHandler(dlq), logger, &mp)
 	if err != nil {
 		logger.Fatal("初始化路由失败", zap.Error(err))
 	}
@@ -236,6 +348,7 @@ var _ = AfterSuite(func() {
 	if mqCleanup != nil {
 		mqCleanup()
 	}
+	_ = os.Remove(testMailPath)
 
 	defer func() {
 		if err := pgContainer.Terminate(ctx); err != nil {
@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"context"
+	"e-commerce/internal/model"
+	"e-commerce/pkg/errno"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/uuid"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ = Describe("RefreshTokenRotation", Ordered, func() {
+	var (
+		userID  uuid.UUID
+		initial LoginData
+		rotated LoginData
+	)
+
+	refresh := func(refreshToken, ua string) (LoginData, Response) {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/auth/fetch-access-token", nil)
+		req.Header.Set("Authorization", refreshToken)
+		req.Header.Set("User-Agent", ua)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		var data LoginData
+		_ = json.Unmarshal(resp.Data, &data)
+		return data, resp
+	}
+
+	checkToken := func(token string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/auth/sessions", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		testRouter.ServeHTTP(w, req)
+
+		var resp Response
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.Code
+	}
+
+	BeforeAll(func() {
+		pwHash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.DefaultCost)
+		userID = uuid.New()
+		testDB.Exec(`INSERT INTO users (id, user_name, email, password, created_at, updated_at) VALUES (?, ?, ?, ?, NOW(), NOW())`,
+			userID, "rotation-user", "rotation-user@test.com", string(pwHash))
+
+		_, resp := doLogin("rotation-user@test.com", "password123")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		_ = json.Unmarshal(resp.Data, &initial)
+	})
+
+	AfterAll(func() {
+		ctx := context.Background()
+		key := fmt.Sprintf("ident:u_sessions:%s", userID)
+		for _, sid := range testRedis.ZRange(ctx, key, 0, -1).Val() {
+			testRedis.Del(ctx, fmt.Sprintf("ident:sess:%s", sid))
+		}
+		testRedis.Del(ctx, key)
+		testDB.Where("user_id = ?", userID).Delete(&model.AuthAuditLog{})
+		testDB.Exec("DELETE FROM users WHERE id = ?", userID)
+	})
+
+	It("刷新后签发新的令牌对，旧 AccessToken 失效", func() {
+		var resp Response
+		rotated, resp = refresh(initial.RefreshToken, "rotation-client")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		Expect(rotated.AccessToken).NotTo(BeEmpty())
+		Expect(rotated.RefreshToken).NotTo(Equal(initial.RefreshToken))
+
+		Expect(checkToken(rotated.AccessToken)).To(Equal(errno.OK.FullCode()))
+		Expect(checkToken(initial.AccessToken)).To(Equal(errno.ErrAuthSessionRevoked.FullCode()))
+	})
+
+	It("重用已轮换的 RefreshToken 注销整个会话并记录审计日志", func() {
+		_, resp := refresh(initial.RefreshToken, "attacker-agent")
+		Expect(resp.Code).To(Equal(errno.ErrAuthRefreshTokenReused.FullCode()))
+
+		// 会话被注销后，合法客户端持有的最新令牌也全部失效
+		Expect(checkToken(rotated.AccessToken)).NotTo(Equal(errno.OK.FullCode()))
+		_, resp = refresh(rotated.RefreshToken, "rotation-client")
+		Expect(resp.Code).NotTo(Equal(errno.OK.FullCode()))
+
+		var logs []model.AuthAuditLog
+		Expect(testDB.Where("user_id = ?", userID).Find(&logs).Error).ToNot(HaveOccurred())
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Event).To(Equal(model.AuthAuditRefreshTokenReuse))
+		Expect(logs[0].UserAgent).To(Equal("attacker-agent"))
+		Expect(logs[0].SessionID).NotTo(BeEmpty())
+	})
+})
@@ -5,6 +5,7 @@ import (
 	"e-commerce/pkg/errno"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"github.com/goccy/go-json"
 	"github.com/google/uuid"
@@ -94,6 +95,77 @@ var _ = Describe("WalletApi", Ordered, func() {
 		Expect(resp2.Code).To(Equal(errno.OK.FullCode()))
 	})
 
+	It("查询余额与流水，流水带入账后余额", func() {
+		get := func(path string) Response {
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", walletToken)
+			w := httptest.NewRecorder()
+			testRouter.ServeHTTP(w, req)
+
+			var resp Response
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			return resp
+		}
+		type logPage struct {
+			Logs []struct {
+				Amount       float64 `json:"amount"`
+				BalanceAfter float64 `json:"balance_after"`
+				Type         string  `json:"type"`
+			} `json:"logs"`
+			NextCursor string `json:"next_cursor"`
+		}
+
+		resp := get("/api/v1/wallet")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var wallet struct {
+			Balance float64 `json:"balance"`
+		}
+		json.Unmarshal(resp.Data, &wallet)
+		Expect(wallet.Balance).To(Equal(300.0))
+
+		// 按时间倒序，每页一条
+		resp = get("/api/v1/wallet/logs?limit=1")
+		Expect(resp.Code).To(Equal(errno.OK.FullCode()))
+		var page logPage
+		json.Unmarshal(resp.Data, &page)
+		Expect(page.Logs).To(HaveLen(1))
+		Expect(page.Logs[0].Amount).To(Equal(200.0))
+		Expect(page.Logs[0].BalanceAfter).To(Equal(300.0))
+		Expect(page.NextCursor).NotTo(BeEmpty())
+
+		resp = get("/api/v1/wallet/logs?limit=1&cursor=" + page.NextCursor)
+		page = logPage{}
+		json.Unmarshal(resp.Data, &page)
+		Expect(page.Logs).To(HaveLen(1))
+		Expect(page.Logs[0].Amount).To(Equal(100.0))
+		Expect(page.Logs[0].BalanceAfter).To(Equal(100.0))
+		Expect(page.NextCursor).To(BeEmpty())
+
+		// 不存在或不属于当前用户的游标返回参数错误，而不是空页
+		resp = get("/api/v1/wallet/logs?cursor=" + uuid.NewString())
+		Expect(resp.Code).To(Equal(errno.ErrInvalidParam.FullCode()))
+
+		today := time.Now().Format(time.DateOnly)
+		tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+		resp = get("/api/v1/wallet/logs?type=deposit&start_date=" + today + "&end_date=" + today)
+		page = logPage{}
+		json.Unmarshal(resp.Data, &page)
+		Expect(page.Logs).To(HaveLen(2))
+
+		resp = get("/api/v1/wallet/logs?type=payment")
+		page = logPage{}
+		json.Unmarshal(resp.Data, &page)
+		Expect(page.Logs).To(BeEmpty())
+
+		resp = get("/api/v1/wallet/logs?start_date=" + tomorrow)
+		page = logPage{}
+		json.Unmarshal(resp.Data, &page)
+		Expect(page.Logs).To(BeEmpty())
+
+		resp = get("/api/v1/wallet/logs?start_date=" + tomorrow + "&end_date=" + today)
+		Expect(resp.Code).To(Equal(errno.ErrInvalidParam.FullCode()))
+	})
+
 	It("钱包充值未鉴权", func() {
 		body, _ := json.Marshal(map[string]interface{}{
 			"amount":          100.0,